@@ -2,16 +2,33 @@ package adaptor
 
 import (
 	"context"
+	"errors"
 )
 
+// ErrStale 数据已软过期，适配器仍返回该数据，由调用方在后台异步刷新
+var ErrStale = errors.New("stale value")
+
 // Adaptor 定义了核心的数据读写删除等适配接口
 type Adaptor[K comparable, V Metadata] interface {
 	// Name 适配器名称，需要在当前业务场景中保证唯一
 	Name() string
 	// Get 读取对象
+	// 若数据已软过期，返回true及ErrStale
 	Get(ctx context.Context, key K, value V) (bool, error)
 	// Set 写入对象
 	Set(ctx context.Context, value V) error
 	// Del 删除对象
 	Del(ctx context.Context, key K) error
 }
+
+// Closer 持有后台协程或外部订阅的适配器实现该接口(可选)，由缓存场景关闭时调用
+type Closer interface {
+	// Close 停止后台协程并释放资源，ctx到期时返回ctx.Err()
+	Close(ctx context.Context) error
+}
+
+// Source 数据源适配器标记接口(可选)，仅操作缓存的流程(如预热)遇到实现该接口的适配器时停止向下查询
+type Source interface {
+	// Source 标记方法
+	Source()
+}
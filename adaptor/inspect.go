@@ -0,0 +1,41 @@
+package adaptor
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotSupported 适配器不支持该操作
+var ErrNotSupported = errors.New("operation not supported by adaptor")
+
+// ErrAdaptorNotFound 缓存场景中不存在指定名称的适配器
+var ErrAdaptorNotFound = errors.New("adaptor not found")
+
+// EntryInfo 缓存条目在单个适配器中的状态
+type EntryInfo struct {
+	// Adaptor 适配器名称
+	Adaptor string
+	// Supported 适配器是否实现了Inspector接口，未实现时其余字段无意义
+	Supported bool
+	// Found 缓存中是否存在该key
+	Found bool
+	// TTL 剩余过期时间，零值表示未设置过期时间
+	TTL time.Duration
+	// Size 数据大小(字节)，不含版本号头部；对象存储为估算的内存占用
+	Size int64
+	// Err 查询失败的错误
+	Err error
+}
+
+// Inspector 按key查询缓存条目状态(可选)，用于Key管理
+type Inspector[K comparable] interface {
+	// Inspect 查询key在当前适配器中的状态，key不存在时返回Found为false的结果
+	Inspect(ctx context.Context, key K) (EntryInfo, error)
+}
+
+// Scanner 按前缀扫描缓存key(可选)，用于Key管理
+type Scanner interface {
+	// Scan 扫描以prefix开头的key，返回不含适配器key前缀的key及下一次扫描的游标，游标为0表示扫描结束
+	Scan(ctx context.Context, prefix string, cursor uint64, count int64) ([]string, uint64, error)
+}
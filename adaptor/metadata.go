@@ -11,3 +11,32 @@ type Metadata interface {
 	// Zero 判定对象是否为零值
 	Zero() bool
 }
+
+// Placeholder 零值占位接口(可选)
+// 批量查询时数据源中不存在的key，通过NewValueFunc创建对象并绑定key，作为零值占位对象写入缓存，防止缓存穿透
+type Placeholder interface {
+	// Placeholder 将对象初始化为指定key的零值占位对象，初始化后Key需返回该key，Zero需返回true
+	Placeholder(key string)
+}
+
+// Versioned 版本号接口(可选)
+// 实现该接口的对象写入缓存时比较版本号，丢弃版本号小于缓存中已有数据的写入，防止并发回填及同步事件以旧数据覆盖新数据
+type Versioned interface {
+	// Version 对象版本号，如数据更新时间戳，版本号越大数据越新
+	Version() int64
+}
+
+// IsVersioned 判断对象类型是否实现了Versioned接口
+func IsVersioned[V Metadata]() bool {
+	var v V
+	_, ok := any(v).(Versioned)
+	return ok
+}
+
+// VersionOf 获取对象的版本号，对象未实现Versioned接口时返回零
+func VersionOf(v Metadata) int64 {
+	if versioned, ok := v.(Versioned); ok {
+		return versioned.Version()
+	}
+	return 0
+}
@@ -1,11 +1,24 @@
 package adaptor
 
 const (
+	LogEventInit       = "INIT"
 	LogEventGet        = "GET"
 	LogEventSet        = "SET"
 	LogEventRefill     = "REFILL"
+	LogEventRefresh    = "REFRESH"
+	LogEventRebuild    = "REBUILD"
 	LogEventDel        = "DEL"
+	LogEventWrite      = "WRITE"
+	LogEventEvict      = "EVICT"
+	LogEventInvalidate = "INVALIDATE"
+	LogEventGeneration = "GENERATION"
 	LogEventSync       = "SYNC"
 	LogEventSyncAdd    = "SYNCSET"
 	LogEventSyncDelete = "SYNCDELETE"
+	LogEventSyncFlush  = "SYNCFLUSH"
+	LogEventSyncGap    = "SYNCGAP"
+	LogEventReconnect  = "RECONNECT"
+	LogEventWarmup     = "WARMUP"
+	LogEventSnapshot   = "SNAPSHOT"
+	LogEventClose      = "CLOSE"
 )
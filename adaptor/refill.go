@@ -0,0 +1,18 @@
+package adaptor
+
+import "context"
+
+// refillKey 回填写入标记的ctx key
+type refillKey struct{}
+
+// WithRefill 标记ctx为读取路径上的数据回填
+// 下层适配器读取到数据后回写上层适配器时使用，回填的数据与下层一致，不代表数据发生了变更
+func WithRefill(ctx context.Context) context.Context {
+	return context.WithValue(ctx, refillKey{}, true)
+}
+
+// IsRefill 判断写入是否为读取路径上的数据回填
+func IsRefill(ctx context.Context) bool {
+	refill, _ := ctx.Value(refillKey{}).(bool)
+	return refill
+}
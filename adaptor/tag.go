@@ -0,0 +1,33 @@
+package adaptor
+
+import "context"
+
+// Tagged 标签接口(可选)
+// 实现该接口的对象写入缓存时记录标签与key的关联，可通过Invalidator.InvalidateTag删除带有指定标签的全部数据，如租户ID
+type Tagged interface {
+	// Tags 对象的标签
+	Tags() []string
+}
+
+// TagsOf 获取对象的标签，对象未实现Tagged接口时返回nil
+func TagsOf(v Metadata) []string {
+	if tagged, ok := v.(Tagged); ok {
+		return tagged.Tags()
+	}
+	return nil
+}
+
+// IsTagged 判断对象类型是否实现了Tagged接口
+func IsTagged[V Metadata]() bool {
+	var v V
+	_, ok := any(v).(Tagged)
+	return ok
+}
+
+// Invalidator 按标签或key前缀批量删除缓存(可选)
+type Invalidator interface {
+	// InvalidateTag 删除带有tag标签的数据，返回删除条数
+	InvalidateTag(ctx context.Context, tag string) (int, error)
+	// InvalidatePrefix 删除key以prefix开头的数据，prefix不含适配器key前缀，返回删除条数
+	InvalidatePrefix(ctx context.Context, prefix string) (int, error)
+}
@@ -0,0 +1,29 @@
+package adaptor
+
+import "time"
+
+// Expiring 过期时间接口(可选)
+// 实现该接口的对象写入缓存时以TTL作为基础过期时间，返回值不大于零时使用适配器配置的过期时间
+type Expiring interface {
+	// TTL 对象的过期时间
+	TTL() time.Duration
+}
+
+// TTLPolicy 过期时间策略，key为不含适配器前缀的对象key，ttl为适配器计算出的基础过期时间
+// 返回最终过期时间，返回值不大于零时使用基础过期时间
+type TTLPolicy func(key string, value Metadata, ttl time.Duration) time.Duration
+
+// ResolveTTL 计算对象的过期时间，依次应用对象的Expiring接口及过期时间策略
+func ResolveTTL(value Metadata, ttl time.Duration, policy TTLPolicy) time.Duration {
+	if expiring, ok := value.(Expiring); ok {
+		if d := expiring.TTL(); d > 0 {
+			ttl = d
+		}
+	}
+	if policy != nil {
+		if d := policy(value.Key(), value, ttl); d > 0 {
+			ttl = d
+		}
+	}
+	return ttl
+}
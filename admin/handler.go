@@ -0,0 +1,241 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/utils"
+)
+
+// ErrSolutionNotFound 未注册的缓存场景
+var ErrSolutionNotFound = errors.New("solution not found")
+
+// Solution 可管理的缓存场景，multicache.Cache及multicache.MultiCache均实现了该接口
+type Solution interface {
+	// Name 缓存场景名称
+	Name() string
+	// Layers 各级适配器名称
+	Layers() []string
+	// Inspect 查询key在各级适配器中的状态
+	Inspect(ctx context.Context, key string) ([]adaptor.EntryInfo, error)
+	// Purge 删除key的缓存，layer为空时删除各级缓存
+	Purge(ctx context.Context, key string, layer string) error
+	// Scan 在指定适配器中按前缀扫描key
+	Scan(ctx context.Context, layer string, prefix string, cursor uint64, count int64) ([]string, uint64, error)
+}
+
+// Handler Key管理HTTP接口，可通过http.StripPrefix挂载至任意路径
+//
+//	GET    /solutions                                          已注册的缓存场景及各级适配器
+//	GET    /keys?solution=&key=                                key在各级适配器中的状态
+//	DELETE /keys?solution=&key=[&layer=]                       删除key在指定适配器或各级适配器中的缓存
+//	GET    /scan?solution=&layer=&prefix=[&cursor=][&count=]   在指定适配器中按前缀扫描key
+type Handler struct {
+	mu        sync.RWMutex
+	solutions map[string]Solution
+	mux       *http.ServeMux
+}
+
+// NewHandler 创建Key管理HTTP接口
+func NewHandler(solutions ...Solution) *Handler {
+	h := &Handler{
+		solutions: make(map[string]Solution),
+		mux:       http.NewServeMux(),
+	}
+	for _, s := range solutions {
+		h.Register(s)
+	}
+	h.mux.HandleFunc("/solutions", h.listSolutions)
+	h.mux.HandleFunc("/keys", h.keys)
+	h.mux.HandleFunc("/scan", h.scan)
+	return h
+}
+
+// Register 注册缓存场景，同名场景将被覆盖
+func (h *Handler) Register(s Solution) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.solutions[s.Name()] = s
+}
+
+// Unregister 注销缓存场景
+func (h *Handler) Unregister(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.solutions, name)
+}
+
+// ServeHTTP 实现http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// SolutionInfo 缓存场景信息
+type SolutionInfo struct {
+	Name   string   `json:"name"`
+	Layers []string `json:"layers"`
+}
+
+// LayerInfo key在单个适配器中的状态
+type LayerInfo struct {
+	Adaptor   string `json:"adaptor"`
+	Supported bool   `json:"supported"`
+	Found     bool   `json:"found"`
+	// 剩余过期时间(毫秒)，0表示未设置过期时间
+	TTL   int64  `json:"ttl_ms"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+// KeyInfo key在各级适配器中的状态
+type KeyInfo struct {
+	Solution string      `json:"solution"`
+	Key      string      `json:"key"`
+	Layers   []LayerInfo `json:"layers"`
+}
+
+// ScanResult 扫描结果，Cursor为0表示扫描结束
+type ScanResult struct {
+	Keys   []string `json:"keys"`
+	Cursor uint64   `json:"cursor"`
+}
+
+// listSolutions 已注册的缓存场景
+func (h *Handler) listSolutions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	h.mu.RLock()
+	infos := make([]SolutionInfo, 0, len(h.solutions))
+	for _, s := range h.solutions {
+		infos = append(infos, SolutionInfo{Name: s.Name(), Layers: s.Layers()})
+	}
+	h.mu.RUnlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// keys 查询或删除key
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	s, err := h.solution(query.Get("solution"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	key := query.Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, errors.New("key is required"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		entries, err := s.Inspect(r.Context(), key)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		info := KeyInfo{Solution: s.Name(), Key: key, Layers: make([]LayerInfo, 0, len(entries))}
+		for _, e := range entries {
+			layer := LayerInfo{
+				Adaptor:   e.Adaptor,
+				Supported: e.Supported,
+				Found:     e.Found,
+				TTL:       e.TTL.Milliseconds(),
+				Size:      e.Size,
+			}
+			if e.Err != nil {
+				layer.Error = e.Err.Error()
+			}
+			info.Layers = append(info.Layers, layer)
+		}
+		writeJSON(w, http.StatusOK, info)
+	case http.MethodDelete:
+		err := s.Purge(r.Context(), key, query.Get("layer"))
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+// scan 按前缀扫描key
+func (h *Handler) scan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	query := r.URL.Query()
+	s, err := h.solution(query.Get("solution"))
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	var cursor uint64
+	var count int64
+	if v := query.Get("cursor"); v != "" {
+		if cursor, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if v := query.Get("count"); v != "" {
+		if count, err = strconv.ParseInt(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	keys, next, err := s.Scan(r.Context(), query.Get("layer"), query.Get("prefix"), cursor, count)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ScanResult{Keys: keys, Cursor: next})
+}
+
+// solution 按名称查找缓存场景
+func (h *Handler) solution(name string) (Solution, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	s, ok := h.solutions[name]
+	if !ok {
+		return nil, ErrSolutionNotFound
+	}
+	return s, nil
+}
+
+// statusOf 错误对应的HTTP状态码
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrSolutionNotFound), errors.Is(err, adaptor.ErrAdaptorNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, utils.ErrInvalidKey), errors.Is(err, adaptor.ErrNotSupported):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 输出错误响应
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
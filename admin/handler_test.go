@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/rumis/multicache"
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/local"
+	"github.com/rumis/multicache/remote"
+	"github.com/rumis/multicache/syncer"
+	"github.com/rumis/multicache/tests"
+)
+
+func TestHandler(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	newLocal := func() *local.FreeCache[string, *tests.Student] {
+		return local.NewFreeCache[string, *tests.Student](freecache.NewCache(1024*1024), nil,
+			local.WithName("local"), local.WithSyncer(syncer.NewRedisSyncer(redisClient, "admin_test")))
+	}
+	// 两个实例的本地缓存通过Syncer同步
+	testLocal, peerLocal := newLocal(), newLocal()
+	testRemote := remote.NewRedisAdaptor[string, *tests.Student](redisClient, testLocal, remote.WithName("remote"), remote.WithPrefix("admin_test_"))
+	cacheInst := multicache.NewCache[string, *tests.Student]("student", testLocal, testRemote)
+	server := httptest.NewServer(NewHandler(cacheInst))
+	defer server.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range []string{"张三", "李四", "王五"} {
+		if err := cacheInst.Set(context.Background(), &tests.Student{Name: name, Age: 18}); err != nil {
+			t.Fatal("Set Error", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	do := func(method string, path string, v any) int {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+
+	var solutions []SolutionInfo
+	if code := do(http.MethodGet, "/solutions", &solutions); code != http.StatusOK || len(solutions) != 1 || len(solutions[0].Layers) != 2 {
+		t.Errorf("solutions = %d, %+v", code, solutions)
+	}
+
+	var info KeyInfo
+	if code := do(http.MethodGet, "/keys?solution=student&key=张三", &info); code != http.StatusOK {
+		t.Fatalf("inspect = %d", code)
+	}
+	for _, layer := range info.Layers {
+		if !layer.Supported || !layer.Found || layer.TTL <= 0 || layer.Size == 0 {
+			t.Errorf("layer = %+v", layer)
+		}
+	}
+
+	var scan ScanResult
+	if code := do(http.MethodGet, "/scan?solution=student&layer=remote&prefix=张", &scan); code != http.StatusOK || len(scan.Keys) != 1 || scan.Keys[0] != "张三" {
+		t.Errorf("scan = %d, %+v", code, scan)
+	}
+	if code := do(http.MethodGet, "/scan?solution=student&layer=local&prefix=张", nil); code != http.StatusBadRequest {
+		t.Errorf("scan local = %d, want 400", code)
+	}
+
+	// 仅删除本地缓存，删除操作广播至其他实例
+	if code := do(http.MethodDelete, "/keys?solution=student&key=张三&layer=local", nil); code != http.StatusNoContent {
+		t.Fatalf("purge = %d", code)
+	}
+	time.Sleep(100 * time.Millisecond)
+	peerInfo, _ := multicache.NewCache[string, *tests.Student]("peer", peerLocal).Inspect(context.Background(), "张三")
+	if peerInfo[0].Found {
+		t.Error("peer local cache not purged")
+	}
+	do(http.MethodGet, "/keys?solution=student&key=张三", &info)
+	if info.Layers[0].Found || !info.Layers[1].Found {
+		t.Errorf("layers = %+v", info.Layers)
+	}
+
+	// 删除各级缓存
+	if code := do(http.MethodDelete, "/keys?solution=student&key=张三", nil); code != http.StatusNoContent {
+		t.Fatalf("purge = %d", code)
+	}
+	do(http.MethodGet, "/keys?solution=student&key=张三", &info)
+	if info.Layers[1].Found {
+		t.Errorf("layers = %+v", info.Layers)
+	}
+
+	for path, want := range map[string]int{
+		"/keys?solution=teacher&key=张三":            http.StatusNotFound,
+		"/keys?solution=student":                   http.StatusBadRequest,
+		"/scan?solution=student&layer=db&prefix=张": http.StatusNotFound,
+	} {
+		if code := do(http.MethodGet, path, nil); code != want {
+			t.Errorf("%s = %d, want %d", path, code, want)
+		}
+	}
+}
+
+func TestHandlerMultiCache(t *testing.T) {
+
+	redisClient := tests.NewRedisClusterClient()
+	testRemote := remote.NewRedisMultiAdaptor[int, *tests.Student](redisClient, nil, remote.WithName("remote"))
+	cacheInst := multicache.NewMultiCache[int, *tests.Student]("student", testRemote)
+	server := httptest.NewServer(NewHandler(cacheInst))
+	defer server.Close()
+
+	cacheInst.Set(context.Background(), adaptor.ValueCol[*tests.Student]{&tests.Student{Name: "1", Age: 18}, &tests.Student{Name: "12", Age: 19}})
+
+	resp, err := http.Get(server.URL + "/scan?solution=student&layer=remote&prefix=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scan ScanResult
+	json.NewDecoder(resp.Body).Decode(&scan)
+	resp.Body.Close()
+	if len(scan.Keys) != 2 || scan.Cursor != 0 {
+		t.Errorf("scan = %+v", scan)
+	}
+	// key无法解析为int
+	resp, err = http.Get(server.URL + "/keys?solution=student&key=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("inspect = %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,58 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+)
+
+// ErrNotReady 过滤器尚未构建完成
+var ErrNotReady = errors.New("bloom filter not ready")
+
+// KeyEnumerator 全量key枚举函数，通过add批量添加所有已存在的key
+type KeyEnumerator func(ctx context.Context, add func(keys ...string) error) error
+
+// Filter 布隆过滤器接口
+type Filter interface {
+	// Add 添加key
+	Add(ctx context.Context, keys ...string) error
+	// Exists 判断key是否可能存在，返回值与keys一一对应，false表示一定不存在
+	Exists(ctx context.Context, keys ...string) ([]bool, error)
+	// Rebuild 通过枚举函数重建过滤器，重建完成前不影响当前过滤器的读写
+	Rebuild(ctx context.Context, enumerator KeyEnumerator) error
+}
+
+// optimal 根据预期元素数量及误判率计算位数组长度及哈希函数个数
+func optimal(n uint64, p float64) (m uint64, k uint64) {
+	if n == 0 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m = uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k = uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return m, k
+}
+
+// locations 通过双重哈希计算key对应的k个位置
+func locations(key string, k uint64, m uint64) []uint64 {
+	h := fnv.New128a()
+	h.Write([]byte(key))
+	sum := h.Sum(nil)
+	h1 := uint64(0)
+	h2 := uint64(0)
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[i+8])
+	}
+	locs := make([]uint64, k)
+	for i := uint64(0); i < k; i++ {
+		locs[i] = (h1 + i*h2) % m
+	}
+	return locs
+}
@@ -0,0 +1,118 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/rumis/multicache/tests"
+)
+
+// enumerate 测试用key枚举函数
+func enumerate(n int) KeyEnumerator {
+	return func(ctx context.Context, add func(keys ...string) error) error {
+		for i := 0; i < n; i++ {
+			err := add("key_" + strconv.Itoa(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func testFilter(t *testing.T, f Filter) {
+	err := f.Rebuild(context.Background(), enumerate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Add(context.Background(), "added")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已添加的key一定存在
+	keys := []string{"added"}
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, "key_"+strconv.Itoa(i))
+	}
+	exists, err := f.Exists(context.Background(), keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ok := range exists {
+		if !ok {
+			t.Errorf("%s should exist", keys[i])
+		}
+	}
+
+	// 误判率在预期范围内
+	others := make([]string, 0)
+	for i := 0; i < 1000; i++ {
+		others = append(others, "other_"+strconv.Itoa(i))
+	}
+	exists, err = f.Exists(context.Background(), others...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	falsePositive := 0
+	for _, ok := range exists {
+		if ok {
+			falsePositive++
+		}
+	}
+	if falsePositive > 50 {
+		t.Errorf("false positive %d/1000", falsePositive)
+	}
+}
+
+func TestMemoryFilter(t *testing.T) {
+	testFilter(t, NewMemoryFilter(1000, 0.01))
+}
+
+func TestRedisFilter(t *testing.T) {
+	f := NewRedisFilter(tests.NewRedisClient(), "bloom_test", 1000, 0.01)
+
+	// 构建前不可用
+	_, err := f.Exists(context.Background(), "key_0")
+	if err != ErrNotReady {
+		t.Errorf("err = %v, want ErrNotReady", err)
+	}
+
+	testFilter(t, f)
+}
+
+func TestRedisFilterConcurrentRebuild(t *testing.T) {
+	client := tests.NewRedisClient()
+	f := NewRedisFilter(client, "bloom_rebuild_test", 1000, 0.01)
+	other := NewRedisFilter(client, "bloom_rebuild_test", 1000, 0.01)
+
+	err := f.Rebuild(context.Background(), func(ctx context.Context, add func(keys ...string) error) error {
+		// 重建期间其他实例无法重建，其他实例新增的key写入临时位图
+		if err := other.Rebuild(ctx, enumerate(10)); !errors.Is(err, ErrRebuilding) {
+			t.Errorf("concurrent Rebuild() = %v, want ErrRebuilding", err)
+		}
+		if err := other.Add(ctx, "added_during_rebuild"); err != nil {
+			return err
+		}
+		return enumerate(1000)(ctx, add)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := other.Exists(context.Background(), "added_during_rebuild", "key_999")
+	if err != nil || !exists[0] || !exists[1] {
+		t.Errorf("Exists() = %v, %v", exists, err)
+	}
+	// 重建完成后释放锁，临时位图被替换
+	if n := client.Exists(context.Background(), f.lockKey()).Val(); n != 0 {
+		t.Error("rebuild lock should be released")
+	}
+	if ttl := client.PTTL(context.Background(), "bloom_rebuild_test").Val(); ttl != -1 {
+		t.Errorf("ttl = %v, filter should not expire", ttl)
+	}
+	if err := other.Rebuild(context.Background(), enumerate(10)); err != nil {
+		t.Error("Rebuild after release", err)
+	}
+}
@@ -0,0 +1,137 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+)
+
+// guard 布隆过滤器防护，负责过滤器的周期重建及key检测
+type guard struct {
+	name         string
+	solutionName string
+	filter       Filter
+	enumerator   KeyEnumerator
+	interval     time.Duration
+	// 过滤器是否可用，配置了枚举函数时首次重建完成后可用
+	ready atomic.Bool
+	// 取消进行中的重建并停止周期重建
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newGuard 创建布隆过滤器防护
+func newGuard(filter Filter, fns ...GuardOptionFunc) *guard {
+	opts := DefaultGuardOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	g := &guard{
+		name:         opts.Name,
+		solutionName: opts.SolutionName,
+		filter:       filter,
+		enumerator:   opts.Enumerator,
+		interval:     opts.RebuildInterval,
+		done:         make(chan struct{}),
+	}
+	if g.enumerator == nil {
+		g.ready.Store(true)
+		close(g.done)
+		return g
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g.cancel = cancel
+	go g.rebuildLoop(ctx)
+	return g
+}
+
+// check 检测key是否可能存在，过滤器不可用时全部视为可能存在
+func (g *guard) check(ctx context.Context, keys ...string) ([]bool, error) {
+	if g.ready.Load() {
+		exists, err := g.filter.Exists(ctx, keys...)
+		if err == nil {
+			return exists, nil
+		}
+		if !errors.Is(err, ErrNotReady) {
+			return nil, err
+		}
+	}
+	exists := make([]bool, len(keys))
+	for i := range keys {
+		exists[i] = true
+	}
+	return exists, nil
+}
+
+// add 添加key
+func (g *guard) add(ctx context.Context, keys ...string) error {
+	return g.filter.Add(ctx, keys...)
+}
+
+// Close 停止周期重建并等待进行中的重建退出，可重复调用
+func (g *guard) Close(ctx context.Context) error {
+	g.closeOnce.Do(func() {
+		if g.cancel != nil {
+			g.cancel()
+		}
+	})
+	select {
+	case <-g.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rebuildLoop 周期重建过滤器，ctx取消后退出
+func (g *guard) rebuildLoop(ctx context.Context) {
+	defer close(g.done)
+	g.rebuild(ctx)
+	if g.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.rebuild(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rebuild 重建过滤器
+func (g *guard) rebuild(ctx context.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, false)
+			logger.Error(fmt.Sprint(err), "solution", g.solutionName, "adaptor", g.name, "event", adaptor.LogEventRebuild, "stack", string(buf[:n]))
+		}
+	}()
+	err := g.filter.Rebuild(ctx, g.enumerator)
+	if errors.Is(err, ErrRebuilding) {
+		// 其他实例正在重建，当前位图(如已构建)仍可使用
+		logger.Info(err.Error(), "solution", g.solutionName, "adaptor", g.name, "event", adaptor.LogEventRebuild)
+		g.ready.Store(true)
+		return
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error(err.Error(), "solution", g.solutionName, "adaptor", g.name, "event", adaptor.LogEventRebuild)
+		return
+	}
+	g.ready.Store(true)
+}
@@ -0,0 +1,77 @@
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/metrics"
+)
+
+// 类型检测
+var _ adaptor.Adaptor[string, adaptor.Metadata] = (*GuardAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Closer = (*GuardAdaptor[string, adaptor.Metadata])(nil)
+
+// GuardAdaptor 基于布隆过滤器的缓存穿透防护适配器
+// 置于数据源适配器之前，一定不存在的key直接返回，不再查询数据源
+type GuardAdaptor[K comparable, V adaptor.Metadata] struct {
+	*guard
+}
+
+// NewGuardAdaptor 创建一个新的布隆过滤器防护适配器
+func NewGuardAdaptor[K comparable, V adaptor.Metadata](filter Filter, fns ...GuardOptionFunc) *GuardAdaptor[K, V] {
+	return &GuardAdaptor[K, V]{
+		guard: newGuard(filter, fns...),
+	}
+}
+
+// Name 适配器名称
+func (c *GuardAdaptor[K, V]) Name() string {
+	return c.name
+}
+
+// Get 读取对象
+// key一定不存在时返回true且不填充对象，调用方得到零值；可能存在时返回false，继续查询下一级适配器
+func (c *GuardAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool, error) {
+	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
+	startTime := time.Now()
+	exists, err := c.check(ctx, fmt.Sprint(key))
+	if err != nil {
+		metric.AddMeta(ctx, metrics.Meta{
+			AdaptorName: c.Name(),
+			Key:         fmt.Sprint(key),
+			Type:        metrics.Miss,
+		})
+		return false, err
+	}
+	if exists[0] {
+		metric.AddMeta(ctx, metrics.Meta{
+			AdaptorName: c.Name(),
+			Key:         fmt.Sprint(key),
+			Type:        metrics.Miss,
+			TrackTime:   time.Since(startTime).Milliseconds(),
+		})
+		return false, nil
+	}
+	metric.AddMeta(ctx, metrics.Meta{
+		AdaptorName: c.Name(),
+		Key:         fmt.Sprint(key),
+		Type:        metrics.Reject,
+		TrackTime:   time.Since(startTime).Milliseconds(),
+	})
+	return true, nil
+}
+
+// Set 写入对象，将对象的key加入过滤器
+func (c *GuardAdaptor[K, V]) Set(ctx context.Context, value V) error {
+	if value.Zero() {
+		return nil
+	}
+	return c.add(ctx, value.Key())
+}
+
+// Del 删除对象，布隆过滤器不支持删除
+func (c *GuardAdaptor[K, V]) Del(ctx context.Context, key K) error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package bloom
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/metrics"
+)
+
+// 类型检测
+var _ adaptor.MultiAdaptor[string, adaptor.Metadata] = (*GuardMultiAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Closer = (*GuardMultiAdaptor[string, adaptor.Metadata])(nil)
+
+// GuardMultiAdaptor 基于布隆过滤器的批量缓存穿透防护适配器
+type GuardMultiAdaptor[K comparable, V adaptor.Metadata] struct {
+	*guard
+}
+
+// NewGuardMultiAdaptor 创建一个新的布隆过滤器批量防护适配器
+func NewGuardMultiAdaptor[K comparable, V adaptor.Metadata](filter Filter, fns ...GuardOptionFunc) *GuardMultiAdaptor[K, V] {
+	return &GuardMultiAdaptor[K, V]{
+		guard: newGuard(filter, fns...),
+	}
+}
+
+// Name 适配器名称
+func (c *GuardMultiAdaptor[K, V]) Name() string {
+	return c.name
+}
+
+// Get 读取对象
+// 一定不存在的key以零值对象填充并返回，不再查询数据源
+func (c *GuardMultiAdaptor[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], vals adaptor.Values[K, V], fn adaptor.NewValueFunc[V]) (adaptor.Keys[K], error) {
+	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
+	startTime := time.Now()
+	rejectKeys := make(adaptor.Keys[K], 0)
+
+	strKeys := make([]string, len(keys))
+	for i, key := range keys {
+		strKeys[i] = fmt.Sprint(key)
+	}
+	exists, err := c.check(ctx, strKeys...)
+	if err != nil {
+		return rejectKeys, err
+	}
+
+	for i, key := range keys {
+		if exists[i] {
+			metric.AddMeta(ctx, metrics.Meta{
+				AdaptorName: c.Name(),
+				Key:         strKeys[i],
+				Type:        metrics.Miss,
+				TrackTime:   time.Since(startTime).Milliseconds(),
+			})
+			continue
+		}
+		val := fn()
+		if placeholder, ok := any(val).(adaptor.Placeholder); ok {
+			placeholder.Placeholder(strKeys[i])
+		}
+		vals[key] = val
+		rejectKeys = append(rejectKeys, key)
+
+		metric.AddMeta(ctx, metrics.Meta{
+			AdaptorName: c.Name(),
+			Key:         strKeys[i],
+			Type:        metrics.Reject,
+			TrackTime:   time.Since(startTime).Milliseconds(),
+		})
+	}
+	return rejectKeys, nil
+}
+
+// Set 写入对象，将对象的key加入过滤器
+func (c *GuardMultiAdaptor[K, V]) Set(ctx context.Context, vals adaptor.ValueCol[V]) error {
+	keys := make([]string, 0, len(vals))
+	for _, val := range vals {
+		if val.Zero() {
+			continue
+		}
+		keys = append(keys, val.Key())
+	}
+	return c.add(ctx, keys...)
+}
+
+// Del 删除对象，布隆过滤器不支持删除
+func (c *GuardMultiAdaptor[K, V]) Del(ctx context.Context, keys adaptor.Keys[K]) error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package bloom
+
+import (
+	"context"
+	"sync"
+)
+
+// 类型检测
+var _ Filter = (*MemoryFilter)(nil)
+
+// MemoryFilter 进程内布隆过滤器
+type MemoryFilter struct {
+	m    uint64
+	k    uint64
+	lock sync.RWMutex
+	bits []uint64
+	// 重建中的位数组，重建期间新增的key同时写入
+	next []uint64
+}
+
+// NewMemoryFilter 创建进程内布隆过滤器
+// n为预期元素数量，p为期望误判率
+func NewMemoryFilter(n uint64, p float64) *MemoryFilter {
+	m, k := optimal(n, p)
+	return &MemoryFilter{
+		m:    m,
+		k:    k,
+		bits: make([]uint64, (m+63)/64),
+	}
+}
+
+// Add 添加key
+func (f *MemoryFilter) Add(ctx context.Context, keys ...string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.add(f.bits, keys...)
+	if f.next != nil {
+		f.add(f.next, keys...)
+	}
+	return nil
+}
+
+// Exists 判断key是否可能存在
+func (f *MemoryFilter) Exists(ctx context.Context, keys ...string) ([]bool, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	exists := make([]bool, len(keys))
+	for i, key := range keys {
+		exists[i] = true
+		for _, loc := range locations(key, f.k, f.m) {
+			if f.bits[loc/64]&(1<<(loc%64)) == 0 {
+				exists[i] = false
+				break
+			}
+		}
+	}
+	return exists, nil
+}
+
+// Rebuild 通过枚举函数重建过滤器
+func (f *MemoryFilter) Rebuild(ctx context.Context, enumerator KeyEnumerator) error {
+	f.lock.Lock()
+	f.next = make([]uint64, len(f.bits))
+	f.lock.Unlock()
+
+	err := enumerator(ctx, func(keys ...string) error {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.add(f.next, keys...)
+		return nil
+	})
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err != nil {
+		f.next = nil
+		return err
+	}
+	f.bits, f.next = f.next, nil
+	return nil
+}
+
+// add 向位数组中添加key
+func (f *MemoryFilter) add(bits []uint64, keys ...string) {
+	for _, key := range keys {
+		for _, loc := range locations(key, f.k, f.m) {
+			bits[loc/64] |= 1 << (loc % 64)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package bloom
+
+import "time"
+
+// GuardOption 布隆过滤器适配器配置选项
+type GuardOption struct {
+	Name         string
+	SolutionName string
+	// 全量key枚举函数，配置后启动时及每隔RebuildInterval重建过滤器
+	Enumerator      KeyEnumerator
+	RebuildInterval time.Duration
+}
+
+// GuardOptionFunc 布隆过滤器适配器配置函数
+type GuardOptionFunc func(*GuardOption)
+
+// DefaultGuardOption 默认布隆过滤器适配器配置
+func DefaultGuardOption() GuardOption {
+	return GuardOption{
+		Name:            "bloom_guard",
+		SolutionName:    "multicache_default",
+		RebuildInterval: time.Hour,
+	}
+}
+
+// WithName 设置适配器名称
+func WithName(name string) GuardOptionFunc {
+	return func(option *GuardOption) {
+		option.Name = name
+	}
+}
+
+// WithSolutionName 场景名称
+func WithSolutionName(name string) GuardOptionFunc {
+	return func(option *GuardOption) {
+		option.SolutionName = name
+	}
+}
+
+// WithEnumerator 设置全量key枚举函数
+func WithEnumerator(fn KeyEnumerator) GuardOptionFunc {
+	return func(option *GuardOption) {
+		option.Enumerator = fn
+	}
+}
+
+// WithRebuildInterval 设置过滤器重建间隔
+func WithRebuildInterval(interval time.Duration) GuardOptionFunc {
+	return func(option *GuardOption) {
+		option.RebuildInterval = interval
+	}
+}
@@ -0,0 +1,248 @@
+package bloom
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/utils"
+)
+
+// 类型检测
+var _ Filter = (*RedisFilter)(nil)
+
+// maxRedisBits Redis位图最大长度
+const maxRedisBits = 1 << 32
+
+// rebuildLease 重建锁的租约时长，重建期间每隔rebuildLease/3续约，持有方异常退出后锁及临时位图随租约过期
+const rebuildLease = 30 * time.Second
+
+// ErrRebuilding 其他实例正在重建过滤器
+var ErrRebuilding = errors.New("bloom filter is being rebuilt by another instance")
+
+// ErrRebuildLost 重建锁租约已过期，本次重建结果被丢弃
+var ErrRebuildLost = errors.New("bloom filter rebuild lease lost")
+
+// addScript 位图已存在时设置位，避免位图丢失后被部分重建导致误判；正在重建时同时写入重建中的临时位图，避免重建完成后丢失重建期间新增的key
+// KEYS[1] 当前位图 KEYS[2] 重建锁，值为临时位图key
+var addScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	for i = 1, #ARGV do
+		redis.call('SETBIT', KEYS[1], ARGV[i], 1)
+	end
+end
+local next = redis.call('GET', KEYS[2])
+if next then
+	for i = 1, #ARGV do
+		redis.call('SETBIT', next, ARGV[i], 1)
+	end
+end
+return 1
+`)
+
+// acquireScript 获取重建锁并创建临时位图，临时位图与锁使用相同的租约
+// KEYS[1] 重建锁 KEYS[2] 临时位图 ARGV[1] 租约(毫秒)
+var acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], KEYS[2], 'NX', 'PX', ARGV[1]) then
+	redis.call('SET', KEYS[2], '', 'PX', ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// renewScript 仍持有重建锁时续约锁及临时位图
+// KEYS[1] 重建锁 KEYS[2] 临时位图 ARGV[1] 租约(毫秒)
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == KEYS[2] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+	redis.call('PEXPIRE', KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// finishScript 仍持有重建锁时以临时位图替换当前位图并释放锁
+// KEYS[1] 重建锁 KEYS[2] 临时位图 KEYS[3] 当前位图
+var finishScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= KEYS[2] then
+	return 0
+end
+redis.call('RENAME', KEYS[2], KEYS[3])
+redis.call('PERSIST', KEYS[3])
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// abortScript 仍持有重建锁时删除临时位图并释放锁
+// KEYS[1] 重建锁 KEYS[2] 临时位图
+var abortScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == KEYS[2] then
+	redis.call('DEL', KEYS[1], KEYS[2])
+end
+return 1
+`)
+
+// RedisFilter 基于Redis位图的布隆过滤器，多实例共享
+// 位图需通过Rebuild构建后才可用，Add仅向已构建的位图中追加key
+// 重建状态保存在Redis中：同一时刻仅有一个实例持有重建锁，重建期间所有实例的Add同时写入临时位图
+type RedisFilter struct {
+	m      uint64
+	k      uint64
+	key    string
+	client redis.UniversalClient
+}
+
+// NewRedisFilter 创建基于Redis位图的布隆过滤器
+// key为位图的存储key，n为预期元素数量，p为期望误判率
+func NewRedisFilter(client redis.UniversalClient, key string, n uint64, p float64) *RedisFilter {
+	m, k := optimal(n, p)
+	if m > maxRedisBits {
+		m = maxRedisBits
+	}
+	return &RedisFilter{
+		m:      m,
+		k:      k,
+		key:    key,
+		client: client,
+	}
+}
+
+// Add 添加key
+func (f *RedisFilter) Add(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	locs := make([]interface{}, 0, len(keys)*int(f.k))
+	for _, key := range keys {
+		for _, loc := range locations(key, f.k, f.m) {
+			locs = append(locs, loc)
+		}
+	}
+	return addScript.Run(ctx, f.client, []string{f.key, f.lockKey()}, locs...).Err()
+}
+
+// Exists 判断key是否可能存在
+// 位图不存在(如尚未构建或被淘汰)时返回ErrNotReady
+func (f *RedisFilter) Exists(ctx context.Context, keys ...string) ([]bool, error) {
+	var existsCmd *redis.IntCmd
+	cmds := make([][]*redis.IntCmd, len(keys))
+	_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		existsCmd = pipe.Exists(ctx, f.key)
+		for i, key := range keys {
+			for _, loc := range locations(key, f.k, f.m) {
+				cmds[i] = append(cmds[i], pipe.GetBit(ctx, f.key, int64(loc)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existsCmd.Val() == 0 {
+		return nil, ErrNotReady
+	}
+	exists := make([]bool, len(keys))
+	for i := range keys {
+		exists[i] = true
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				exists[i] = false
+				break
+			}
+		}
+	}
+	return exists, nil
+}
+
+// Rebuild 通过枚举函数重建过滤器
+// 获取重建锁后将数据写入唯一的临时位图，完成后替换当前位图；其他实例正在重建时返回ErrRebuilding
+func (f *RedisFilter) Rebuild(ctx context.Context, enumerator KeyEnumerator) error {
+	lockKey := f.lockKey()
+	nextKey := f.rebuildKey()
+	keys := []string{lockKey, nextKey}
+	lease := rebuildLease.Milliseconds()
+	ok, err := acquireScript.Run(ctx, f.client, keys, lease).Bool()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrRebuilding
+	}
+
+	// 重建期间定期续约
+	renewCtx, cancel := context.WithCancel(ctx)
+	renewDone := make(chan struct{})
+	go func() {
+		defer close(renewDone)
+		ticker := time.NewTicker(rebuildLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				renewScript.Run(renewCtx, f.client, keys, lease)
+			}
+		}
+	}()
+	stopRenew := func() {
+		cancel()
+		<-renewDone
+	}
+
+	err = enumerator(ctx, func(keys ...string) error {
+		return f.add(ctx, nextKey, keys...)
+	})
+	stopRenew()
+	if err != nil {
+		abortScript.Run(context.Background(), f.client, keys)
+		return err
+	}
+	ok, err = finishScript.Run(ctx, f.client, []string{lockKey, nextKey, f.key}).Bool()
+	if err != nil {
+		abortScript.Run(context.Background(), f.client, keys)
+		return err
+	}
+	if !ok {
+		return ErrRebuildLost
+	}
+	return nil
+}
+
+// add 向指定位图中添加key
+func (f *RedisFilter) add(ctx context.Context, bitKey string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := f.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			for _, loc := range locations(key, f.k, f.m) {
+				pipe.SetBit(ctx, bitKey, int64(loc), 1)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// rebuildKey 本次重建使用的唯一临时位图key，与当前位图位于同一槽位
+func (f *RedisFilter) rebuildKey() string {
+	return f.slotKey() + ":rebuild:" + utils.UUID()
+}
+
+// lockKey 重建锁key，值为重建中的临时位图key，与当前位图位于同一槽位
+func (f *RedisFilter) lockKey() string {
+	return f.slotKey() + ":rebuilding"
+}
+
+// slotKey 与当前位图位于同一槽位的key前缀
+func (f *RedisFilter) slotKey() string {
+	if s := strings.IndexByte(f.key, '{'); s > -1 {
+		if e := strings.IndexByte(f.key[s+1:], '}'); e > 0 {
+			return f.key
+		}
+	}
+	return "{" + f.key + "}"
+}
@@ -2,8 +2,14 @@ package multicache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/utils"
@@ -13,24 +19,58 @@ type Cache[K comparable, V adaptor.Metadata] struct {
 	name     string
 	adaptors []adaptor.Adaptor[K, V]
 	metric   metrics.Metrics
+	// 正在后台刷新的key及刷新协程
+	refreshing sync.Map
+	refreshWg  sync.WaitGroup
+	// 保护closed及refreshWg.Add，关闭后不再启动后台刷新
+	refreshMu sync.Mutex
+	closed    bool
+	// 数据源写入器
+	writer *writer[K, V]
+	// 延迟双删
+	deleter *doubleDeleter[K]
+	// 热点key探测
+	hotKey *hotkey.Detector
+	// 缓存代数
+	generation *generation.Generation
+	// 自适应过期时间
+	adaptiveTTL *hotkey.AdaptiveTTL
 }
 
 // NewCache 创建一个新的Cache对象
 func NewCache[K comparable, V adaptor.Metadata](name string, adaptors ...adaptor.Adaptor[K, V]) *Cache[K, V] {
-	return &Cache[K, V]{
-		name:     name,
-		adaptors: adaptors,
-		metric:   metrics.DefaultMetrics(),
-	}
+	return NewCacheWithOption(name, adaptors)
 }
 
 // NewCacheWithMetric 创建一个新的Cache对象，包含自定义指标计数器
 func NewCacheWithMetric[K comparable, V adaptor.Metadata](name string, metric metrics.Metrics, adaptors ...adaptor.Adaptor[K, V]) *Cache[K, V] {
-	return &Cache[K, V]{
-		name:     name,
-		adaptors: adaptors,
-		metric:   metric,
+	return NewCacheWithOption(name, adaptors, WithMetric[K, V](metric))
+}
+
+// NewCacheWithOption 创建一个新的Cache对象，支持指标计数器、写策略等自定义配置
+func NewCacheWithOption[K comparable, V adaptor.Metadata](name string, adaptors []adaptor.Adaptor[K, V], fns ...CacheOptionFunc[K, V]) *Cache[K, V] {
+	opts := DefaultCacheOption[K, V]()
+	for _, fn := range fns {
+		fn(&opts)
 	}
+	cacheInst := &Cache[K, V]{
+		name:        name,
+		adaptors:    adaptors,
+		metric:      opts.Metric,
+		writer:      newWriter(name, opts),
+		hotKey:      opts.HotKey,
+		generation:  opts.Generation,
+		adaptiveTTL: opts.AdaptiveTTL,
+	}
+	cacheInst.deleter = newDoubleDeleter(opts.DoubleDeleteDelay, func(ctx context.Context, keys adaptor.Keys[K]) error {
+		var firstErr error
+		for _, key := range keys {
+			err := cacheInst.evict(ctx, key)
+			firstErr = utils.IfExpr(firstErr == nil, err, firstErr)
+		}
+		return firstErr
+	})
+	return cacheInst
 }
 
 // Get 读取对象
@@ -39,10 +79,27 @@ func (c *Cache[K, V]) Get(ctx context.Context, key K, value V) (bool, error) {
 	ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
 	c.metric.Start(ctx, c.name)
 
-	for _, adap := range c.adaptors {
+	if c.hotKey != nil {
+		c.hotKey.Observe(ctx, fmt.Sprint(key))
+	}
+	if c.adaptiveTTL != nil {
+		c.adaptiveTTL.ObserveRead(fmt.Sprint(key))
+	}
+
+	for idx, adap := range c.adaptors {
 		ok, err := adap.Get(ctx, key, value)
+		if errors.Is(err, adaptor.ErrStale) {
+			// 软过期数据直接返回，同时后台刷新
+			c.refresh(key, value, idx+1)
+			err = nil
+		}
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", key, "event", adaptor.LogEventGet)
+			// 调用方已取消或超时
+			if ctx.Err() != nil {
+				c.metric.Summary(ctx)
+				return false, ctx.Err()
+			}
 		}
 		if ok {
 			c.metric.Summary(ctx)
@@ -53,17 +110,93 @@ func (c *Cache[K, V]) Get(ctx context.Context, key K, value V) (bool, error) {
 	return false, nil
 }
 
+// refresh 从第from个适配器开始后台刷新软过期数据
+// 同一key同一时刻仅启动一个刷新协程，数据源的并发加载由其singleflight合并；缓存场景关闭后不再启动
+func (c *Cache[K, V]) refresh(key K, value V, from int) {
+	if from >= len(c.adaptors) {
+		return
+	}
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.closed {
+		return
+	}
+	if _, loaded := c.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	c.refreshWg.Add(1)
+	go func() {
+		defer c.refreshWg.Done()
+		defer c.refreshing.Delete(key)
+		defer func() {
+			if err := recover(); err != nil {
+				buf := make([]byte, 1<<16)
+				n := runtime.Stack(buf, false)
+				logger.Error(fmt.Sprint(err), "solution", c.name, "key", key, "event", adaptor.LogEventRefresh, "stack", string(buf[:n]))
+			}
+		}()
+
+		ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, utils.UUID())
+		ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
+		c.metric.Start(ctx, c.name)
+		defer c.metric.Summary(ctx)
+
+		newValue := utils.NewOf(value)
+		for _, adap := range c.adaptors[from:] {
+			ok, err := adap.Get(ctx, key, newValue)
+			if errors.Is(err, adaptor.ErrStale) {
+				// 下层数据同样软过期，继续向下刷新
+				continue
+			}
+			if err != nil {
+				logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", key, "event", adaptor.LogEventRefresh)
+			}
+			if ok {
+				return
+			}
+		}
+	}()
+}
+
 // Set 向缓存中写入对象
+// 开启写策略时数据源与各级缓存的写入顺序及失败语义见WritePolicy
 func (c *Cache[K, V]) Set(ctx context.Context, value V) error {
 
 	ctx = context.WithValue(ctx, metrics.MetricsTraceKey, utils.UUID())
 	ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
 	c.metric.Start(ctx, c.name)
 
+	if c.adaptiveTTL != nil {
+		c.adaptiveTTL.ObserveWrite(value.Key())
+	}
+
+	var err error
+	switch c.writer.policy {
+	case WritePolicyThrough, WritePolicyInvalidate:
+		err = c.writer.write(ctx, adaptor.ValueCol[V]{value})
+	case WritePolicyBehind:
+		err = c.writer.enqueueSet(adaptor.ValueCol[V]{value})
+	}
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "value", value, "event", adaptor.LogEventWrite)
+		c.metric.Summary(ctx)
+		return err
+	}
+
+	if c.writer.policy == WritePolicyInvalidate {
+		err = c.evictValue(ctx, value)
+		c.metric.Summary(ctx)
+		return err
+	}
+
 	for _, adap := range c.adaptors {
 		err := adap.Set(ctx, value)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "value", value, "event", adaptor.LogEventSet)
+			// 数据源已接受写入，删除各级缓存中可能不一致的数据
+			if c.writer.policy != WritePolicyNone {
+				c.evictValue(ctx, value)
+			}
 			return err
 		}
 	}
@@ -74,13 +207,79 @@ func (c *Cache[K, V]) Set(ctx context.Context, value V) error {
 }
 
 // Del 删除缓存对象
+// 开启写策略时先删除数据源(异步写回模式下进入队列)，再自底向上删除各级缓存
+// 开启延迟双删时，经过DoubleDeleteDelay后再次删除各级缓存
 func (c *Cache[K, V]) Del(ctx context.Context, key K) error {
-	for _, adap := range c.adaptors {
-		err := adap.Del(ctx, key)
+	if c.adaptiveTTL != nil {
+		c.adaptiveTTL.ObserveWrite(fmt.Sprint(key))
+	}
+
+	var err error
+	switch c.writer.policy {
+	case WritePolicyBehind:
+		err = c.writer.enqueueDel(adaptor.Keys[K]{key})
+	case WritePolicyThrough, WritePolicyInvalidate:
+		err = c.writer.delete(ctx, adaptor.Keys[K]{key})
+	}
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "key", key, "event", adaptor.LogEventWrite)
+		return err
+	}
+
+	if c.writer.policy == WritePolicyNone {
+		for _, adap := range c.adaptors {
+			err = adap.Del(ctx, key)
+			if err != nil {
+				logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", key, "event", adaptor.LogEventDel)
+				break
+			}
+		}
+	} else {
+		err = c.evict(ctx, key)
+	}
+
+	c.deleter.schedule(adaptor.Keys[K]{key})
+	return err
+}
+
+// SyncWrites 立即将异步写回队列中的操作写入数据源并等待完成
+// 返回本次刷新中重试耗尽的第一个错误
+func (c *Cache[K, V]) SyncWrites(ctx context.Context) error {
+	return c.writer.sync(ctx)
+}
+
+// PendingDeletes 待执行及执行中的延迟双删key数量
+func (c *Cache[K, V]) PendingDeletes() int {
+	return c.deleter.count()
+}
+
+// DrainDeletes 立即执行所有待执行的延迟双删并等待完成，用于服务退出前
+func (c *Cache[K, V]) DrainDeletes(ctx context.Context) error {
+	return c.deleter.drain(ctx)
+}
+
+// evict 自底向上删除各级缓存中的key，避免上层缓存从尚未删除的下层缓存回填旧数据
+// 单个适配器删除失败不影响其余适配器，返回第一个错误
+func (c *Cache[K, V]) evict(ctx context.Context, key K) error {
+	var firstErr error
+	for i := len(c.adaptors) - 1; i >= 0; i-- {
+		err := c.adaptors[i].Del(ctx, key)
 		if err != nil {
-			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", key, "event", adaptor.LogEventDel)
-			return err
+			logger.Error(err.Error(), "solution", c.name, "adaptor", c.adaptors[i].Name(), "key", key, "event", adaptor.LogEventEvict)
+			firstErr = utils.IfExpr(firstErr == nil, err, firstErr)
 		}
 	}
-	return nil
+	return firstErr
+}
+
+// evictValue 删除对象对应的各级缓存，并计划延迟双删
+func (c *Cache[K, V]) evictValue(ctx context.Context, value V) error {
+	key, err := utils.ParseKey[K](value.Key())
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "value", value, "event", adaptor.LogEventEvict)
+		return err
+	}
+	err = c.evict(ctx, key)
+	c.deleter.schedule(adaptor.Keys[K]{key})
+	return err
 }
@@ -2,18 +2,29 @@ package multicache
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/coocood/freecache"
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/bloom"
 	"github.com/rumis/multicache/datasource"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
 	"github.com/rumis/multicache/local"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/metrics/prometheus"
 	"github.com/rumis/multicache/remote"
+	"github.com/rumis/multicache/syncer"
 	"github.com/rumis/multicache/tests"
+	"github.com/rumis/multicache/utils"
 )
 
 func TestCacheTwoLevel(t *testing.T) {
@@ -58,6 +69,30 @@ func TestCacheTwoLevel(t *testing.T) {
 	fmt.Println(s2)
 }
 
+func TestCacheCluster(t *testing.T) {
+
+	testRemote := remote.NewRedisAdaptor[string, *tests.Student](tests.NewRedisClusterClient(), nil)
+	testDataSource := DataSourceAdaptorTest(testRemote)
+
+	cacheInst := NewCache[string, *tests.Student]("cache_cluster_test", testRemote, testDataSource)
+
+	for i := 0; i < 2; i++ {
+		var s tests.Student
+		ok, err := cacheInst.Get(context.Background(), "张三", &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || s.Name != "张三" {
+			t.Error("Get Error", i)
+		}
+	}
+
+	err := cacheInst.Del(context.Background(), "张三")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestCacheThreeLevel(t *testing.T) {
 
 	// prometheusGateWayHost := tests.PromGatewayHost()
@@ -203,7 +238,662 @@ func TestCacheSet(t *testing.T) {
 
 }
 
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+
+	var loadCount int32
+	testLocal := local.NewFreeCache[string, *tests.Student](local.FreeCacheClient(), nil,
+		local.WithPrefix("stale_test_"),
+		local.WithTTL(time.Second),
+		local.WithThreshold(0),
+		local.WithStaleTTL(10*time.Second))
+	testDataSource := datasource.NewDataSourceAdaptor[string, *tests.Student](testLocal, func(key string) (*tests.Student, bool, error) {
+		atomic.AddInt32(&loadCount, 1)
+		return &tests.Student{
+			Name: key,
+			Age:  18,
+			Time: time.Now().UnixNano(),
+		}, true, nil
+	})
+
+	cacheInst := NewCache[string, *tests.Student]("cache_stale_test", testLocal, testDataSource)
+
+	// 首次获取数据，穿透至数据源
+	var s tests.Student
+	ok, err := cacheInst.Get(context.Background(), "张三", &s)
+	if err != nil || !ok {
+		t.Fatal("Get Error", err)
+	}
+
+	// 软过期后仍返回旧数据，同时后台刷新
+	time.Sleep(2100 * time.Millisecond)
+	var s1 tests.Student
+	ok, err = cacheInst.Get(context.Background(), "张三", &s1)
+	if err != nil || !ok {
+		t.Fatal("Get Error 1", err)
+	}
+	if s1.Time != s.Time {
+		t.Error("stale value expected")
+	}
+
+	// 后台刷新完成后返回新数据
+	time.Sleep(300 * time.Millisecond)
+	var s2 tests.Student
+	ok, err = cacheInst.Get(context.Background(), "张三", &s2)
+	if err != nil || !ok {
+		t.Fatal("Get Error 2", err)
+	}
+	if s2.Time == s.Time {
+		t.Error("refreshed value expected")
+	}
+	if n := atomic.LoadInt32(&loadCount); n != 2 {
+		t.Errorf("datasource load count = %d, want 2", n)
+	}
+}
+
+func TestCacheBloomGuard(t *testing.T) {
+
+	var loadCount int32
+	filter := bloom.NewMemoryFilter(1000, 0.01)
+	filter.Add(context.Background(), "张三")
+
+	testRemote := RemoteCacheTest(nil)
+	testGuard := bloom.NewGuardAdaptor[string, *tests.Student](filter)
+	testDataSource := datasource.NewDataSourceAdaptor[string, *tests.Student](testRemote, func(key string) (*tests.Student, bool, error) {
+		atomic.AddInt32(&loadCount, 1)
+		return &tests.Student{
+			Name: key,
+			Age:  18,
+		}, true, nil
+	})
+
+	cacheInst := NewCache[string, *tests.Student]("cache_bloom_test", testRemote, testGuard, testDataSource)
+
+	var s tests.Student
+	ok, err := cacheInst.Get(context.Background(), "张三", &s)
+	if err != nil || !ok {
+		t.Fatal("Get Error", err)
+	}
+
+	// 过滤器中不存在的key不会查询数据源
+	var s1 tests.Student
+	ok, err = cacheInst.Get(context.Background(), "李四", &s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("李四 should be rejected")
+	}
+	if n := atomic.LoadInt32(&loadCount); n != 1 {
+		t.Errorf("datasource load count = %d, want 1", n)
+	}
+
+	// 写入后加入过滤器
+	err = cacheInst.Set(context.Background(), &tests.Student{Name: "李四", Age: 19})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, _ := filter.Exists(context.Background(), "李四")
+	if !exists[0] {
+		t.Error("李四 should be added to filter")
+	}
+}
+
+func TestCacheContext(t *testing.T) {
+
+	type traceKey struct{}
+	testRemote := RemoteCacheTest(nil)
+	testDataSource := datasource.NewDataSourceAdaptorWithContext[string, *tests.Student](testRemote, func(ctx context.Context, key string) (*tests.Student, bool, error) {
+		// 链路信息传递至数据源
+		if ctx.Value(traceKey{}) != "trace_test" {
+			return nil, false, errors.New("trace value not found")
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		return &tests.Student{
+			Name: key,
+			Age:  18,
+		}, true, nil
+	})
+
+	cacheInst := NewCache[string, *tests.Student]("cache_context_test", testRemote, testDataSource)
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), traceKey{}, "trace_test"), 100*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	var s tests.Student
+	ok, err := cacheInst.Get(ctx, "张三", &s)
+	if ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get = %v, %v, want deadline exceeded", ok, err)
+	}
+	if time.Since(startTime) > 500*time.Millisecond {
+		t.Error("Get should return when the caller gives up")
+	}
+
+	// 未超时的请求正常返回
+	var s1 tests.Student
+	ok, err = cacheInst.Get(context.WithValue(context.Background(), traceKey{}, "trace_test"), "张三", &s1)
+	if err != nil || !ok {
+		t.Error("Get Error", err)
+	}
+}
+
 // LocalCacheTest 本地缓存
+func TestCacheWritePolicy(t *testing.T) {
+
+	var mu sync.Mutex
+	db := make(map[string]*tests.Student)
+	var writeErr error
+	var writes int32
+	writeFn := func(ctx context.Context, vals adaptor.ValueCol[*tests.Student]) error {
+		atomic.AddInt32(&writes, 1)
+		mu.Lock()
+		defer mu.Unlock()
+		if writeErr != nil {
+			return writeErr
+		}
+		for _, val := range vals {
+			db[val.Key()] = val
+		}
+		return nil
+	}
+	deleteFn := func(ctx context.Context, keys adaptor.Keys[string]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range keys {
+			delete(db, key)
+		}
+		return nil
+	}
+	newCache := func(name string, fns ...CacheOptionFunc[string, *tests.Student]) *Cache[string, *tests.Student] {
+		testLocal := local.NewFreeCache[string, *tests.Student](local.FreeCacheClient(), nil, local.WithPrefix(name+"_"))
+		testRemote := remote.NewRedisAdaptor[string, *tests.Student](tests.NewRedisClient(), testLocal)
+		testDataSource := datasource.NewDataSourceAdaptor[string, *tests.Student](testRemote, func(key string) (*tests.Student, bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			val, ok := db[key]
+			return val, ok, nil
+		})
+		return NewCacheWithOption(name, []adaptor.Adaptor[string, *tests.Student]{testLocal, testRemote, testDataSource}, fns...)
+	}
+	get := func(cacheInst *Cache[string, *tests.Student], key string) int {
+		var s tests.Student
+		ok, err := cacheInst.Get(context.Background(), key, &s)
+		if err != nil || !ok {
+			return -1
+		}
+		return s.Age
+	}
+
+	// 写穿：数据源写入失败时缓存保持不变
+	through := newCache("write_through_test", WithWriteThrough(writeFn, deleteFn))
+	if err := through.Set(context.Background(), &tests.Student{Name: "张三", Age: 18}); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	writeErr = errors.New("db unavailable")
+	if err := through.Set(context.Background(), &tests.Student{Name: "张三", Age: 19}); err == nil {
+		t.Error("Set should fail when the data source rejects the write")
+	}
+	writeErr = nil
+	if age := get(through, "张三"); age != 18 {
+		t.Errorf("age = %d, want 18", age)
+	}
+	if err := through.Del(context.Background(), "张三"); err != nil {
+		t.Fatal("Del Error", err)
+	}
+	if age := get(through, "张三"); age != -1 {
+		t.Errorf("age = %d, want deleted", age)
+	}
+
+	// 旁路缓存：写数据源后删除缓存，读取时重新加载
+	invalidate := newCache("invalidate_test", WithInvalidateOnWrite(writeFn, deleteFn))
+	db["李四"] = &tests.Student{Name: "李四", Age: 18}
+	if age := get(invalidate, "李四"); age != 18 {
+		t.Errorf("age = %d, want 18", age)
+	}
+	if err := invalidate.Set(context.Background(), &tests.Student{Name: "李四", Age: 20}); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	if age := get(invalidate, "李四"); age != 20 {
+		t.Errorf("age = %d, want 20", age)
+	}
+
+	// 异步写回：同一key的多次写入合并为一次数据源写入
+	behind := newCache("write_behind_test", WithWriteBehind(writeFn, deleteFn), WithWriteBehindOption[string, *tests.Student](WriteBehindOption{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		QueueSize:     2,
+		MaxRetry:      1,
+		RetryInterval: 10 * time.Millisecond,
+	}))
+	atomic.StoreInt32(&writes, 0)
+	for i := 0; i < 5; i++ {
+		if err := behind.Set(context.Background(), &tests.Student{Name: "王五", Age: 30 + i}); err != nil {
+			t.Fatal("Set Error", err)
+		}
+	}
+	behind.Set(context.Background(), &tests.Student{Name: "赵六", Age: 40})
+	if err := behind.Set(context.Background(), &tests.Student{Name: "孙七", Age: 50}); !errors.Is(err, ErrWriteQueueFull) {
+		t.Errorf("err = %v, want ErrWriteQueueFull", err)
+	}
+	if age := get(behind, "王五"); age != 34 {
+		t.Errorf("age = %d, want 34", age)
+	}
+	if err := behind.SyncWrites(context.Background()); err != nil {
+		t.Fatal("SyncWrites Error", err)
+	}
+	if n := atomic.LoadInt32(&writes); n != 1 {
+		t.Errorf("writes = %d, want 1", n)
+	}
+	if db["王五"].Age != 34 || db["赵六"].Age != 40 {
+		t.Error("write-behind values were not flushed")
+	}
+
+	// 重试耗尽后调用OnWriteError，由调用方删除缓存中未持久化的数据
+	var failed adaptor.ValueCol[*tests.Student]
+	failing := newCache("write_behind_error_test", WithWriteBehind(writeFn, deleteFn), WithWriteError(func(vals adaptor.ValueCol[*tests.Student], keys adaptor.Keys[string], err error) {
+		failed = vals
+	}), WithWriteBehindOption[string, *tests.Student](WriteBehindOption{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		MaxRetry:      1,
+		RetryInterval: 10 * time.Millisecond,
+	}))
+	mu.Lock()
+	writeErr = errors.New("db unavailable")
+	mu.Unlock()
+	failing.Set(context.Background(), &tests.Student{Name: "周八", Age: 60})
+	if err := failing.SyncWrites(context.Background()); err == nil || len(failed) != 1 || failed[0].Name != "周八" {
+		t.Errorf("err = %v, failed = %v", err, failed)
+	}
+
+	// ctx结束时中断重试，未写入的操作放回队列
+	retrying := newCache("write_behind_cancel_test", WithWriteBehind(writeFn, deleteFn), WithWriteBehindOption[string, *tests.Student](WriteBehindOption{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		MaxRetry:      3,
+		RetryInterval: time.Hour,
+	}))
+	retrying.Set(context.Background(), &tests.Student{Name: "吴九", Age: 70})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	startTime := time.Now()
+	if err := retrying.SyncWrites(ctx); !errors.Is(err, context.DeadlineExceeded) || time.Since(startTime) > time.Second {
+		t.Errorf("err = %v, elapsed = %v", err, time.Since(startTime))
+	}
+	mu.Lock()
+	writeErr = nil
+	mu.Unlock()
+	if err := retrying.Close(context.Background()); err != nil || db["吴九"] == nil {
+		t.Errorf("err = %v, requeued write was not flushed on close", err)
+	}
+
+	// 开启写策略时数据源写入函数为空，回退为仅写缓存
+	if nilWrite := newCache("write_behind_nil_test", WithWriteBehind[string, *tests.Student](nil, deleteFn)); nilWrite.writer.policy != WritePolicyNone {
+		t.Errorf("policy = %s, want %s", nilWrite.writer.policy, WritePolicyNone)
+	}
+}
+
+func TestCacheDoubleDelete(t *testing.T) {
+
+	testLocal := local.NewFreeCache[string, *tests.Student](local.FreeCacheClient(), nil, local.WithPrefix("double_delete_test_"))
+	testRemote := remote.NewRedisAdaptor[string, *tests.Student](tests.NewRedisClient(), testLocal)
+	cacheInst := NewCacheWithOption("cache_double_delete_test", []adaptor.Adaptor[string, *tests.Student]{testLocal, testRemote}, WithDoubleDelete[string, *tests.Student](200*time.Millisecond))
+	// 模拟并发读取在首次删除后回填旧数据
+	refill := func() {
+		NewCache[string, *tests.Student]("cache_double_delete_refill", testRemote).Set(context.Background(), &tests.Student{Name: "张三", Age: 18})
+	}
+	exists := func() bool {
+		var s tests.Student
+		ok, _ := cacheInst.Get(context.Background(), "张三", &s)
+		return ok
+	}
+
+	refill()
+	if err := cacheInst.Del(context.Background(), "张三"); err != nil {
+		t.Fatal("Del Error", err)
+	}
+	refill()
+	if n := cacheInst.PendingDeletes(); n != 1 {
+		t.Errorf("PendingDeletes = %d, want 1", n)
+	}
+	if !exists() {
+		t.Error("stale value should exist before the second delete")
+	}
+	time.Sleep(400 * time.Millisecond)
+	if exists() {
+		t.Error("stale value should be removed by the second delete")
+	}
+	if n := cacheInst.PendingDeletes(); n != 0 {
+		t.Errorf("PendingDeletes = %d, want 0", n)
+	}
+
+	// 退出前立即执行待执行的二次删除
+	cacheInst.Del(context.Background(), "张三")
+	refill()
+	if err := cacheInst.DrainDeletes(context.Background()); err != nil {
+		t.Fatal("DrainDeletes Error", err)
+	}
+	if exists() || cacheInst.PendingDeletes() != 0 {
+		t.Error("DrainDeletes should run pending deletes immediately")
+	}
+}
+
+func TestCacheVersioned(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	testSyncer := syncer.NewRedisSyncer(redisClient, "versioned_test")
+	testLocal := local.NewFreeCache[string, *tests.VersionedStudent](freecache.NewCache(1024*1024), nil, local.WithSyncer(testSyncer))
+	testRemote := remote.NewRedisAdaptor[string, *tests.VersionedStudent](redisClient, testLocal)
+	cacheInst := NewCache[string, *tests.VersionedStudent]("cache_versioned_test", testLocal, testRemote)
+	newStudent := func(age int, version int64) *tests.VersionedStudent {
+		return &tests.VersionedStudent{Student: tests.Student{Name: "张三", Age: age, Time: version}}
+	}
+	get := func(adaps ...adaptor.Adaptor[string, *tests.VersionedStudent]) int {
+		var s tests.VersionedStudent
+		ok, err := NewCache("cache_versioned_get", adaps...).Get(context.Background(), "张三", &s)
+		if err != nil || !ok {
+			return -1
+		}
+		return s.Age
+	}
+
+	if err := cacheInst.Set(context.Background(), newStudent(20, 2)); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	// 旧版本数据的回填被丢弃
+	if err := cacheInst.Set(context.Background(), newStudent(18, 1)); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	if age := get(testLocal); age != 20 {
+		t.Errorf("local age = %d, want 20", age)
+	}
+	if age := get(testRemote); age != 20 {
+		t.Errorf("remote age = %d, want 20", age)
+	}
+	// 新版本数据正常写入
+	if err := cacheInst.Set(context.Background(), newStudent(21, 3)); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	if age := get(testLocal, testRemote); age != 21 {
+		t.Errorf("age = %d, want 21", age)
+	}
+
+	// 本地缓存丢弃旧版本的同步事件
+	time.Sleep(100 * time.Millisecond)
+	emitter := syncer.NewRedisSyncer(redisClient, "versioned_test")
+	for _, s := range []*tests.VersionedStudent{newStudent(19, 1), newStudent(22, 4)} {
+		buf, _ := s.Value()
+		emitter.Emit(context.Background(), &syncer.CacheSyncEvent{
+			EventType: syncer.EventTypeAdd,
+			Key:       "multicache_local_张三",
+			Val:       utils.EncodeVersion(s.Version(), buf),
+			TTL:       time.Minute,
+			Version:   s.Version(),
+		})
+		time.Sleep(100 * time.Millisecond)
+		if age := get(testLocal); age != utils.IfExpr(s.Version() == 4, 22, 21) {
+			t.Errorf("local age = %d after sync of version %d", age, s.Version())
+		}
+	}
+}
+
+func TestCacheLocalStore(t *testing.T) {
+
+	stores := map[string]adaptor.Adaptor[string, *tests.Student]{
+		"admission": local.NewLocalCache[string, *tests.Student](local.NewAdmissionStore(local.MB), nil),
+		"typed":     local.NewTypedLocalCache[string, *tests.Student](local.NewMapStore[*tests.Student](1000), nil),
+		"object":    local.NewTypedLocalCache[string, *tests.Student](local.NewObjectStore(local.WithEviction[*tests.Student](local.EvictionLFU)), nil),
+	}
+	for name, testLocal := range stores {
+		t.Run(name, func(t *testing.T) {
+			cacheInst := NewCache("cache_store_test_"+name, testLocal)
+			s := &tests.Student{Name: "张三", Age: 18}
+			if err := cacheInst.Set(context.Background(), s); err != nil {
+				t.Fatal("Set Error", err)
+			}
+			// 写入后修改调用方对象不影响缓存
+			s.Age = 19
+
+			var s1 tests.Student
+			ok, err := cacheInst.Get(context.Background(), "张三", &s1)
+			if err != nil || !ok || s1.Age != 18 {
+				t.Errorf("Get() = %v, %v, %v", s1, ok, err)
+			}
+			// 修改读取到的对象不影响缓存
+			s1.Age = 20
+			var s2 tests.Student
+			cacheInst.Get(context.Background(), "张三", &s2)
+			if s2.Age != 18 {
+				t.Errorf("age = %d, want 18", s2.Age)
+			}
+		})
+	}
+}
+
+func TestCacheFreeCacheQuota(t *testing.T) {
+
+	testLocal := local.NewFreeCache[string, *tests.Student](nil, nil, local.WithSolutionName("cache_quota_test"), local.WithQuota(local.MB))
+	cacheInst := NewCache[string, *tests.Student]("cache_quota_test", testLocal)
+	if err := cacheInst.Set(context.Background(), &tests.Student{Name: "张三", Age: 18}); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	// 数据写入场景独立的freecache实例
+	stats, ok := local.FreeCacheStatsOf("cache_quota_test")
+	if !ok || stats.EntryCount != 1 || stats.Quota != local.MB {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestCacheHotKey(t *testing.T) {
+
+	var hotKeys []string
+	detector := hotkey.NewDetector(hotkey.WithThreshold(3), hotkey.WithOnHot(func(hk hotkey.HotKey) {
+		hotKeys = append(hotKeys, hk.Key)
+	}))
+	// 本地缓存默认跳过读取，仅热点key从本地缓存读取
+	testLocal := local.NewFreeCache[string, *tests.Student](freecache.NewCache(int(local.MB)), nil,
+		local.WithSkipGet(true), local.WithHotKey(detector, time.Minute))
+	var loads int32
+	testDataSource := datasource.NewDataSourceAdaptor[string, *tests.Student](testLocal, func(key string) (*tests.Student, bool, error) {
+		atomic.AddInt32(&loads, 1)
+		return &tests.Student{Name: key, Age: 18}, true, nil
+	})
+	cacheInst := NewCacheWithOption("cache_hotkey_test", []adaptor.Adaptor[string, *tests.Student]{testLocal, testDataSource}, WithHotKey[string, *tests.Student](detector))
+
+	for i := 0; i < 5; i++ {
+		var s tests.Student
+		if ok, err := cacheInst.Get(context.Background(), "张三", &s); !ok || err != nil {
+			t.Fatal("Get Error", ok, err)
+		}
+		cacheInst.Get(context.Background(), fmt.Sprint("李四", i), &s)
+	}
+	// 前两次读取未达到热点阈值，之后从本地缓存读取
+	if n := atomic.LoadInt32(&loads); n != 2+5 {
+		t.Errorf("loads = %d, want 7", n)
+	}
+	if len(hotKeys) != 1 || hotKeys[0] != "张三" {
+		t.Errorf("hot keys = %v", hotKeys)
+	}
+	if top := detector.TopK(); len(top) != 1 || top[0].Count != 5 {
+		t.Errorf("top = %+v", top)
+	}
+}
+
+func TestCacheWarmup(t *testing.T) {
+
+	testLocal := local.NewFreeCache[string, *tests.Student](freecache.NewCache(int(local.MB)), nil)
+	testRemote := remote.NewRedisAdaptor[string, *tests.Student](tests.NewRedisClient(), testLocal)
+	var loads int32
+	testDataSource := datasource.NewDataSourceAdaptor[string, *tests.Student](testRemote, func(key string) (*tests.Student, bool, error) {
+		atomic.AddInt32(&loads, 1)
+		if key == "bad" {
+			return nil, false, errors.New("db error")
+		}
+		return &tests.Student{Name: key, Age: 18}, true, nil
+	})
+	cacheInst := NewCache[string, *tests.Student]("cache_warmup_test", testLocal, testRemote, testDataSource)
+
+	keys := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		keys = append(keys, fmt.Sprint("student_", i))
+		if i < 10 {
+			NewCache[string, *tests.Student]("cache_warmup_remote", testRemote).Set(context.Background(), &tests.Student{Name: keys[i], Age: 18})
+		}
+	}
+
+	// 仅从分布式缓存预热本地缓存，不访问数据源
+	p, err := cacheInst.Warmup(context.Background(), KeysOf(keys...), WithWarmupCacheOnly(true))
+	if err != nil || p.Total != 20 || p.Loaded != 10 || p.Missing != 10 || atomic.LoadInt32(&loads) != 0 {
+		t.Fatalf("cache only warmup = %+v, %v, loads %d", p, err, loads)
+	}
+	var s tests.Student
+	if ok, _ := NewCache[string, *tests.Student]("cache_warmup_local", testLocal).Get(context.Background(), keys[0], &s); !ok {
+		t.Error("local cache not warmed")
+	}
+
+	// 通过数据源预热，限速每秒100个key
+	ch := make(chan string, len(keys)+1)
+	for _, key := range append(keys, "bad") {
+		ch <- key
+	}
+	close(ch)
+	var failedKeys []string
+	var progress int
+	p, err = cacheInst.Warmup(context.Background(), KeysFromChan(ch), WithWarmupBatchSize(5), WithWarmupRate(100),
+		WithWarmupProgress(func(p WarmupProgress) { progress++ }),
+		WithWarmupError(func(key string, err error) { failedKeys = append(failedKeys, key) }))
+	if err != nil || p.Total != 21 || p.Loaded != 20 || p.Failed != 1 || atomic.LoadInt32(&loads) != 11 {
+		t.Errorf("warmup = %+v, %v, loads %d", p, err, loads)
+	}
+	if len(failedKeys) != 1 || failedKeys[0] != "bad" || progress != 5 {
+		t.Errorf("failed keys = %v, progress = %d", failedKeys, progress)
+	}
+	if p.Elapsed < 180*time.Millisecond {
+		t.Errorf("elapsed = %v, rate limit not applied", p.Elapsed)
+	}
+
+	// 取消预热
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cacheInst.Warmup(ctx, KeysFromChan(make(chan string))); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestCacheClose(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	before := make(map[string]bool)
+	for _, stack := range moduleGoroutines(nil) {
+		id, _, _ := strings.Cut(stack, " [")
+		before[id] = true
+	}
+
+	var mu sync.Mutex
+	db := make(map[string]*tests.Student)
+	writeFn := func(ctx context.Context, vals adaptor.ValueCol[*tests.Student]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, val := range vals {
+			db[val.Key()] = val
+		}
+		return nil
+	}
+	testSyncer := syncer.NewRedisSyncer(redisClient, "cache_close_test")
+	testGeneration := generation.NewGeneration(redisClient, "cache_close_test", generation.WithSyncer(testSyncer))
+	testLocal := local.NewFreeCache[string, *tests.Student](freecache.NewCache(int(local.MB)), nil,
+		local.WithSyncer(testSyncer), local.WithGeneration(testGeneration), local.WithSnapshot(filepath.Join(t.TempDir(), "student.snap"), time.Second))
+	testRemote := remote.NewRedisAdaptor[string, *tests.Student](redisClient, testLocal, remote.WithGeneration(testGeneration))
+	testGuard := bloom.NewGuardAdaptor[string, *tests.Student](bloom.NewMemoryFilter(1000, 0.01),
+		bloom.WithEnumerator(func(ctx context.Context, add func(keys ...string) error) error {
+			return add("张三")
+		}), bloom.WithRebuildInterval(time.Second))
+	testDataSource := datasource.NewDataSourceAdaptor[string, *tests.Student](testRemote, func(key string) (*tests.Student, bool, error) {
+		return &tests.Student{Name: key, Age: 18}, true, nil
+	})
+	cacheInst := NewCacheWithOption("cache_close_test", []adaptor.Adaptor[string, *tests.Student]{testLocal, testRemote, testGuard, testDataSource},
+		WithWriteBehind[string, *tests.Student](writeFn, nil), WithDoubleDelete[string, *tests.Student](time.Hour),
+		WithGeneration[string, *tests.Student](testGeneration))
+
+	var s tests.Student
+	if ok, err := cacheInst.Get(context.Background(), "张三", &s); !ok || err != nil {
+		t.Fatal("Get Error", err)
+	}
+	if err := cacheInst.Flush(context.Background()); err != nil {
+		t.Fatal("Flush Error", err)
+	}
+	if err := cacheInst.Set(context.Background(), &tests.Student{Name: "李四", Age: 19}); err != nil {
+		t.Fatal("Set Error", err)
+	}
+	cacheInst.Del(context.Background(), "张三")
+
+	// 关闭时刷新异步写回队列并执行延迟双删
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cacheInst.Close(ctx); err != nil {
+		t.Fatal("Close Error", err)
+	}
+	if err := cacheInst.Close(ctx); err != nil {
+		t.Fatal("Close twice Error", err)
+	}
+	// 关闭后不再启动后台刷新
+	cacheInst.refresh("王五", &tests.Student{}, 0)
+	if _, ok := cacheInst.refreshing.Load("王五"); ok {
+		t.Error("refresh should not start after Close")
+	}
+	mu.Lock()
+	if _, ok := db["李四"]; !ok {
+		t.Error("write-behind queue not flushed")
+	}
+	mu.Unlock()
+	if n := cacheInst.PendingDeletes(); n != 0 {
+		t.Errorf("pending deletes = %d", n)
+	}
+	// Generation由创建方关闭，缓存场景关闭后仍可使用
+	if n := testGeneration.Current(); n != 1 {
+		t.Errorf("generation = %d, want 1", n)
+	}
+	if err := testGeneration.Close(ctx); err != nil {
+		t.Fatal("Generation Close Error", err)
+	}
+	if err := testGeneration.Close(ctx); err != nil {
+		t.Fatal("Generation Close twice Error", err)
+	}
+	if err := testSyncer.Close(ctx); err != nil {
+		t.Fatal("Syncer Close Error", err)
+	}
+	if err := testSyncer.Subscribe(context.Background(), func(e *syncer.CacheSyncEvent) {}); !errors.Is(err, syncer.ErrSyncerClosed) {
+		t.Errorf("err = %v, want ErrSyncerClosed", err)
+	}
+	redisClient.Close()
+
+	// 全部后台协程退出
+	for i := 0; len(moduleGoroutines(before)) > 0; i++ {
+		if i >= 50 {
+			t.Fatalf("leaked goroutines:\n%s", strings.Join(moduleGoroutines(before), "\n\n"))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// moduleGoroutines 返回由本模块启动且不在before中的协程堆栈，不含测试协程
+func moduleGoroutines(before map[string]bool) []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	var stacks []string
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		id, _, _ := strings.Cut(stack, " [")
+		if !before[id] && strings.Contains(stack, "github.com/rumis/multicache") && !strings.Contains(stack, "testing.tRunner") {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks
+}
+
 func LocalCacheTest() adaptor.Adaptor[string, *tests.Student] {
 	return local.NewFreeCache[string, *tests.Student](local.FreeCacheClient(), nil)
 }
@@ -225,3 +915,194 @@ func DataSourceAdaptorTest(preAdaptor adaptor.Adaptor[string, *tests.Student]) a
 		}, true, nil
 	}, datasource.WithSingleFlightWaitTime(200*time.Millisecond))
 }
+
+func TestCacheInvalidate(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	testSyncer := syncer.NewRedisSyncer(redisClient, "invalidate_test")
+	peerSyncer := syncer.NewRedisSyncer(redisClient, "invalidate_test")
+	defer testSyncer.Close(context.Background())
+	defer peerSyncer.Close(context.Background())
+	testLocal := local.NewFreeCache[string, *tests.TaggedStudent](freecache.NewCache(1024*1024), nil, local.WithSyncer(testSyncer))
+	peerLocal := local.NewFreeCache[string, *tests.TaggedStudent](freecache.NewCache(1024*1024), nil, local.WithSyncer(peerSyncer))
+	testRemote := remote.NewRedisAdaptor[string, *tests.TaggedStudent](redisClient, testLocal)
+	cacheInst := NewCache[string, *tests.TaggedStudent]("cache_invalidate_test", testLocal, testRemote)
+	peerInst := NewCache[string, *tests.TaggedStudent]("cache_invalidate_peer", peerLocal, remote.NewRedisAdaptor[string, *tests.TaggedStudent](redisClient, peerLocal))
+	exists := func(name string, adap adaptor.Adaptor[string, *tests.TaggedStudent]) bool {
+		ok, _ := NewCache("cache_invalidate_get", adap).Get(context.Background(), name, &tests.TaggedStudent{})
+		return ok
+	}
+	names := []string{"张三", "李四", "王五"}
+	for i, name := range names {
+		cacheInst.Set(context.Background(), &tests.TaggedStudent{Student: tests.Student{Name: name, Age: 18 + i/2}})
+		// 其他实例读取后回填本地缓存
+		peerInst.Get(context.Background(), name, &tests.TaggedStudent{})
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range names {
+		if !exists(name, peerLocal) {
+			t.Fatalf("%s not cached by peer", name)
+		}
+	}
+
+	// 按标签删除各级缓存，其他实例的本地缓存通过同步事件删除
+	if err := cacheInst.InvalidateTag(context.Background(), "age:18"); err != nil {
+		t.Fatal("InvalidateTag Error", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, name := range names {
+		want := name == "王五"
+		for _, adap := range []adaptor.Adaptor[string, *tests.TaggedStudent]{testLocal, testRemote, peerLocal} {
+			if exists(name, adap) != want {
+				t.Errorf("%s exists in %s = %v, want %v", name, adap.Name(), !want, want)
+			}
+		}
+	}
+
+	// 按前缀删除
+	if err := cacheInst.InvalidatePrefix(context.Background(), "王"); err != nil {
+		t.Fatal("InvalidatePrefix Error", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, adap := range []adaptor.Adaptor[string, *tests.TaggedStudent]{testLocal, testRemote, peerLocal} {
+		if exists("王五", adap) {
+			t.Errorf("王五 exists in %s after InvalidatePrefix", adap.Name())
+		}
+	}
+}
+
+func TestCacheSyncInvalidateRefill(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	newPod := func(name string) (*local.FreeCache[string, *tests.Student], *Cache[string, *tests.Student]) {
+		podSyncer := syncer.NewRedisSyncer(redisClient, "invalidate_refill_test")
+		t.Cleanup(func() { podSyncer.Close(context.Background()) })
+		podLocal := local.NewFreeCache[string, *tests.Student](freecache.NewCache(1024*1024), nil, local.WithSyncer(podSyncer), local.WithSyncMode(local.SyncInvalidate))
+		return podLocal, NewCache[string, *tests.Student](name, podLocal, remote.NewRedisAdaptor[string, *tests.Student](redisClient, podLocal))
+	}
+	localA, podA := newPod("invalidate_refill_a")
+	localB, podB := newPod("invalidate_refill_b")
+	exists := func(adap adaptor.Adaptor[string, *tests.Student]) bool {
+		ok, _ := NewCache("invalidate_refill_get", adap).Get(context.Background(), "张三", &tests.Student{})
+		return ok
+	}
+
+	podA.Set(context.Background(), &tests.Student{Name: "张三", Age: 18})
+	time.Sleep(100 * time.Millisecond)
+	// 其他实例从远程缓存读取并回填本地缓存，回填不广播失效事件
+	podB.Get(context.Background(), "张三", &tests.Student{})
+	time.Sleep(100 * time.Millisecond)
+	if !exists(localA) || !exists(localB) {
+		t.Fatalf("after refill: a = %v, b = %v, want both cached", exists(localA), exists(localB))
+	}
+
+	// 真实写入仍然失效其他实例的本地缓存
+	podA.Set(context.Background(), &tests.Student{Name: "张三", Age: 19})
+	time.Sleep(100 * time.Millisecond)
+	if !exists(localA) || exists(localB) {
+		t.Errorf("after set: a = %v, b = %v, want only a cached", exists(localA), exists(localB))
+	}
+}
+
+func TestCacheFlush(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	testSyncer := syncer.NewRedisSyncer(redisClient, "flush_test")
+	defer testSyncer.Close(context.Background())
+	testGeneration := generation.NewGeneration(redisClient, "cache_flush_test", generation.WithSyncer(testSyncer))
+	defer testGeneration.Close(context.Background())
+	testLocal := local.NewFreeCache[string, *tests.Student](freecache.NewCache(1024*1024), nil, local.WithGeneration(testGeneration))
+	testRemote := remote.NewRedisAdaptor[string, *tests.Student](redisClient, testLocal, remote.WithGeneration(testGeneration))
+	cacheInst := NewCacheWithOption("cache_flush_test", []adaptor.Adaptor[string, *tests.Student]{testLocal, testRemote}, WithGeneration[string, *tests.Student](testGeneration))
+	exists := func(adaps ...adaptor.Adaptor[string, *tests.Student]) bool {
+		ok, _ := NewCache("cache_flush_get", adaps...).Get(context.Background(), "张三", &tests.Student{})
+		return ok
+	}
+
+	// 代数为0时不修改key
+	cacheInst.Set(context.Background(), &tests.Student{Name: "张三", Age: 18})
+	if redisClient.Exists(context.Background(), "mulcache_local_张三").Val() != 1 {
+		t.Error("generation 0 should not change keys")
+	}
+
+	if err := cacheInst.Flush(context.Background()); err != nil {
+		t.Fatal("Flush Error", err)
+	}
+	if exists(testLocal) || exists(testRemote) {
+		t.Error("entries of the old generation should be unreachable")
+	}
+	cacheInst.Set(context.Background(), &tests.Student{Name: "张三", Age: 19})
+	if !exists(testLocal) || redisClient.Exists(context.Background(), "mulcache_local_g1:张三").Val() != 1 {
+		t.Error("entries should be written with the new generation")
+	}
+
+	// 未配置代数时不支持Flush
+	if err := NewCache[string, *tests.Student]("cache_flush_none", testLocal).Flush(context.Background()); !errors.Is(err, adaptor.ErrNotSupported) {
+		t.Errorf("Flush() = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestCacheTTLPolicy(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	adaptiveTTL := hotkey.NewAdaptiveTTL(hotkey.WithReadBoost(3, 2), hotkey.WithWritePenalty(3, 0.5))
+	testLocal := local.NewFreeCache[string, *tests.ExpiringStudent](freecache.NewCache(1024*1024), nil,
+		local.WithTTL(10*time.Second), local.WithThreshold(time.Second), local.WithTTLPolicy(adaptiveTTL.Policy()))
+	testRemote := remote.NewRedisAdaptor[string, *tests.ExpiringStudent](redisClient, testLocal,
+		remote.WithPrefix("ttl_policy_"), remote.WithTTL(10*time.Second), remote.WithThreshold(1), remote.WithTTLPolicy(adaptiveTTL.Policy()))
+	cacheInst := NewCacheWithOption("cache_ttl_policy_test", []adaptor.Adaptor[string, *tests.ExpiringStudent]{testLocal, testRemote},
+		WithAdaptiveTTL[string, *tests.ExpiringStudent](adaptiveTTL))
+	set := func(name string, age int) {
+		if err := cacheInst.Set(context.Background(), &tests.ExpiringStudent{Student: tests.Student{Name: name, Age: age}}); err != nil {
+			t.Fatal("Set Error", err)
+		}
+	}
+
+	set("张三", 0)
+	// 读取频繁的key延长过期时间
+	set("李四", 0)
+	for i := 0; i < 3; i++ {
+		cacheInst.Get(context.Background(), "李四", &tests.ExpiringStudent{})
+	}
+	set("李四", 0)
+	// 更新频繁的key缩短过期时间
+	for i := 0; i < 3; i++ {
+		set("王五", 0)
+	}
+	// 对象实现Expiring接口时以对象的过期时间为基础
+	set("赵六", 30)
+
+	cases := map[string]time.Duration{
+		"张三": 10 * time.Second,
+		"李四": 20 * time.Second,
+		"王五": 5 * time.Second,
+		"赵六": 30 * time.Second,
+	}
+	for key, want := range cases {
+		if ttl := redisClient.PTTL(context.Background(), "ttl_policy_"+key).Val(); ttl <= want-time.Second || ttl > want {
+			t.Errorf("remote ttl of %s = %v, want %v", key, ttl, want)
+		}
+		if info, err := testLocal.Inspect(context.Background(), key); err != nil || info.TTL < want-time.Second || info.TTL > want {
+			t.Errorf("local ttl of %s = %v, want %v, err %v", key, info.TTL, want, err)
+		}
+	}
+}
+
+func TestCacheTagTTL(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	// 张三的过期时间由策略延长至60秒
+	policy := func(key string, value adaptor.Metadata, ttl time.Duration) time.Duration {
+		return utils.IfExpr(key == "张三", time.Minute, ttl)
+	}
+	testRemote := remote.NewRedisAdaptor[string, *tests.TaggedStudent](redisClient, nil,
+		remote.WithPrefix("tag_ttl_"), remote.WithTTL(10*time.Second), remote.WithThreshold(1), remote.WithTTLPolicy(policy))
+	cacheInst := NewCache[string, *tests.TaggedStudent]("cache_tag_ttl_test", testRemote)
+
+	cacheInst.Set(context.Background(), &tests.TaggedStudent{Student: tests.Student{Name: "张三", Age: 18}})
+	// 过期时间较短的写入不缩短标签集合的过期时间
+	cacheInst.Set(context.Background(), &tests.TaggedStudent{Student: tests.Student{Name: "李四", Age: 18}})
+	if ttl := redisClient.PTTL(context.Background(), "tag_ttl___tag:age:18").Val(); ttl <= 59*time.Second {
+		t.Errorf("tag ttl = %v, want at least 1m", ttl)
+	}
+}
@@ -18,21 +18,37 @@ var ErrNotFound = errors.New("entry not found")
 // DataSourceFunc 数据源构造函数
 type DataSourceFunc[K comparable, V adaptor.Metadata] func(key K) (V, bool, error)
 
+// DataSourceCtxFunc 支持上下文的数据源构造函数，调用方的超时、取消及链路信息通过ctx传递至数据源查询
+type DataSourceCtxFunc[K comparable, V adaptor.Metadata] func(ctx context.Context, key K) (V, bool, error)
+
+// WithContext 转换为支持上下文的数据源构造函数
+func (fn DataSourceFunc[K, V]) WithContext() DataSourceCtxFunc[K, V] {
+	return func(ctx context.Context, key K) (V, bool, error) {
+		return fn(key)
+	}
+}
+
 // 类型检测
 var _ adaptor.Adaptor[string, adaptor.Metadata] = (*DataSourceAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Source = (*DataSourceAdaptor[string, adaptor.Metadata])(nil)
 
 // DataSourceAdaptor 数据源
 type DataSourceAdaptor[K comparable, V adaptor.Metadata] struct {
 	name           string
 	solutionName   string
 	preAdaptor     adaptor.Adaptor[K, V]
-	dataSourceFn   DataSourceFunc[K, V]
+	dataSourceFn   DataSourceCtxFunc[K, V]
 	sg             singleflight.Group
 	sgWaitDuration time.Duration
 }
 
 // NewDataSourceAdaptor 创建一个新的数据源适配器对象
 func NewDataSourceAdaptor[K comparable, V adaptor.Metadata](preAdaptor adaptor.Adaptor[K, V], dsfn DataSourceFunc[K, V], fns ...DataSourceOptionFunc) *DataSourceAdaptor[K, V] {
+	return NewDataSourceAdaptorWithContext(preAdaptor, dsfn.WithContext(), fns...)
+}
+
+// NewDataSourceAdaptorWithContext 创建一个新的数据源适配器对象，数据源构造函数支持上下文
+func NewDataSourceAdaptorWithContext[K comparable, V adaptor.Metadata](preAdaptor adaptor.Adaptor[K, V], dsfn DataSourceCtxFunc[K, V], fns ...DataSourceOptionFunc) *DataSourceAdaptor[K, V] {
 	opts := DefaultDataSourceOption()
 	for _, fn := range fns {
 		fn(&opts)
@@ -55,43 +71,37 @@ func (c *DataSourceAdaptor[K, V]) Name() string {
 func (c *DataSourceAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool, error) {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
 	startTime := time.Now()
-	done := make(chan ValueWithError[V])
+	sgKey := fmt.Sprint(key)
 	// 删除singleflight对象中的缓存
 	defer func() {
-		c.sg.Forget(fmt.Sprint(key))
-	}()
-
-	go func() {
-		defer close(done) // 关闭通道
-		defer func() {
-			err := recover()
-			if err != nil {
-				done <- ValueWithError[V]{Val: value, Err: errors.New(fmt.Sprint(err))}
-			}
-		}()
-
-		v, err, _ := c.sg.Do(fmt.Sprint(key), func() (interface{}, error) {
-			resultVal, ok, err := c.dataSourceFn(key)
-			if err != nil {
-				return nil, err
-			}
-			if !ok {
-				return nil, ErrNotFound
-			}
-			return resultVal, nil
-		})
-		done <- ValueWithError[V]{Val: v.(V), Err: err}
+		c.sg.Forget(sgKey)
 	}()
 
 	var result ValueWithError[V]
-	select {
-	case result = <-done:
-	case <-time.After(c.sgWaitDuration):
-		r1, ok, err := c.dataSourceFn(key)
-		if err == nil && !ok {
-			err = ErrNotFound
+	if c.sgWaitDuration <= 0 {
+		// 未启用singleflight
+		result = c.loadDirect(ctx, key)
+	} else {
+		// singleflight使用发起方的ctx查询数据源
+		ch := c.sg.DoChan(sgKey, func() (interface{}, error) {
+			return c.load(ctx, key)
+		})
+		timer := time.NewTimer(c.sgWaitDuration)
+		defer timer.Stop()
+		select {
+		case r := <-ch:
+			val, _ := r.Val.(V)
+			result = ValueWithError[V]{Val: val, Err: r.Err}
+			// 发起方取消导致查询失败，当前调用方仍有效时重新查询
+			if (errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				result = c.loadDirect(ctx, key)
+			}
+		case <-timer.C:
+			// 等待超时，直接调用数据源
+			result = c.loadDirect(ctx, key)
+		case <-ctx.Done():
+			return false, ctx.Err()
 		}
-		result = ValueWithError[V]{Val: r1, Err: err}
 	}
 
 	if result.Err == ErrNotFound {
@@ -118,7 +128,7 @@ func (c *DataSourceAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool
 	})
 
 	if c.preAdaptor != nil {
-		err := c.preAdaptor.Set(ctx, value)
+		err := c.preAdaptor.Set(adaptor.WithRefill(ctx), value)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", value, "event", adaptor.LogEventRefill)
 		}
@@ -127,6 +137,38 @@ func (c *DataSourceAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool
 	return true, nil
 }
 
+// load 调用数据源查询数据，数据不存在时返回ErrNotFound
+func (c *DataSourceAdaptor[K, V]) load(ctx context.Context, key K) (val V, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+	}()
+	val, ok, err := c.dataSourceFn(ctx, key)
+	if err != nil {
+		return val, err
+	}
+	if !ok {
+		return val, ErrNotFound
+	}
+	return val, nil
+}
+
+// loadDirect 在独立协程中调用数据源，调用方取消时立即返回ctx.Err()
+func (c *DataSourceAdaptor[K, V]) loadDirect(ctx context.Context, key K) ValueWithError[V] {
+	done := make(chan ValueWithError[V], 1)
+	go func() {
+		val, err := c.load(ctx, key)
+		done <- ValueWithError[V]{Val: val, Err: err}
+	}()
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return ValueWithError[V]{Err: ctx.Err()}
+	}
+}
+
 // Set 写入对象
 func (c *DataSourceAdaptor[K, V]) Set(ctx context.Context, value V) error {
 	return nil
@@ -136,3 +178,6 @@ func (c *DataSourceAdaptor[K, V]) Set(ctx context.Context, value V) error {
 func (c *DataSourceAdaptor[K, V]) Del(ctx context.Context, key K) error {
 	return nil
 }
+
+// Source 标记为数据源适配器
+func (c *DataSourceAdaptor[K, V]) Source() {}
@@ -2,39 +2,62 @@ package datasource
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/rumis/multicache/adaptor"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
+	"github.com/rumis/multicache/utils"
 )
 
 // MultiDataSourceFunc 多值数据源构造函数
 type MultiDataSourceFunc[K comparable, V adaptor.Metadata] func(keys adaptor.Keys[K]) (adaptor.Values[K, V], error)
 
+// MultiDataSourceCtxFunc 支持上下文的多值数据源构造函数，调用方的超时、取消及链路信息通过ctx传递至数据源查询
+type MultiDataSourceCtxFunc[K comparable, V adaptor.Metadata] func(ctx context.Context, keys adaptor.Keys[K]) (adaptor.Values[K, V], error)
+
+// WithContext 转换为支持上下文的多值数据源构造函数
+func (fn MultiDataSourceFunc[K, V]) WithContext() MultiDataSourceCtxFunc[K, V] {
+	return func(ctx context.Context, keys adaptor.Keys[K]) (adaptor.Values[K, V], error) {
+		return fn(keys)
+	}
+}
+
 // 类型检测
 var _ adaptor.MultiAdaptor[string, adaptor.Metadata] = (*DataSourceMultiAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Source = (*DataSourceMultiAdaptor[string, adaptor.Metadata])(nil)
 
 // DataSourceMultiAdaptor 多值数据源适配器
 type DataSourceMultiAdaptor[K comparable, V adaptor.Metadata] struct {
 	name         string
 	solutionName string
 	preAdaptor   adaptor.MultiAdaptor[K, V]
-	dataSourceFn MultiDataSourceFunc[K, V]
+	dataSourceFn MultiDataSourceCtxFunc[K, V]
+	// 批量加载请求合并
+	flight         *multiFlight[K, V]
+	sgWaitDuration time.Duration
 }
 
 // NewDataSourceMultiAdaptor 多值数据源适配器
 func NewDataSourceMultiAdaptor[K comparable, V adaptor.Metadata](preAdaptor adaptor.MultiAdaptor[K, V], dsfn MultiDataSourceFunc[K, V], fns ...DataSourceOptionFunc) *DataSourceMultiAdaptor[K, V] {
+	return NewDataSourceMultiAdaptorWithContext(preAdaptor, dsfn.WithContext(), fns...)
+}
+
+// NewDataSourceMultiAdaptorWithContext 多值数据源适配器，数据源构造函数支持上下文
+func NewDataSourceMultiAdaptorWithContext[K comparable, V adaptor.Metadata](preAdaptor adaptor.MultiAdaptor[K, V], dsfn MultiDataSourceCtxFunc[K, V], fns ...DataSourceOptionFunc) *DataSourceMultiAdaptor[K, V] {
 	opts := DefaultDataSourceOption()
 	for _, fn := range fns {
 		fn(&opts)
 	}
 	return &DataSourceMultiAdaptor[K, V]{
-		name:         opts.Name,
-		solutionName: opts.SolutionName,
-		preAdaptor:   preAdaptor,
-		dataSourceFn: dsfn,
+		name:           opts.Name,
+		solutionName:   opts.SolutionName,
+		preAdaptor:     preAdaptor,
+		dataSourceFn:   dsfn,
+		flight:         newMultiFlight[K, V](),
+		sgWaitDuration: opts.SingleFlightWaitTime,
 	}
 }
 
@@ -44,38 +67,184 @@ func (c *DataSourceMultiAdaptor[K, V]) Name() string {
 }
 
 // Get 读取对象
+// 并发请求中已在加载的key等待其加载结果，仅剩余的key调用数据源，等待超时则直接调用数据源加载
 func (c *DataSourceMultiAdaptor[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], vals adaptor.Values[K, V], fn adaptor.NewValueFunc[V]) (adaptor.Keys[K], error) {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
 	startTime := time.Now()
 	hasKeys := make(adaptor.Keys[K], 0)
 	hasValues := make(adaptor.ValueCol[V], 0)
 
-	results, err := c.dataSourceFn(keys)
-	if err != nil {
+	// 由当前请求加载的数据，需回写上层缓存
+	loaded, loadedKeys, err := c.load(ctx, keys, fn)
+	if err != nil && (len(loadedKeys) == 0 || ctx.Err() != nil) {
 		return hasKeys, err
 	}
 
-	for key, val := range results {
+	for _, key := range keys {
+		if _, ok := vals[key]; ok {
+			continue
+		}
+		val, found := loaded[key]
+		if !found {
+			if _, ok := loadedKeys[key]; !ok {
+				// 加载失败
+				continue
+			}
+			// 数据源中不存在的key写入零值占位对象，防止缓存穿透
+			val = fn()
+			placeholder, ok := any(val).(adaptor.Placeholder)
+			if !ok {
+				// 对象不支持零值占位
+				continue
+			}
+			placeholder.Placeholder(fmt.Sprint(key))
+		}
 		vals[key] = val
 		hasKeys = append(hasKeys, key)
-		hasValues = append(hasValues, val)
+		if loadedKeys[key] {
+			hasValues = append(hasValues, val)
+		}
 
 		metric.AddMeta(ctx, metrics.Meta{
 			AdaptorName: c.Name(),
-			Key:         fmt.Sprint(val.Key()),
-			Type:        metrics.Hit,
+			Key:         fmt.Sprint(key),
+			Type:        utils.IfExpr(found, metrics.Hit, metrics.Miss),
 			TrackTime:   time.Since(startTime).Milliseconds(),
 		})
 	}
 
 	if c.preAdaptor != nil && len(hasValues) > 0 {
-		err := c.preAdaptor.Set(ctx, hasValues)
+		err := c.preAdaptor.Set(adaptor.WithRefill(ctx), hasValues)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", hasValues, "event", adaptor.LogEventRefill)
 		}
 	}
 
-	return hasKeys, nil
+	return hasKeys, err
+}
+
+// load 加载数据
+// 返回加载到的数据，以及参与加载的key(值为true表示由当前请求调用数据源加载，false表示等待其他请求的加载结果)
+func (c *DataSourceMultiAdaptor[K, V]) load(ctx context.Context, keys adaptor.Keys[K], fn adaptor.NewValueFunc[V]) (adaptor.Values[K, V], map[K]bool, error) {
+	loaded := make(adaptor.Values[K, V])
+	loadedKeys := make(map[K]bool)
+
+	// 未启用请求合并
+	if c.sgWaitDuration <= 0 {
+		results, err := c.call(ctx, keys, nil)
+		if err != nil {
+			return loaded, loadedKeys, err
+		}
+		for _, key := range keys {
+			loadedKeys[key] = true
+		}
+		return results, loadedKeys, nil
+	}
+
+	ownKeys, owned, waits := c.flight.acquire(keys)
+
+	var firstErr error
+	if len(ownKeys) > 0 {
+		results, err := c.call(ctx, ownKeys, owned)
+		if err != nil {
+			firstErr = err
+		} else {
+			for _, key := range ownKeys {
+				loadedKeys[key] = true
+			}
+			for key, val := range results {
+				loaded[key] = val
+			}
+		}
+	}
+
+	// 等待其他请求的加载结果，超时或失败的key由当前请求直接加载
+	retryKeys := make(adaptor.Keys[K], 0)
+	timer := time.NewTimer(c.sgWaitDuration)
+	defer timer.Stop()
+	expired := false
+	for key, call := range waits {
+		if !expired {
+			select {
+			case <-call.done:
+			case <-timer.C:
+				expired = true
+			case <-ctx.Done():
+				return loaded, loadedKeys, ctx.Err()
+			}
+		}
+		if expired {
+			select {
+			case <-call.done:
+			default:
+				retryKeys = append(retryKeys, key)
+				continue
+			}
+		}
+		if call.err != nil {
+			retryKeys = append(retryKeys, key)
+			continue
+		}
+		loadedKeys[key] = false
+		if !call.found {
+			continue
+		}
+		// 复制共享对象，避免多个请求持有同一对象
+		val := fn()
+		buf, err := call.val.Value()
+		if err == nil {
+			err = val.Decode(buf)
+		}
+		if err != nil {
+			delete(loadedKeys, key)
+			retryKeys = append(retryKeys, key)
+			continue
+		}
+		loaded[key] = val
+	}
+
+	if len(retryKeys) > 0 {
+		results, err := c.call(ctx, retryKeys, nil)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			for _, key := range retryKeys {
+				loadedKeys[key] = true
+			}
+			for key, val := range results {
+				loaded[key] = val
+			}
+		}
+	}
+
+	return loaded, loadedKeys, firstErr
+}
+
+// call 在独立协程中调用数据源，调用方取消时立即返回ctx.Err()
+// owned不为空时，数据源返回后向等待方发布结果
+func (c *DataSourceMultiAdaptor[K, V]) call(ctx context.Context, keys adaptor.Keys[K], owned map[K]*multiCall[V]) (adaptor.Values[K, V], error) {
+	done := make(chan multiResult[K, V], 1)
+	go func() {
+		var result multiResult[K, V]
+		defer func() {
+			if e := recover(); e != nil {
+				result.err = errors.New(fmt.Sprint(e))
+			}
+			if owned != nil {
+				c.flight.release(owned, result.vals, result.err)
+			}
+			done <- result
+		}()
+		result.vals, result.err = c.dataSourceFn(ctx, keys)
+	}()
+	select {
+	case result := <-done:
+		return result.vals, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 // Set 写入对象
@@ -87,3 +256,6 @@ func (c *DataSourceMultiAdaptor[K, V]) Set(ctx context.Context, vals adaptor.Val
 func (c *DataSourceMultiAdaptor[K, V]) Del(ctx context.Context, keys adaptor.Keys[K]) error {
 	return nil
 }
+
+// Source 标记为数据源适配器
+func (c *DataSourceMultiAdaptor[K, V]) Source() {}
@@ -0,0 +1,73 @@
+package datasource
+
+import (
+	"sync"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// multiCall 批量加载中单个key的加载结果
+type multiCall[V adaptor.Metadata] struct {
+	done  chan struct{}
+	val   V
+	found bool
+	err   error
+}
+
+// multiResult 批量加载结果
+type multiResult[K comparable, V adaptor.Metadata] struct {
+	vals adaptor.Values[K, V]
+	err  error
+}
+
+// multiFlight 批量加载请求合并
+// 按key登记正在加载中的请求，并发请求中已在加载的key直接等待其结果
+type multiFlight[K comparable, V adaptor.Metadata] struct {
+	lock  sync.Mutex
+	calls map[K]*multiCall[V]
+}
+
+// newMultiFlight 创建批量加载请求合并对象
+func newMultiFlight[K comparable, V adaptor.Metadata]() *multiFlight[K, V] {
+	return &multiFlight[K, V]{
+		calls: make(map[K]*multiCall[V]),
+	}
+}
+
+// acquire 登记keys
+// 返回需由当前调用方加载的key及其登记对象，以及需等待其他调用方加载结果的key
+func (g *multiFlight[K, V]) acquire(keys adaptor.Keys[K]) (adaptor.Keys[K], map[K]*multiCall[V], map[K]*multiCall[V]) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	ownKeys := make(adaptor.Keys[K], 0, len(keys))
+	owned := make(map[K]*multiCall[V])
+	waits := make(map[K]*multiCall[V])
+	for _, key := range keys {
+		if _, ok := owned[key]; ok {
+			continue
+		}
+		if call, ok := g.calls[key]; ok {
+			waits[key] = call
+			continue
+		}
+		call := &multiCall[V]{done: make(chan struct{})}
+		g.calls[key] = call
+		owned[key] = call
+		ownKeys = append(ownKeys, key)
+	}
+	return ownKeys, owned, waits
+}
+
+// release 发布加载结果并移除登记
+func (g *multiFlight[K, V]) release(owned map[K]*multiCall[V], results adaptor.Values[K, V], err error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for key, call := range owned {
+		call.err = err
+		if err == nil {
+			call.val, call.found = results[key]
+		}
+		delete(g.calls, key)
+		close(call.done)
+	}
+}
@@ -0,0 +1,13 @@
+package datasource
+
+import (
+	"context"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// DataSourceWriteFunc 数据源写入函数，将对象批量持久化至数据源
+type DataSourceWriteFunc[V adaptor.Metadata] func(ctx context.Context, vals adaptor.ValueCol[V]) error
+
+// DataSourceDeleteFunc 数据源删除函数，从数据源中批量删除对象
+type DataSourceDeleteFunc[K comparable] func(ctx context.Context, keys adaptor.Keys[K]) error
@@ -0,0 +1,110 @@
+package multicache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// delayedDelete 待执行的延迟删除
+type delayedDelete[K comparable] struct {
+	id      uint64
+	keys    adaptor.Keys[K]
+	timer   *time.Timer
+	started bool
+	// 删除完成后关闭
+	done chan struct{}
+}
+
+// doubleDeleter 延迟双删
+// 首次删除后经过delay再次删除各级缓存，清除并发读取在数据源提交与首次删除之间回填的旧数据
+type doubleDeleter[K comparable] struct {
+	delay time.Duration
+	del   func(ctx context.Context, keys adaptor.Keys[K]) error
+
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]*delayedDelete[K]
+}
+
+// newDoubleDeleter 创建延迟双删对象，delay不大于零时不执行二次删除
+func newDoubleDeleter[K comparable](delay time.Duration, del func(ctx context.Context, keys adaptor.Keys[K]) error) *doubleDeleter[K] {
+	return &doubleDeleter[K]{
+		delay:   delay,
+		del:     del,
+		pending: make(map[uint64]*delayedDelete[K]),
+	}
+}
+
+// schedule 计划二次删除
+func (d *doubleDeleter[K]) schedule(keys adaptor.Keys[K]) {
+	if d.delay <= 0 || len(keys) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seq++
+	dd := &delayedDelete[K]{
+		id:   d.seq,
+		keys: keys,
+		done: make(chan struct{}),
+	}
+	dd.timer = time.AfterFunc(d.delay, func() {
+		d.run(dd)
+	})
+	d.pending[dd.id] = dd
+}
+
+// run 执行二次删除，同一计划仅执行一次
+func (d *doubleDeleter[K]) run(dd *delayedDelete[K]) {
+	d.mu.Lock()
+	if dd.started {
+		d.mu.Unlock()
+		return
+	}
+	dd.started = true
+	d.mu.Unlock()
+
+	d.del(context.Background(), dd.keys)
+
+	d.mu.Lock()
+	delete(d.pending, dd.id)
+	d.mu.Unlock()
+	close(dd.done)
+}
+
+// count 待执行及执行中的二次删除key数量
+func (d *doubleDeleter[K]) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, dd := range d.pending {
+		n += len(dd.keys)
+	}
+	return n
+}
+
+// drain 立即执行所有待执行的二次删除，并等待执行中的二次删除完成
+func (d *doubleDeleter[K]) drain(ctx context.Context) error {
+	d.mu.Lock()
+	entries := make([]*delayedDelete[K], 0, len(d.pending))
+	for _, dd := range d.pending {
+		entries = append(entries, dd)
+	}
+	d.mu.Unlock()
+
+	for _, dd := range entries {
+		dd.timer.Stop()
+		go d.run(dd)
+	}
+	for _, dd := range entries {
+		select {
+		case <-dd.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
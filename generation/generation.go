@@ -0,0 +1,198 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/syncer"
+)
+
+// KeyPrefix 代数在Redis中的key前缀，key为KeyPrefix+名称
+const KeyPrefix = "multicache_generation_"
+
+// bumpSrc 代数加一，Redis中的代数不大于本地代数时(如Redis数据丢失)从本地代数加一，保证代数单调递增
+// KEYS[1] 代数key ARGV[1] 本地代数
+const bumpSrc = `
+local n = redis.call('INCR', KEYS[1])
+local cur = tonumber(ARGV[1])
+if n <= cur then
+	n = cur + 1
+	redis.call('SET', KEYS[1], n)
+end
+return n
+`
+
+var bumpScript = redis.NewScript(bumpSrc)
+
+// Generation 缓存代数，存储于Redis，本地缓存并定期刷新
+// 配置了代数的适配器将代数拼接至key前缀，代数加一后旧代数的数据均无法访问并随过期时间淘汰，实现整个缓存场景的快速清空
+// 可被同一缓存场景的多个适配器共享，代数为0时不修改key，开启前已写入的数据仍可访问
+type Generation struct {
+	client redis.UniversalClient
+	key    string
+	opts   GenerationOption
+	// 当前代数
+	current uint64
+	// 立即刷新信号
+	trigger chan struct{}
+	// 停止刷新协程
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewGeneration 创建缓存代数，name通常为缓存场景名称
+// 创建时从Redis读取一次代数，读取失败时从0开始并在刷新间隔后重试
+func NewGeneration(client redis.UniversalClient, name string, fns ...GenerationOptionFunc) *Generation {
+	opts := DefaultGenerationOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = DefaultGenerationOption().RefreshInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &Generation{
+		client:  client,
+		key:     KeyPrefix + name,
+		opts:    opts,
+		trigger: make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	loadCtx, loadCancel := context.WithTimeout(ctx, opts.RefreshInterval)
+	if err := g.Refresh(loadCtx); err != nil {
+		logger.Error(err.Error(), "generation", g.key, "event", adaptor.LogEventGeneration)
+	}
+	loadCancel()
+
+	// 订阅代数变更
+	if opts.Syncer != nil {
+		if err := opts.Syncer.Subscribe(ctx, g.sync); err != nil {
+			logger.Error(err.Error(), "generation", g.key, "event", adaptor.LogEventSync)
+		}
+	}
+	go g.refreshLoop(ctx)
+	return g
+}
+
+// Key 代数在Redis中的key
+func (g *Generation) Key() string {
+	return g.key
+}
+
+// Current 当前代数，g为nil时返回0
+func (g *Generation) Current() uint64 {
+	if g == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&g.current)
+}
+
+// Prefix 拼接至适配器key前缀之后的代数前缀，g为nil或代数为0时返回空字符串
+func (g *Generation) Prefix() string {
+	n := g.Current()
+	if n == 0 {
+		return ""
+	}
+	return "g" + strconv.FormatUint(n, 10) + ":"
+}
+
+// Bump 代数加一并广播，返回新的代数
+func (g *Generation) Bump(ctx context.Context) (uint64, error) {
+	n, err := bumpScript.Run(ctx, g.client, []string{g.key}, g.Current()).Uint64()
+	if err != nil {
+		return 0, err
+	}
+	g.advance(n)
+	if g.opts.Syncer != nil {
+		e := &syncer.CacheSyncEvent{EventType: syncer.EventTypeGeneration, Key: g.key, Version: int64(n)}
+		if err := g.opts.Syncer.Emit(ctx, e); err != nil {
+			logger.Error(err.Error(), "generation", g.key, "value", n, "event", adaptor.LogEventSync)
+		}
+	}
+	return n, nil
+}
+
+// Refresh 从Redis读取代数，代数仅增不减
+func (g *Generation) Refresh(ctx context.Context) error {
+	n, err := g.client.Get(ctx, g.key).Uint64()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	g.advance(n)
+	return nil
+}
+
+// Close 停止定期刷新并退订代数变更，可重复调用，同步器需由创建方单独关闭
+func (g *Generation) Close(ctx context.Context) error {
+	g.closeOnce.Do(g.cancel)
+	select {
+	case <-g.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// advance 代数增大至n，返回代数是否变化
+func (g *Generation) advance(n uint64) bool {
+	for {
+		cur := atomic.LoadUint64(&g.current)
+		if n <= cur {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&g.current, cur, n) {
+			logger.Info("cache generation changed", "generation", g.key, "from", cur, "to", n, "event", adaptor.LogEventGeneration)
+			return true
+		}
+	}
+}
+
+// refreshLoop 定期及收到刷新信号时从Redis刷新代数
+func (g *Generation) refreshLoop(ctx context.Context) {
+	defer close(g.done)
+	ticker := time.NewTicker(g.opts.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-g.trigger:
+		}
+		refreshCtx, cancel := context.WithTimeout(ctx, g.opts.RefreshInterval)
+		if err := g.Refresh(refreshCtx); err != nil && ctx.Err() == nil {
+			logger.Error(err.Error(), "generation", g.key, "event", adaptor.LogEventGeneration)
+		}
+		cancel()
+	}
+}
+
+// sync 处理代数变更事件，订阅断开重连或事件缺失时立即刷新
+func (g *Generation) sync(e *syncer.CacheSyncEvent) {
+	switch e.EventType {
+	case syncer.EventTypeGeneration:
+		if e.Key == g.key && e.Version > 0 {
+			g.advance(uint64(e.Version))
+		}
+	case syncer.EventTypeFlush:
+		if e.Key == "" {
+			select {
+			case g.trigger <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
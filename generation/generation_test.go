@@ -0,0 +1,61 @@
+package generation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rumis/multicache/syncer"
+	"github.com/rumis/multicache/tests"
+)
+
+func TestGeneration(t *testing.T) {
+
+	redisClient := tests.NewRedisClient()
+	s1 := syncer.NewRedisSyncer(redisClient, "generation_test")
+	s2 := syncer.NewRedisSyncer(redisClient, "generation_test")
+	defer s1.Close(context.Background())
+	defer s2.Close(context.Background())
+
+	g1 := NewGeneration(redisClient, "student", WithSyncer(s1), WithRefreshInterval(time.Hour))
+	g2 := NewGeneration(redisClient, "student", WithSyncer(s2), WithRefreshInterval(time.Hour))
+	g3 := NewGeneration(redisClient, "student", WithRefreshInterval(100*time.Millisecond))
+	defer g1.Close(context.Background())
+	defer g2.Close(context.Background())
+	defer g3.Close(context.Background())
+	if g1.Current() != 0 || g1.Prefix() != "" {
+		t.Errorf("initial generation = %d, prefix = %q", g1.Current(), g1.Prefix())
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	n, err := g1.Bump(context.Background())
+	if err != nil || n != 1 || g1.Prefix() != "g1:" {
+		t.Fatalf("Bump() = %d, %v, prefix = %q", n, err, g1.Prefix())
+	}
+	// 其他实例通过同步事件立即感知，未配置同步器的实例在刷新间隔内感知
+	time.Sleep(50 * time.Millisecond)
+	if g2.Current() != 1 {
+		t.Errorf("synced generation = %d, want 1", g2.Current())
+	}
+	time.Sleep(200 * time.Millisecond)
+	if g3.Current() != 1 {
+		t.Errorf("refreshed generation = %d, want 1", g3.Current())
+	}
+
+	// Redis数据丢失后代数仍单调递增
+	redisClient.Del(context.Background(), g1.Key())
+	if n, err := g1.Bump(context.Background()); err != nil || n != 2 {
+		t.Errorf("Bump() after data loss = %d, %v", n, err)
+	}
+	if g4 := NewGeneration(redisClient, "student"); g4.Current() != 2 {
+		t.Errorf("loaded generation = %d, want 2", g4.Current())
+	} else {
+		g4.Close(context.Background())
+	}
+
+	// nil代数不修改key
+	var nilGeneration *Generation
+	if nilGeneration.Prefix() != "" {
+		t.Error("nil generation prefix should be empty")
+	}
+}
@@ -0,0 +1,39 @@
+package generation
+
+import (
+	"time"
+
+	"github.com/rumis/multicache/syncer"
+)
+
+// GenerationOption 缓存代数配置选项
+type GenerationOption struct {
+	// 从Redis刷新代数的间隔
+	RefreshInterval time.Duration
+	// 广播代数变更的同步器，为空时其他实例在刷新间隔内感知代数变更
+	Syncer syncer.Syncer
+}
+
+// GenerationOptionFunc 缓存代数配置函数
+type GenerationOptionFunc func(*GenerationOption)
+
+// DefaultGenerationOption 默认缓存代数配置
+func DefaultGenerationOption() GenerationOption {
+	return GenerationOption{
+		RefreshInterval: time.Second,
+	}
+}
+
+// WithRefreshInterval 设置从Redis刷新代数的间隔
+func WithRefreshInterval(interval time.Duration) GenerationOptionFunc {
+	return func(option *GenerationOption) {
+		option.RefreshInterval = interval
+	}
+}
+
+// WithSyncer 设置广播代数变更的同步器
+func WithSyncer(s syncer.Syncer) GenerationOptionFunc {
+	return func(option *GenerationOption) {
+		option.Syncer = s
+	}
+}
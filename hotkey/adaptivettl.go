@@ -0,0 +1,94 @@
+package hotkey
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// AdaptiveTTL 自适应过期时间策略
+// 按滑动窗口分别统计key的读取及更新次数，更新频繁的key缩短过期时间以减少读取到旧数据的时长，读取频繁的key延长过期时间以提高命中率
+// 次数由缓存场景通过WithAdaptiveTTL记录，各级适配器通过WithTTLPolicy(a.Policy())应用，可被多个缓存场景共享
+type AdaptiveTTL struct {
+	opts AdaptiveTTLOption
+	seed maphash.Seed
+
+	mu     sync.Mutex
+	reads  *slidingSketch
+	writes *slidingSketch
+}
+
+// NewAdaptiveTTL 创建自适应过期时间策略
+func NewAdaptiveTTL(fns ...AdaptiveTTLOptionFunc) *AdaptiveTTL {
+	opts := DefaultAdaptiveTTLOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	if opts.Slots <= 0 {
+		opts.Slots = 1
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultAdaptiveTTLOption().Window
+	}
+	return &AdaptiveTTL{
+		opts:   opts,
+		seed:   maphash.MakeSeed(),
+		reads:  newSlidingSketch(opts.Window, opts.Slots, opts.Width),
+		writes: newSlidingSketch(opts.Window, opts.Slots, opts.Width),
+	}
+}
+
+// ObserveRead 记录一次key读取
+func (a *AdaptiveTTL) ObserveRead(key string) {
+	a.observe(a.reads, key)
+}
+
+// ObserveWrite 记录一次key更新(写入或删除)
+func (a *AdaptiveTTL) ObserveWrite(key string) {
+	a.observe(a.writes, key)
+}
+
+// TTL 按key在窗口内的读取及更新次数调整过期时间
+func (a *AdaptiveTTL) TTL(key string, ttl time.Duration) time.Duration {
+	h := hashKey(a.seed, key)
+	now := time.Now()
+	a.mu.Lock()
+	a.reads.advance(now)
+	a.writes.advance(now)
+	reads, writes := a.reads.estimate(h), a.writes.estimate(h)
+	a.mu.Unlock()
+
+	switch {
+	case a.opts.WriteThreshold > 0 && writes >= a.opts.WriteThreshold:
+		ttl = time.Duration(float64(ttl) * a.opts.WriteFactor)
+	case a.opts.ReadThreshold > 0 && reads >= a.opts.ReadThreshold:
+		ttl = time.Duration(float64(ttl) * a.opts.ReadFactor)
+	default:
+		return ttl
+	}
+	if a.opts.MinTTL > 0 && ttl < a.opts.MinTTL {
+		ttl = a.opts.MinTTL
+	}
+	if a.opts.MaxTTL > 0 && ttl > a.opts.MaxTTL {
+		ttl = a.opts.MaxTTL
+	}
+	return ttl
+}
+
+// Policy 适配器使用的过期时间策略
+func (a *AdaptiveTTL) Policy() adaptor.TTLPolicy {
+	return func(key string, value adaptor.Metadata, ttl time.Duration) time.Duration {
+		return a.TTL(key, ttl)
+	}
+}
+
+// observe 在窗口中记录一次key访问
+func (a *AdaptiveTTL) observe(w *slidingSketch, key string) {
+	h := hashKey(a.seed, key)
+	a.mu.Lock()
+	w.advance(time.Now())
+	w.increment(h)
+	a.mu.Unlock()
+}
@@ -0,0 +1,231 @@
+package hotkey
+
+import (
+	"container/heap"
+	"context"
+	"hash/maphash"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rumis/multicache/metrics"
+)
+
+// Detector 热点key探测器
+// 按时间片维护count-min sketch组成滑动窗口，窗口内访问次数达到阈值的key进入top-K小顶堆
+// 时间片在访问时惰性滑动，无后台协程，可被多个缓存场景共享
+// 热点key集合变化时发布只读快照，IsHot及采样跳过的Observe无需加锁
+type Detector struct {
+	opts DetectorOption
+	seed maphash.Seed
+
+	mu     sync.Mutex
+	window *slidingSketch
+	top    topHeap
+	index  map[string]*entry
+	// 热点key集合的只读快照
+	hot atomic.Pointer[hotSet]
+}
+
+// hotSet 热点key集合快照，until为当前时间片的结束时间，之后需滑动窗口并重新发布
+type hotSet struct {
+	keys  map[string]struct{}
+	until time.Time
+}
+
+// NewDetector 创建热点key探测器
+func NewDetector(fns ...DetectorOptionFunc) *Detector {
+	opts := DefaultDetectorOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	if opts.Slots <= 0 {
+		opts.Slots = 1
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultDetectorOption().Window
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 1
+	}
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+	d := &Detector{
+		opts:   opts,
+		seed:   maphash.MakeSeed(),
+		window: newSlidingSketch(opts.Window, opts.Slots, opts.Width),
+		index:  make(map[string]*entry),
+	}
+	d.publish()
+	return d
+}
+
+// Name 探测器名称
+func (d *Detector) Name() string {
+	return d.opts.Name
+}
+
+// Observe 记录一次key访问，返回key当前是否为热点
+// key首次被识别为热点时执行回调，ctx中包含指标计数器时上报Hot事件
+func (d *Detector) Observe(ctx context.Context, key string) bool {
+	if d.opts.SampleRate < 1 && rand.Float64() >= d.opts.SampleRate {
+		return d.IsHot(key)
+	}
+
+	now := time.Now()
+	h := hashKey(d.seed, key)
+
+	d.mu.Lock()
+	d.advance(now)
+	d.window.increment(h)
+	count := d.estimate(h)
+	hot, promoted := d.offer(key, h, count, now)
+	d.mu.Unlock()
+
+	if !promoted {
+		return hot
+	}
+	if d.opts.OnHot != nil {
+		d.opts.OnHot(HotKey{Key: key, Count: count, Time: now})
+	}
+	if metric, ok := ctx.Value(metrics.MetricsClient).(metrics.Metrics); ok {
+		metric.AddMeta(ctx, metrics.Meta{
+			AdaptorName: d.Name(),
+			Key:         key,
+			Type:        metrics.Hot,
+		})
+	}
+	return true
+}
+
+// IsHot 判断key当前是否为热点，读取热点key集合快照，仅在快照所属时间片结束后加锁滑动窗口
+func (d *Detector) IsHot(key string) bool {
+	set := d.hot.Load()
+	if now := time.Now(); !now.Before(set.until) {
+		d.mu.Lock()
+		d.advance(now)
+		set = d.hot.Load()
+		d.mu.Unlock()
+	}
+	_, ok := set.keys[key]
+	return ok
+}
+
+// TopK 当前窗口内的热点key，按访问次数降序排列
+func (d *Detector) TopK() []HotKey {
+	d.mu.Lock()
+	d.advance(time.Now())
+	hks := make([]HotKey, 0, len(d.top))
+	for _, e := range d.top {
+		hks = append(hks, HotKey{Key: e.key, Count: e.count, Time: e.since})
+	}
+	d.mu.Unlock()
+
+	sort.Slice(hks, func(i, j int) bool {
+		return hks[i].Count > hks[j].Count
+	})
+	return hks
+}
+
+// estimate 估计key在滑动窗口内的访问次数，按采样率折算
+func (d *Detector) estimate(h uint64) uint64 {
+	return uint64(float64(d.window.estimate(h)) / d.opts.SampleRate)
+}
+
+// offer 更新key在top-K中的访问次数，返回key是否为热点以及是否新晋为热点
+func (d *Detector) offer(key string, h uint64, count uint64, now time.Time) (bool, bool) {
+	if e, ok := d.index[key]; ok {
+		e.count = count
+		heap.Fix(&d.top, e.idx)
+		return true, false
+	}
+	if count < d.opts.Threshold {
+		return false, false
+	}
+	if len(d.top) >= d.opts.TopK {
+		if count <= d.top[0].count {
+			return false, false
+		}
+		evicted := heap.Pop(&d.top).(*entry)
+		delete(d.index, evicted.key)
+	}
+	e := &entry{key: key, hash: h, count: count, since: now}
+	heap.Push(&d.top, e)
+	d.index[key] = e
+	d.publish()
+	return true, true
+}
+
+// publish 发布热点key集合快照，调用方需持有锁
+func (d *Detector) publish() {
+	keys := make(map[string]struct{}, len(d.index))
+	for key := range d.index {
+		keys[key] = struct{}{}
+	}
+	d.hot.Store(&hotSet{keys: keys, until: d.window.slotStart.Add(d.window.slotSize)})
+}
+
+// advance 滑动窗口至now所在时间片，清空过期时间片并重新计算热点key的访问次数
+func (d *Detector) advance(now time.Time) {
+	if !d.window.advance(now) {
+		return
+	}
+
+	// 访问次数回落至阈值以下的key移出热点
+	kept := d.top[:0]
+	for _, e := range d.top {
+		e.count = d.estimate(e.hash)
+		if e.count < d.opts.Threshold {
+			delete(d.index, e.key)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	for i := len(kept); i < len(d.top); i++ {
+		d.top[i] = nil
+	}
+	d.top = kept
+	for i, e := range d.top {
+		e.idx = i
+	}
+	heap.Init(&d.top)
+	d.publish()
+}
+
+// entry top-K中的热点key
+type entry struct {
+	key   string
+	hash  uint64
+	count uint64
+	since time.Time
+	idx   int
+}
+
+// topHeap 按访问次数排序的小顶堆
+type topHeap []*entry
+
+func (h topHeap) Len() int           { return len(h) }
+func (h topHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h topHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].idx = i
+	h[j].idx = j
+}
+
+func (h *topHeap) Push(x any) {
+	e := x.(*entry)
+	e.idx = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *topHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
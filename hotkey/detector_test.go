@@ -0,0 +1,91 @@
+package hotkey
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDetector(t *testing.T) {
+
+	var hot []HotKey
+	d := NewDetector(WithThreshold(10), WithTopK(2), WithOnHot(func(hk HotKey) {
+		hot = append(hot, hk)
+	}))
+	for i := 0; i < 100; i++ {
+		d.Observe(context.Background(), fmt.Sprint("cold_", i))
+	}
+	for i := 0; i < 20; i++ {
+		d.Observe(context.Background(), "a")
+		if i < 15 {
+			d.Observe(context.Background(), "b")
+		}
+		if i < 12 {
+			d.Observe(context.Background(), "c")
+		}
+	}
+	// a、b先达到阈值，c访问次数低于堆顶无法进入top-K
+	if len(hot) != 2 || hot[0].Key != "a" || hot[1].Key != "b" {
+		t.Errorf("hot = %+v", hot)
+	}
+	top := d.TopK()
+	if len(top) != 2 || top[0].Key != "a" || top[0].Count != 20 || top[1].Count != 15 {
+		t.Errorf("top = %+v", top)
+	}
+	if !d.IsHot("a") || d.IsHot("c") || d.IsHot("cold_1") {
+		t.Error("IsHot mismatch")
+	}
+}
+
+func TestDetectorWindow(t *testing.T) {
+
+	d := NewDetector(WithThreshold(5), WithWindow(200*time.Millisecond, 4))
+	for i := 0; i < 5; i++ {
+		d.Observe(context.Background(), "a")
+	}
+	if !d.IsHot("a") {
+		t.Fatal("a should be hot")
+	}
+	// 窗口滑过后访问次数清零，key移出热点
+	time.Sleep(250 * time.Millisecond)
+	if d.IsHot("a") {
+		t.Error("a should be cold")
+	}
+	if top := d.TopK(); len(top) != 0 {
+		t.Errorf("top = %+v", top)
+	}
+}
+
+func TestAdaptiveTTL(t *testing.T) {
+
+	a := NewAdaptiveTTL(WithReadBoost(3, 2), WithWritePenalty(2, 0.5), WithTTLBounds(time.Second, 15*time.Second), WithAdaptiveWindow(200*time.Millisecond, 4))
+	for i := 0; i < 3; i++ {
+		a.ObserveRead("read")
+		a.ObserveRead("both")
+	}
+	for i := 0; i < 2; i++ {
+		a.ObserveWrite("write")
+		a.ObserveWrite("both")
+	}
+	policy := a.Policy()
+	cases := map[string]time.Duration{
+		"cold":  10 * time.Second,
+		"read":  15 * time.Second, // 延长至上限
+		"write": 5 * time.Second,
+		"both":  5 * time.Second, // 缩短优先于延长
+	}
+	for key, want := range cases {
+		if got := policy(key, nil, 10*time.Second); got != want {
+			t.Errorf("TTL(%s) = %v, want %v", key, got, want)
+		}
+	}
+	if got := a.TTL("write", time.Second); got != time.Second {
+		t.Errorf("TTL below MinTTL = %v", got)
+	}
+	// 窗口滑过后恢复基础过期时间
+	time.Sleep(250 * time.Millisecond)
+	if got := a.TTL("read", 10*time.Second); got != 10*time.Second {
+		t.Errorf("TTL after window = %v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package hotkey
+
+import "time"
+
+// HotKey 热点key信息
+type HotKey struct {
+	Key string
+	// 滑动窗口内估计的访问次数
+	Count uint64
+	// 被识别为热点的时间
+	Time time.Time
+}
+
+// HotKeyFunc 发现热点key时的回调函数，在Get调用方协程中同步执行，不应阻塞
+type HotKeyFunc func(hk HotKey)
+
+// DetectorOption 热点探测配置选项
+type DetectorOption struct {
+	// 探测器名称，作为指标中的适配器名称
+	Name string
+	// 滑动窗口时长
+	Window time.Duration
+	// 窗口划分的时间片数量，时间片越多窗口滑动越平滑
+	Slots int
+	// 每个时间片count-min sketch每行的计数器数量
+	Width int
+	// 最多保留的热点key数量
+	TopK int
+	// 窗口内访问次数达到该值的key视为热点
+	Threshold uint64
+	// 采样率，取值(0,1]，访问次数按采样率折算
+	SampleRate float64
+	// 发现热点key时的回调
+	OnHot HotKeyFunc
+}
+
+// DetectorOptionFunc 热点探测配置函数
+type DetectorOptionFunc func(*DetectorOption)
+
+// DefaultDetectorOption 默认热点探测配置
+func DefaultDetectorOption() DetectorOption {
+	return DetectorOption{
+		Name:       "hotkey",
+		Window:     10 * time.Second,
+		Slots:      10,
+		Width:      2048,
+		TopK:       100,
+		Threshold:  1000,
+		SampleRate: 1,
+	}
+}
+
+// WithName 设置探测器名称
+func WithName(name string) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.Name = name
+	}
+}
+
+// WithWindow 设置滑动窗口时长及时间片数量
+func WithWindow(window time.Duration, slots int) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.Window = window
+		option.Slots = slots
+	}
+}
+
+// WithWidth 设置count-min sketch每行的计数器数量
+func WithWidth(width int) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.Width = width
+	}
+}
+
+// WithTopK 设置最多保留的热点key数量
+func WithTopK(k int) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.TopK = k
+	}
+}
+
+// WithThreshold 设置热点阈值
+func WithThreshold(threshold uint64) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.Threshold = threshold
+	}
+}
+
+// WithSampleRate 设置采样率
+func WithSampleRate(rate float64) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.SampleRate = rate
+	}
+}
+
+// WithOnHot 设置发现热点key时的回调
+func WithOnHot(fn HotKeyFunc) DetectorOptionFunc {
+	return func(option *DetectorOption) {
+		option.OnHot = fn
+	}
+}
+
+// AdaptiveTTLOption 自适应过期时间配置选项
+type AdaptiveTTLOption struct {
+	// 统计读取及更新次数的滑动窗口时长
+	Window time.Duration
+	// 窗口划分的时间片数量
+	Slots int
+	// 每个时间片count-min sketch每行的计数器数量
+	Width int
+	// 窗口内读取次数达到该值的key按ReadFactor延长过期时间
+	ReadThreshold uint64
+	// 窗口内更新次数达到该值的key按WriteFactor缩短过期时间，优先于延长
+	WriteThreshold uint64
+	// 读取频繁的key的过期时间倍数
+	ReadFactor float64
+	// 更新频繁的key的过期时间倍数
+	WriteFactor float64
+	// 调整后过期时间的下限及上限，零值表示不限制
+	MinTTL time.Duration
+	MaxTTL time.Duration
+}
+
+// AdaptiveTTLOptionFunc 自适应过期时间配置函数
+type AdaptiveTTLOptionFunc func(*AdaptiveTTLOption)
+
+// DefaultAdaptiveTTLOption 默认自适应过期时间配置
+func DefaultAdaptiveTTLOption() AdaptiveTTLOption {
+	return AdaptiveTTLOption{
+		Window:         time.Minute,
+		Slots:          6,
+		Width:          2048,
+		ReadThreshold:  100,
+		WriteThreshold: 10,
+		ReadFactor:     2,
+		WriteFactor:    0.5,
+		MinTTL:         time.Second,
+	}
+}
+
+// WithAdaptiveWindow 设置统计读取及更新次数的滑动窗口时长及时间片数量
+func WithAdaptiveWindow(window time.Duration, slots int) AdaptiveTTLOptionFunc {
+	return func(option *AdaptiveTTLOption) {
+		option.Window = window
+		option.Slots = slots
+	}
+}
+
+// WithReadBoost 设置读取频繁的阈值及过期时间倍数
+func WithReadBoost(threshold uint64, factor float64) AdaptiveTTLOptionFunc {
+	return func(option *AdaptiveTTLOption) {
+		option.ReadThreshold = threshold
+		option.ReadFactor = factor
+	}
+}
+
+// WithWritePenalty 设置更新频繁的阈值及过期时间倍数
+func WithWritePenalty(threshold uint64, factor float64) AdaptiveTTLOptionFunc {
+	return func(option *AdaptiveTTLOption) {
+		option.WriteThreshold = threshold
+		option.WriteFactor = factor
+	}
+}
+
+// WithTTLBounds 设置调整后过期时间的下限及上限，零值表示不限制
+func WithTTLBounds(min time.Duration, max time.Duration) AdaptiveTTLOptionFunc {
+	return func(option *AdaptiveTTLOption) {
+		option.MinTTL = min
+		option.MaxTTL = max
+	}
+}
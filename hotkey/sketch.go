@@ -0,0 +1,65 @@
+package hotkey
+
+import "hash/maphash"
+
+// sketchDepth count-min sketch的行数
+const sketchDepth = 4
+
+// cmSketch 单个时间片内key访问次数的count-min sketch
+type cmSketch struct {
+	rows [sketchDepth][]uint32
+	mask uint64
+}
+
+// newCMSketch 创建count-min sketch，width为每行计数器数量，向上取整为2的幂
+func newCMSketch(width int) *cmSketch {
+	w := 1
+	for w < width {
+		w <<= 1
+	}
+	s := &cmSketch{
+		mask: uint64(w - 1),
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint32, w)
+	}
+	return s
+}
+
+// increment 增加key的访问次数
+func (s *cmSketch) increment(h uint64) {
+	h1, h2 := h, h>>32|h<<32
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < ^uint32(0) {
+			s.rows[i][idx]++
+		}
+	}
+}
+
+// estimate 估计key的访问次数
+func (s *cmSketch) estimate(h uint64) uint32 {
+	h1, h2 := h, h>>32|h<<32
+	min := ^uint32(0)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < min {
+			min = s.rows[i][idx]
+		}
+	}
+	return min
+}
+
+// clear 清空所有计数
+func (s *cmSketch) clear() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+}
+
+// hashKey 计算key的哈希值
+func hashKey(seed maphash.Seed, key string) uint64 {
+	return maphash.String(seed, key)
+}
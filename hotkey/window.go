@@ -0,0 +1,59 @@
+package hotkey
+
+import "time"
+
+// slidingSketch 由按时间片划分的count-min sketch组成的滑动窗口，时间片在访问时惰性滑动，调用方需加锁
+type slidingSketch struct {
+	slots     []*cmSketch
+	cur       int
+	slotStart time.Time
+	slotSize  time.Duration
+}
+
+// newSlidingSketch 创建滑动窗口，window为窗口时长，slots为时间片数量，width为每行计数器数量
+func newSlidingSketch(window time.Duration, slots int, width int) *slidingSketch {
+	w := &slidingSketch{
+		slots:     make([]*cmSketch, slots),
+		slotStart: time.Now(),
+		slotSize:  window / time.Duration(slots),
+	}
+	if w.slotSize <= 0 {
+		w.slotSize = 1
+	}
+	for i := range w.slots {
+		w.slots[i] = newCMSketch(width)
+	}
+	return w
+}
+
+// increment 在当前时间片中增加key的次数
+func (w *slidingSketch) increment(h uint64) {
+	w.slots[w.cur].increment(h)
+}
+
+// estimate 估计key在窗口内的次数
+func (w *slidingSketch) estimate(h uint64) uint64 {
+	var sum uint64
+	for _, s := range w.slots {
+		sum += uint64(s.estimate(h))
+	}
+	return sum
+}
+
+// advance 滑动窗口至now所在时间片并清空过期时间片，返回是否发生滑动
+func (w *slidingSketch) advance(now time.Time) bool {
+	elapsed := int64(now.Sub(w.slotStart) / w.slotSize)
+	if elapsed <= 0 {
+		return false
+	}
+	w.slotStart = w.slotStart.Add(time.Duration(elapsed) * w.slotSize)
+	n := int(elapsed)
+	if elapsed > int64(len(w.slots)) {
+		n = len(w.slots)
+	}
+	for i := 0; i < n; i++ {
+		w.cur = (w.cur + 1) % len(w.slots)
+		w.slots[w.cur].clear()
+	}
+	return true
+}
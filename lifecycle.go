@@ -0,0 +1,81 @@
+package multicache
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/utils"
+)
+
+// Close 关闭缓存场景，用于服务退出前
+// 依次刷新异步写回队列、执行待执行的延迟双删、等待后台刷新协程退出，最后关闭实现了adaptor.Closer的适配器
+// 关闭后Get读取到软过期数据时不再启动后台刷新
+// 适配器被多个缓存场景共享时同样会被关闭；指标计数器、Syncer及Generation可能被共享，需由创建方单独关闭
+// 单个步骤失败不影响其余步骤，返回第一个错误
+func (c *Cache[K, V]) Close(ctx context.Context) error {
+	c.refreshMu.Lock()
+	c.closed = true
+	c.refreshMu.Unlock()
+	return firstError(
+		c.writer.close(ctx),
+		c.deleter.drain(ctx),
+		wait(ctx, &c.refreshWg),
+		closeAdaptors(ctx, c.name, c.adaptors),
+	)
+}
+
+// Close 关闭缓存场景，用于服务退出前
+// 依次刷新异步写回队列、执行待执行的延迟双删，最后关闭实现了adaptor.Closer的适配器
+// 适配器被多个缓存场景共享时同样会被关闭；指标计数器、Syncer及Generation可能被共享，需由创建方单独关闭
+// 单个步骤失败不影响其余步骤，返回第一个错误
+func (c *MultiCache[K, V]) Close(ctx context.Context) error {
+	return firstError(
+		c.writer.close(ctx),
+		c.deleter.drain(ctx),
+		closeAdaptors(ctx, c.name, c.adaptors),
+	)
+}
+
+// closeAdaptors 关闭实现了adaptor.Closer的适配器，返回第一个错误
+func closeAdaptors[A named](ctx context.Context, name string, adaptors []A) error {
+	var firstErr error
+	for _, adap := range adaptors {
+		closer, ok := any(adap).(adaptor.Closer)
+		if !ok {
+			continue
+		}
+		err := closer.Close(ctx)
+		if err != nil {
+			logger.Error(err.Error(), "solution", name, "adaptor", adap.Name(), "event", adaptor.LogEventClose)
+			firstErr = utils.IfExpr(firstErr == nil, err, firstErr)
+		}
+	}
+	return firstErr
+}
+
+// wait 等待协程全部退出，ctx到期时返回ctx.Err()
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// firstError 返回第一个非空错误
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package local
+
+import (
+	"sync"
+	"time"
+)
+
+// 类型检测
+var _ Store = (*AdmissionStore)(nil)
+var _ Iterable[[]byte] = (*AdmissionStore)(nil)
+
+// admissionSamples 容量已满时单次淘汰的采样数量
+const admissionSamples = 5
+
+// admissionEntry 字节存储条目
+type admissionEntry struct {
+	val      []byte
+	expireAt time.Time
+	hash     uint64
+	cost     int64
+}
+
+// AdmissionStore 带准入策略的字节存储(参考Ristretto的TinyLFU准入及采样LFU淘汰)
+// 通过count-min sketch记录key的访问频率，容量已满时采样若干已有数据，新数据的访问频率低于采样中频率最低的数据时拒绝写入，否则淘汰后者
+// 数据的开销为key与value的字节数之和，总开销不超过maxCost
+type AdmissionStore struct {
+	mu      sync.Mutex
+	items   map[string]*admissionEntry
+	sketch  *cmSketch
+	cost    int64
+	maxCost int64
+}
+
+// NewAdmissionStore 创建带准入策略的字节存储，maxCost为数据总开销上限
+func NewAdmissionStore(maxCost Size) *AdmissionStore {
+	// 按平均每条数据1KB估算数据条数，计数器数量取数据条数的10倍
+	numCounters := int(maxCost / KB * 10)
+	if numCounters < 1024 {
+		numCounters = 1024
+	}
+	if numCounters > 1<<24 {
+		numCounters = 1 << 24
+	}
+	return &AdmissionStore{
+		items:   make(map[string]*admissionEntry),
+		sketch:  newCMSketch(numCounters),
+		maxCost: int64(maxCost),
+	}
+}
+
+// Get 读取数据，同时记录访问频率
+func (s *AdmissionStore) Get(key string) ([]byte, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sketch.increment(s.sketch.hash(key))
+	e, ok := s.items[key]
+	if !ok {
+		return nil, time.Time{}, ErrNotFound
+	}
+	if e.expired(time.Now()) {
+		s.remove(key, e)
+		return nil, time.Time{}, ErrNotFound
+	}
+	return e.val, e.expireAt, nil
+}
+
+// Set 写入数据，未通过准入策略的写入被丢弃，超过容量上限的数据返回ErrTooLarge
+func (s *AdmissionStore) Set(key string, val []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.set(key, val, ttl)
+	return err
+}
+
+// Update 在锁内读取数据并按需替换，替换同样受准入策略约束
+func (s *AdmissionStore) Update(key string, fn UpdateFunc[[]byte]) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cur []byte
+	e, found := s.items[key]
+	if found && e.expired(time.Now()) {
+		s.remove(key, e)
+		found = false
+	}
+	if found {
+		cur = e.val
+	}
+	val, replace, ttl := fn(cur, found)
+	if !replace {
+		return false, nil
+	}
+	return s.set(key, val, ttl)
+}
+
+// Del 删除数据
+func (s *AdmissionStore) Del(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if ok {
+		s.remove(key, e)
+	}
+	return ok
+}
+
+// Clear 清空数据及访问频率
+func (s *AdmissionStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = make(map[string]*admissionEntry)
+	s.cost = 0
+	s.sketch.clear()
+}
+
+// Len 数据条数
+func (s *AdmissionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// Range 遍历未过期的数据，不记录访问频率
+// 锁内仅复制条目，释放锁后再回调，快照序列化期间不阻塞读写
+func (s *AdmissionStore) Range(fn func(key string, val []byte, expireAt time.Time) bool) {
+	s.mu.Lock()
+	now := time.Now()
+	entries := make([]rangeEntry[[]byte], 0, len(s.items))
+	for key, e := range s.items {
+		if e.expired(now) {
+			continue
+		}
+		entries = append(entries, rangeEntry[[]byte]{key: key, val: e.val, expireAt: e.expireAt})
+	}
+	s.mu.Unlock()
+	rangeEntries(entries, fn)
+}
+
+// set 写入数据，返回是否写入，调用方需持有锁
+// 超过容量上限时删除key原有的数据并返回ErrTooLarge，避免继续读取到旧数据
+func (s *AdmissionStore) set(key string, val []byte, ttl time.Duration) (bool, error) {
+	// 已有数据的更新不受准入策略约束
+	e, exists := s.items[key]
+	if exists {
+		s.remove(key, e)
+	}
+	cost := int64(len(key) + len(val))
+	if cost > s.maxCost {
+		return false, ErrTooLarge
+	}
+	h := s.sketch.hash(key)
+	s.sketch.increment(h)
+	if !s.admit(h, cost, exists) {
+		return false, nil
+	}
+
+	e = &admissionEntry{
+		val:  append([]byte(nil), val...),
+		hash: h,
+		cost: cost,
+	}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	s.items[key] = e
+	s.cost += cost
+	return true, nil
+}
+
+// admit 为新数据腾出空间，新数据的访问频率低于采样中频率最低的数据时拒绝写入，force为true时总是淘汰
+func (s *AdmissionStore) admit(h uint64, cost int64, force bool) bool {
+	now := time.Now()
+	freq := s.sketch.estimate(h)
+	for s.cost+cost > s.maxCost {
+		victimKey := ""
+		var victim *admissionEntry
+		var victimFreq uint8
+		n := 0
+		for key, e := range s.items {
+			if e.expired(now) {
+				s.remove(key, e)
+				continue
+			}
+			if f := s.sketch.estimate(e.hash); victim == nil || f < victimFreq {
+				victimKey, victim, victimFreq = key, e, f
+			}
+			n++
+			if n >= admissionSamples {
+				break
+			}
+		}
+		if s.cost+cost <= s.maxCost {
+			break
+		}
+		if victim == nil {
+			// 采样到的数据均已过期
+			continue
+		}
+		if !force && freq < victimFreq {
+			return false
+		}
+		s.remove(victimKey, victim)
+	}
+	return true
+}
+
+// remove 删除数据，调用方需持有锁
+func (s *AdmissionStore) remove(key string, e *admissionEntry) {
+	delete(s.items, key)
+	s.cost -= e.cost
+}
+
+// expired 判断数据是否已过期
+func (e *admissionEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
@@ -1,24 +1,167 @@
 package local
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/coocood/freecache"
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
 )
 
-var freeCacheClient *freecache.Cache
-var freeCacheOnce sync.Once
+// DefaultFreeCacheName 全局共享freecache实例的名称
+const DefaultFreeCacheName = "default"
+
+// ErrFreeCacheInitialized 全局共享freecache实例已创建，无法修改大小
+var ErrFreeCacheInitialized = errors.New("freecache already initialized")
+
+// ErrQuotaConflict 同名freecache实例已按其他配额创建
+var ErrQuotaConflict = errors.New("freecache quota conflict")
+
+// ErrEmptyFreeCacheName 按配额创建的独立freecache实例名称为空
+var ErrEmptyFreeCacheName = errors.New("freecache name is empty")
+
 var freeCacheSize Size = 128 * MB
 
-// InitFreeCacheSize 初始化FreeCache使用的内存大小
-func InitFreeCacheSize(s Size) {
+// freeCacheRegistry 命名freecache实例注册表
+var freeCacheRegistry = struct {
+	sync.Mutex
+	caches map[string]*namedFreeCache
+}{
+	caches: make(map[string]*namedFreeCache),
+}
+
+// namedFreeCache 命名freecache实例
+type namedFreeCache struct {
+	cache *freecache.Cache
+	quota Size
+}
+
+// FreeCacheStats freecache实例使用情况
+type FreeCacheStats struct {
+	Name           string
+	Quota          Size
+	EntryCount     int64
+	EvacuateCount  int64
+	ExpiredCount   int64
+	OverwriteCount int64
+	HitCount       int64
+	MissCount      int64
+	HitRate        float64
+}
+
+// InitFreeCacheSize 初始化全局共享freecache实例使用的内存大小，需在首次调用FreeCacheClient前调用
+func InitFreeCacheSize(s Size) error {
+	freeCacheRegistry.Lock()
+	defer freeCacheRegistry.Unlock()
+	if _, ok := freeCacheRegistry.caches[DefaultFreeCacheName]; ok {
+		return ErrFreeCacheInitialized
+	}
 	freeCacheSize = s
+	return nil
 }
 
-// FreeCacheClient 获取FreeCache客户端
+// FreeCacheClient 获取全局共享的freecache实例
 func FreeCacheClient() *freecache.Cache {
-	freeCacheOnce.Do(func() {
-		freeCacheClient = freecache.NewCache(int(freeCacheSize))
-	})
-	return freeCacheClient
+	freeCacheRegistry.Lock()
+	defer freeCacheRegistry.Unlock()
+	return registerFreeCache(DefaultFreeCacheName, freeCacheSize).cache
+}
+
+// NamedFreeCache 获取指定名称的独立freecache实例，不存在时按配额创建
+// 同名实例已按其他配额创建时返回已有实例及ErrQuotaConflict，名称为空时返回ErrEmptyFreeCacheName
+func NamedFreeCache(name string, quota Size) (*freecache.Cache, error) {
+	if name == "" {
+		return nil, ErrEmptyFreeCacheName
+	}
+	freeCacheRegistry.Lock()
+	defer freeCacheRegistry.Unlock()
+	if name == DefaultFreeCacheName {
+		quota = freeCacheSize
+	}
+	named := registerFreeCache(name, quota)
+	if named.quota != quota {
+		return named.cache, fmt.Errorf("%w: %s registered with %d bytes", ErrQuotaConflict, name, named.quota)
+	}
+	return named.cache, nil
+}
+
+// FreeCacheStatsOf 获取指定名称freecache实例的使用情况
+func FreeCacheStatsOf(name string) (FreeCacheStats, bool) {
+	freeCacheRegistry.Lock()
+	named, ok := freeCacheRegistry.caches[name]
+	freeCacheRegistry.Unlock()
+	if !ok {
+		return FreeCacheStats{}, false
+	}
+	return named.stats(name), true
+}
+
+// AllFreeCacheStats 获取所有freecache实例的使用情况，按名称排序
+func AllFreeCacheStats() []FreeCacheStats {
+	freeCacheRegistry.Lock()
+	names := make([]string, 0, len(freeCacheRegistry.caches))
+	caches := make(map[string]*namedFreeCache, len(freeCacheRegistry.caches))
+	for name, named := range freeCacheRegistry.caches {
+		names = append(names, name)
+		caches[name] = named
+	}
+	freeCacheRegistry.Unlock()
+
+	sort.Strings(names)
+	stats := make([]FreeCacheStats, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, caches[name].stats(name))
+	}
+	return stats
+}
+
+// registerFreeCache 获取或创建命名freecache实例，调用方需持有注册表锁
+func registerFreeCache(name string, quota Size) *namedFreeCache {
+	named, ok := freeCacheRegistry.caches[name]
+	if !ok {
+		named = &namedFreeCache{
+			cache: freecache.NewCache(int(quota)),
+			quota: quota,
+		}
+		freeCacheRegistry.caches[name] = named
+	}
+	return named
+}
+
+// stats freecache实例使用情况
+func (n *namedFreeCache) stats(name string) FreeCacheStats {
+	return FreeCacheStats{
+		Name:           name,
+		Quota:          n.quota,
+		EntryCount:     n.cache.EntryCount(),
+		EvacuateCount:  n.cache.EvacuateCount(),
+		ExpiredCount:   n.cache.ExpiredCount(),
+		OverwriteCount: n.cache.OverwriteCount(),
+		HitCount:       n.cache.HitCount(),
+		MissCount:      n.cache.MissCount(),
+		HitRate:        n.cache.HitRate(),
+	}
+}
+
+// solutionFreeCache 未指定freecache实例时，配置了配额则使用按场景名称隔离的实例，否则使用全局共享实例
+// 配置了配额但未设置场景名称时拒绝创建独立实例并使用全局共享实例，避免所有未命名场景共用同一配额实例
+func solutionFreeCache(fns ...LocalCacheOptionFunc) *freecache.Cache {
+	opts := DefaultLocalCacheOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	if opts.Quota <= 0 {
+		return FreeCacheClient()
+	}
+	icache, err := NamedFreeCache(opts.SolutionName, opts.Quota)
+	if err != nil {
+		logger.Error(err.Error(), "solution", opts.SolutionName, "adaptor", opts.Name, "event", adaptor.LogEventInit)
+	}
+	if icache == nil {
+		return FreeCacheClient()
+	}
+	return icache
 }
@@ -0,0 +1,193 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coocood/freecache"
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/metrics"
+	"github.com/rumis/multicache/syncer"
+	"github.com/rumis/multicache/tests"
+)
+
+// resetFreeCacheRegistry 清空freecache注册表及全局共享实例大小，测试结束后恢复原有状态
+func resetFreeCacheRegistry(t *testing.T) {
+	freeCacheRegistry.Lock()
+	caches, size := freeCacheRegistry.caches, freeCacheSize
+	freeCacheRegistry.caches = make(map[string]*namedFreeCache)
+	freeCacheSize = 128 * MB
+	freeCacheRegistry.Unlock()
+	t.Cleanup(func() {
+		freeCacheRegistry.Lock()
+		freeCacheRegistry.caches, freeCacheSize = caches, size
+		freeCacheRegistry.Unlock()
+	})
+}
+
+func TestFreeCacheRegistry(t *testing.T) {
+	resetFreeCacheRegistry(t)
+	if err := InitFreeCacheSize(64 * MB); err != nil {
+		t.Fatal(err)
+	}
+	FreeCacheClient()
+	// 全局共享实例创建后无法修改大小
+	if err := InitFreeCacheSize(32 * MB); !errors.Is(err, ErrFreeCacheInitialized) {
+		t.Errorf("err = %v, want ErrFreeCacheInitialized", err)
+	}
+
+	a, err := NamedFreeCache("registry_test_a", MB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NamedFreeCache("registry_test_b", 2*MB)
+	if a == b || a == FreeCacheClient() {
+		t.Error("named freecache should be isolated")
+	}
+	// 同名实例按不同配额获取时返回已有实例
+	a1, err := NamedFreeCache("registry_test_a", 2*MB)
+	if a1 != a || !errors.Is(err, ErrQuotaConflict) {
+		t.Errorf("err = %v, want ErrQuotaConflict", err)
+	}
+
+	a.Set([]byte("张三"), []byte("18"), 0)
+	a.Get([]byte("张三"))
+	a.Get([]byte("李四"))
+	stats, ok := FreeCacheStatsOf("registry_test_a")
+	if !ok || stats.Quota != MB || stats.EntryCount != 1 || stats.HitRate != 0.5 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if n := len(AllFreeCacheStats()); n != 3 {
+		t.Errorf("AllFreeCacheStats() = %d instances, want 3", n)
+	}
+
+	// 配置配额时场景名称不可为空
+	if _, err := NamedFreeCache("", MB); !errors.Is(err, ErrEmptyFreeCacheName) {
+		t.Errorf("err = %v, want ErrEmptyFreeCacheName", err)
+	}
+	if solutionFreeCache(WithQuota(MB)) != FreeCacheClient() {
+		t.Error("quota without solution name should fall back to the shared instance")
+	}
+}
+
+func TestFreeCacheSyncFlush(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "sync_flush_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+	testSyncer := syncer.NewRedisSyncer(tests.NewRedisClient(), "sync_flush_test")
+	defer testSyncer.Close(context.Background())
+
+	icache := freecache.NewCache(int(MB))
+	student := NewFreeCache[string, *tests.Student](icache, nil, WithPrefix("student_"), WithSyncer(testSyncer))
+	teacher := NewFreeCache[string, *tests.Student](icache, nil, WithPrefix("teacher_"))
+	for _, name := range []string{"张三", "张四", "李四"} {
+		student.Set(ctx, &tests.Student{Name: name, Age: 18})
+		teacher.Set(ctx, &tests.Student{Name: name, Age: 40})
+	}
+
+	// 按前缀清空
+	student.sync(&syncer.CacheSyncEvent{EventType: syncer.EventTypeFlush, Key: "student_张"})
+	if icache.EntryCount() != 4 {
+		t.Errorf("entries = %d, want 4", icache.EntryCount())
+	}
+	// 其他前缀的清空事件忽略
+	student.sync(&syncer.CacheSyncEvent{EventType: syncer.EventTypeFlush, Key: "teacher_"})
+	// 清空本实例前缀下的全部数据，不影响共享实例中其他前缀的数据
+	student.sync(&syncer.CacheSyncEvent{EventType: syncer.EventTypeFlush})
+	if icache.EntryCount() != 3 {
+		t.Errorf("entries = %d, want 3", icache.EntryCount())
+	}
+}
+
+func TestFreeCacheSyncInvalidate(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "sync_invalidate_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+
+	s := &memorySyncer{clientId: "sender"}
+	sender := NewFreeCache[string, *tests.VersionedStudent](freecache.NewCache(int(MB)), nil, WithSyncer(s), WithSyncMode(SyncInvalidate))
+	peer := NewFreeCache[string, *tests.VersionedStudent](freecache.NewCache(int(MB)), nil, WithSyncer(&memorySyncer{clientId: "peer"}))
+	s.handlers = append(s.handlers, peer.sync)
+	newStudent := func(age int, version int64) *tests.VersionedStudent {
+		return &tests.VersionedStudent{Student: tests.Student{Name: "张三", Age: age, Time: version}}
+	}
+	peerHas := func() bool {
+		ok, _ := peer.Get(ctx, "张三", &tests.VersionedStudent{})
+		return ok
+	}
+
+	// 仅广播key及版本号，接收方删除本地数据
+	peer.Set(ctx, newStudent(18, 1))
+	sender.Set(ctx, newStudent(20, 2))
+	if s.last.EventType != syncer.EventTypeInvalidate || s.last.Val != nil || s.last.Version != 2 {
+		t.Errorf("event = %s", s.last.Encode())
+	}
+	if peerHas() {
+		t.Error("peer value not invalidated")
+	}
+	// 接收方已有不低于事件版本号的数据时保留
+	peer.Set(ctx, newStudent(21, 3))
+	sender.Set(ctx, newStudent(20, 2))
+	if !peerHas() {
+		t.Error("newer peer value invalidated")
+	}
+
+	// 多值缓存广播批量失效事件
+	ms := &memorySyncer{clientId: "multi_sender"}
+	multiSender := NewMultiFreeCache[string, *tests.Student](freecache.NewCache(int(MB)), nil, WithSyncer(ms), WithSyncMode(SyncInvalidate), WithSyncBatch(256*1024, false))
+	peerCache := freecache.NewCache(int(MB))
+	multiPeer := NewMultiFreeCache[string, *tests.Student](peerCache, nil, WithSyncer(&memorySyncer{clientId: "multi_peer"}))
+	ms.handlers = append(ms.handlers, multiPeer.sync)
+	vals := adaptor.ValueCol[*tests.Student]{{Name: "张三", Age: 18}, {Name: "李四", Age: 19}, {Name: "王五", Age: 20}}
+	multiPeer.Set(ctx, vals)
+	multiSender.Set(ctx, vals[:2])
+	if ms.emits != 1 || ms.last.EventType != syncer.EventTypeBatchInvalidate || len(ms.last.Entries) != 2 || ms.last.Entries[0].Val != nil {
+		t.Errorf("emits = %d, last = %+v", ms.emits, ms.last)
+	}
+	if peerCache.EntryCount() != 1 {
+		t.Errorf("peer entries = %d, want 1", peerCache.EntryCount())
+	}
+	// 读取路径的回填不广播失效事件
+	multiSender.Set(adaptor.WithRefill(ctx), vals)
+	if ms.emits != 1 {
+		t.Errorf("refill emits = %d, want 1", ms.emits)
+	}
+}
+
+func TestFreeCacheTagIndex(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "tag_index_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+
+	cache := NewFreeCache[string, *tests.TaggedStudent](freecache.NewCache(int(MB)), nil)
+	for i := 0; i < minPruneKeys-1; i++ {
+		cache.Set(ctx, &tests.TaggedStudent{Student: tests.Student{Name: fmt.Sprint("student_", i), Age: i % 2}})
+	}
+	if n, _ := cache.InvalidateTag(ctx, "age:1"); n != minPruneKeys/2-1 {
+		t.Errorf("invalidated = %d, want %d", n, minPruneKeys/2-1)
+	}
+	// 存储中已淘汰的key在索引增长后被清理
+	cache.store.purge("")
+	for i := 0; i < minPruneKeys/2+1; i++ {
+		cache.Set(ctx, &tests.TaggedStudent{Student: tests.Student{Name: fmt.Sprint("teacher_", i), Age: 2}})
+	}
+	if n := len(cache.tags.keys); n != minPruneKeys/2+1 {
+		t.Errorf("indexed keys = %d, want %d", n, minPruneKeys/2+1)
+	}
+	if n, _ := cache.InvalidatePrefix(ctx, "teacher_"); n != minPruneKeys/2+1 || len(cache.tags.keys) != 0 {
+		t.Errorf("invalidated = %d, indexed keys = %d", n, len(cache.tags.keys))
+	}
+
+	// 失效事件删除本地数据时同时删除标签索引
+	peer := NewFreeCache[string, *tests.TaggedStudent](freecache.NewCache(int(MB)), nil, WithSyncer(&memorySyncer{clientId: "peer"}))
+	multiPeer := NewMultiFreeCache[string, *tests.TaggedStudent](freecache.NewCache(int(MB)), nil, WithSyncer(&memorySyncer{clientId: "multi_peer"}))
+	peer.Set(ctx, &tests.TaggedStudent{Student: tests.Student{Name: "张三", Age: 18}})
+	multiPeer.Set(ctx, adaptor.ValueCol[*tests.TaggedStudent]{{Student: tests.Student{Name: "张三", Age: 18}}})
+	peer.sync(&syncer.CacheSyncEvent{ClientID: "sender", EventType: syncer.EventTypeInvalidate, Key: peer.key("张三")})
+	multiPeer.sync(&syncer.CacheSyncEvent{ClientID: "sender", EventType: syncer.EventTypeBatchInvalidate, Entries: []syncer.SyncEntry{{Key: multiPeer.key("张三")}}})
+	if len(peer.tags.keys) != 0 || len(multiPeer.tags.keys) != 0 {
+		t.Errorf("indexed keys = %d, %d, want 0", len(peer.tags.keys), len(multiPeer.tags.keys))
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coocood/freecache"
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/syncer"
@@ -16,11 +20,13 @@ import (
 
 // 类型检测
 var _ adaptor.Adaptor[string, adaptor.Metadata] = (*FreeCache[string, adaptor.Metadata])(nil)
+var _ adaptor.Closer = (*FreeCache[string, adaptor.Metadata])(nil)
+var _ adaptor.Invalidator = (*FreeCache[string, adaptor.Metadata])(nil)
 
-// FreeCache 基于freecache的本地缓存实现
+// FreeCache 本地缓存实现，默认基于freecache，可通过Store/TypedStore替换存储
 type FreeCache[K comparable, V adaptor.Metadata] struct {
-	// freechache对象
-	innerCache *freecache.Cache
+	// 存储层
+	store storage[V]
 	// key前缀
 	prefix string
 	// 过期时间
@@ -34,10 +40,48 @@ type FreeCache[K comparable, V adaptor.Metadata] struct {
 	solutionName string
 	ttlZero      time.Duration
 	syncer       syncer.Syncer
+	// 热点key探测器及热点key过期时间
+	hotKey *hotkey.Detector
+	hotTTL time.Duration
+	// 本地缓存快照
+	snapshot *snapshotter[V]
+	// 标签索引，对象未实现Tagged接口时为nil
+	tags *tagIndex
+	// 取消数据同步订阅
+	unsubscribe context.CancelFunc
+	// 软过期后仍可继续提供服务的时长
+	staleTTL time.Duration
+	// 写入数据的同步方式
+	syncMode SyncMode
+	// 缓存代数，拼接至key前缀之后
+	generation *generation.Generation
+	// 过期时间策略
+	ttlPolicy adaptor.TTLPolicy
+	// 应用批量同步事件时持有写锁，读取时持有读锁，保证批量事件对读取整体可见
+	batchMu sync.RWMutex
 }
 
 // NewFreeCache 创建一个新的FreeCache对象
+// icache为空时，配置了WithQuota则使用按场景名称隔离的freecache实例，否则使用全局共享实例
 func NewFreeCache[K comparable, V adaptor.Metadata](icache *freecache.Cache, preAdaptor adaptor.Adaptor[K, V], fns ...LocalCacheOptionFunc) *FreeCache[K, V] {
+	if icache == nil {
+		icache = solutionFreeCache(fns...)
+	}
+	return newLocalCache[K, V](newByteStorage[V](NewFreeCacheStore(icache)), preAdaptor, fns...)
+}
+
+// NewLocalCache 创建基于字节存储的本地缓存对象
+func NewLocalCache[K comparable, V adaptor.Metadata](store Store, preAdaptor adaptor.Adaptor[K, V], fns ...LocalCacheOptionFunc) *FreeCache[K, V] {
+	return newLocalCache[K, V](newByteStorage[V](store), preAdaptor, fns...)
+}
+
+// NewTypedLocalCache 创建基于对象存储的本地缓存对象，读取时无需反序列化
+func NewTypedLocalCache[K comparable, V adaptor.Metadata](store TypedStore[V], preAdaptor adaptor.Adaptor[K, V], fns ...LocalCacheOptionFunc) *FreeCache[K, V] {
+	return newLocalCache[K, V](newTypedStorage[V](store), preAdaptor, fns...)
+}
+
+// newLocalCache 创建本地缓存对象
+func newLocalCache[K comparable, V adaptor.Metadata](store storage[V], preAdaptor adaptor.Adaptor[K, V], fns ...LocalCacheOptionFunc) *FreeCache[K, V] {
 	// 默认+自定义配置
 	opts := DefaultLocalCacheOption()
 	for _, fn := range fns {
@@ -45,7 +89,7 @@ func NewFreeCache[K comparable, V adaptor.Metadata](icache *freecache.Cache, pre
 	}
 
 	cacheInst := &FreeCache[K, V]{
-		innerCache:   icache,
+		store:        store,
 		prefix:       opts.Prefix,
 		ttl:          opts.TTL,
 		threshold:    opts.Threshold,
@@ -55,11 +99,28 @@ func NewFreeCache[K comparable, V adaptor.Metadata](icache *freecache.Cache, pre
 		solutionName: opts.SolutionName,
 		ttlZero:      opts.TTLZero,
 		syncer:       opts.Syncer,
+		hotKey:       opts.HotKey,
+		hotTTL:       opts.HotTTL,
+		staleTTL:     opts.StaleTTL,
+		syncMode:     opts.SyncMode,
+		ttlPolicy:    opts.TTLPolicy,
+		generation:   opts.Generation,
 	}
 
+	// 标签索引，需在快照恢复前创建，恢复时重建索引
+	cacheInst.tags = newTagIndex(store)
+
+	// 从快照恢复并启动定期快照
+	cacheInst.snapshot = newSnapshotter(store, cacheInst.tags, opts)
+
 	// 订阅数据同步事件
 	if cacheInst.syncer != nil {
-		cacheInst.syncer.Subscribe(context.Background(), cacheInst.sync)
+		ctx, cancel := context.WithCancel(context.Background())
+		cacheInst.unsubscribe = cancel
+		err := cacheInst.syncer.Subscribe(ctx, cacheInst.sync)
+		if err != nil {
+			logger.Error(err.Error(), "solution", opts.SolutionName, "adaptor", opts.Name, "event", adaptor.LogEventSync)
+		}
 	}
 
 	return cacheInst
@@ -74,8 +135,8 @@ func (c *FreeCache[K, V]) Name() string {
 func (c *FreeCache[K, V]) Get(ctx context.Context, key K, value V) (bool, error) {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
 	startTime := time.Now()
-	// 跳过
-	if c.skipGet {
+	// 跳过，热点key仍从本地缓存读取
+	if c.skipGet && !c.hot(fmt.Sprint(key)) {
 		metric.AddMeta(ctx, metrics.Meta{
 			AdaptorName: c.Name(),
 			Key:         fmt.Sprint(key),
@@ -84,8 +145,11 @@ func (c *FreeCache[K, V]) Get(ctx context.Context, key K, value V) (bool, error)
 		return false, nil
 	}
 
-	buf, err := c.innerCache.Get(utils.Bytes(c.key(key)))
-	if errors.Is(err, freecache.ErrNotFound) {
+	// 读取并反序列化对象
+	c.batchMu.RLock()
+	expireAt, err := c.store.get(c.key(key), value)
+	c.batchMu.RUnlock()
+	if errors.Is(err, ErrNotFound) {
 		metric.AddMeta(ctx, metrics.Meta{
 			AdaptorName: c.Name(),
 			Key:         fmt.Sprint(key),
@@ -102,8 +166,17 @@ func (c *FreeCache[K, V]) Get(ctx context.Context, key K, value V) (bool, error)
 		})
 		return false, err
 	}
-	// 反序列化对象
-	value.Decode(buf)
+
+	// 数据已软过期，不回写上层缓存，由调用方后台刷新
+	if c.stale(expireAt) && !value.Zero() {
+		metric.AddMeta(ctx, metrics.Meta{
+			AdaptorName: c.Name(),
+			Key:         fmt.Sprint(key),
+			Type:        metrics.Stale,
+			TrackTime:   time.Since(startTime).Milliseconds(),
+		})
+		return true, adaptor.ErrStale
+	}
 
 	metric.AddMeta(ctx, metrics.Meta{
 		AdaptorName: c.Name(),
@@ -114,7 +187,7 @@ func (c *FreeCache[K, V]) Get(ctx context.Context, key K, value V) (bool, error)
 
 	// 数据回写
 	if c.preAdaptor != nil {
-		err := c.preAdaptor.Set(ctx, value)
+		err := c.preAdaptor.Set(adaptor.WithRefill(ctx), value)
 		if err != nil {
 			// 回写失败 只记录错误，不影响主流程
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", value, "event", adaptor.LogEventRefill)
@@ -128,28 +201,37 @@ func (c *FreeCache[K, V]) Set(ctx context.Context, value V) error {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
 	startTime := time.Now()
 	ttl := int(c.ttl.Seconds()) + utils.SafeRand().Intn(int(c.threshold.Seconds())) // 正常TTL
-	ttl = utils.IfExpr(value.Zero(), int(c.ttlZero.Seconds()), ttl)                 // 0值TTL
-
-	valBuf, err := value.Value()
-	if err != nil {
-		return err
+	if c.hotTTL > 0 && c.hot(value.Key()) {
+		ttl = int(c.hotTTL.Seconds()) // 热点key TTL
 	}
-	err = c.innerCache.Set(utils.Bytes(c.key1(value.Key())), valBuf, ttl)
-	if err != nil {
+	ttl = resolveTTL(value, ttl, c.ttlPolicy) + int(c.staleTTL.Seconds()) // 对象及策略TTL
+	ttl = utils.IfExpr(value.Zero(), int(c.ttlZero.Seconds()), ttl)       // 0值TTL
+
+	// 对象实现Versioned接口时，缓存中已有更新版本的数据则放弃写入
+	written, valBuf, err := c.store.set(c.key1(value.Key()), value, time.Duration(ttl)*time.Second, c.syncer != nil && c.syncMode == SyncValue)
+	if err != nil || !written {
 		return err
 	}
-	// 缓存数据同步
-	if c.syncer != nil {
+	c.tags.add(c.key1(value.Key()), adaptor.TagsOf(value))
+	// 缓存数据同步，失效模式下读取路径的回填未变更数据，无需通知其他实例
+	if c.syncer != nil && !(c.syncMode == SyncInvalidate && adaptor.IsRefill(ctx)) {
 		setEvent := &syncer.CacheSyncEvent{
 			EventType: syncer.EventTypeAdd,
 			Key:       c.key1(value.Key()),
 			Val:       valBuf,
 			TTL:       time.Duration(ttl) * time.Second,
+			Version:   adaptor.VersionOf(value),
+			Tags:      adaptor.TagsOf(value),
 		}
-		err := c.syncer.Emit(ctx, setEvent)
-		if err != nil {
-			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", setEvent.Encode(), "event", adaptor.LogEventSync)
+		// 仅通知其他实例失效本地数据
+		if c.syncMode == SyncInvalidate {
+			setEvent = &syncer.CacheSyncEvent{
+				EventType: syncer.EventTypeInvalidate,
+				Key:       c.key1(value.Key()),
+				Version:   adaptor.VersionOf(value),
+			}
 		}
+		c.emit(ctx, setEvent)
 	}
 
 	metric.AddMeta(ctx, metrics.Meta{
@@ -164,23 +246,43 @@ func (c *FreeCache[K, V]) Set(ctx context.Context, value V) error {
 // Del 删除对象
 func (c *FreeCache[K, V]) Del(ctx context.Context, key K) error {
 	// 删除本地缓存
-	c.innerCache.Del(utils.Bytes(c.key(key)))
+	c.store.del(c.key(key))
+	c.tags.remove(c.key(key))
 
 	// 删除缓存数据操作同步
-	if c.syncer != nil {
-		delEvent := &syncer.CacheSyncEvent{
-			EventType: syncer.EventTypeDelete,
-			Key:       c.key(key),
-		}
-		err := c.syncer.Emit(ctx, delEvent)
-		if err != nil {
-			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", delEvent.Encode(), "event", adaptor.LogEventSync)
-		}
-	}
+	c.emit(ctx, &syncer.CacheSyncEvent{
+		EventType: syncer.EventTypeDelete,
+		Key:       c.key(key),
+	})
 
 	return nil
 }
 
+// InvalidateTag 删除带有tag标签的本地数据，并通过Syncer广播至其他实例，返回本实例删除条数
+func (c *FreeCache[K, V]) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	n := invalidateTag(c.store, c.tags, tag)
+	c.emit(ctx, &syncer.CacheSyncEvent{EventType: syncer.EventTypeInvalidateTag, Key: c.prefix, Tag: tag})
+	return n, nil
+}
+
+// InvalidatePrefix 删除key以prefix开头的本地数据，并通过Syncer广播EventTypeFlush事件至其他实例，返回本实例删除条数
+// 存储未实现Iterable接口时清空整个存储
+func (c *FreeCache[K, V]) InvalidatePrefix(ctx context.Context, prefix string) (int, error) {
+	n := invalidatePrefix(c.store, c.tags, c.key1(prefix))
+	c.emit(ctx, &syncer.CacheSyncEvent{EventType: syncer.EventTypeFlush, Key: c.key1(prefix)})
+	return n, nil
+}
+
+// emit 广播同步事件，失败时记录日志
+func (c *FreeCache[K, V]) emit(ctx context.Context, e *syncer.CacheSyncEvent) {
+	if c.syncer == nil {
+		return
+	}
+	if err := c.syncer.Emit(ctx, e); err != nil {
+		logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", e.Encode(), "event", adaptor.LogEventSync)
+	}
+}
+
 // sync 数据同步
 func (c *FreeCache[K, V]) sync(e *syncer.CacheSyncEvent) {
 	if c.syncer == nil {
@@ -192,13 +294,73 @@ func (c *FreeCache[K, V]) sync(e *syncer.CacheSyncEvent) {
 	switch e.EventType {
 	case syncer.EventTypeAdd:
 		ttl := int(e.TTL.Seconds())
-		err := c.innerCache.Set(utils.Bytes(e.Key), e.Val, ttl)
+		// 对象实现Versioned接口时丢弃版本号小于本地缓存数据的同步事件
+		err := c.store.setRaw(e.Key, e.Val, e.Version, time.Duration(ttl)*time.Second)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", e, "event", adaptor.LogEventSyncAdd)
+			return
 		}
+		c.tags.add(e.Key, e.Tags)
 	case syncer.EventTypeDelete:
-		c.innerCache.Del(utils.Bytes(e.Key))
+		c.store.del(e.Key)
+		c.tags.remove(e.Key)
+	case syncer.EventTypeInvalidate:
+		// 对象实现Versioned接口时保留版本号不小于事件版本号的本地数据
+		if c.store.invalidate(e.Key, e.Version) {
+			c.tags.remove(e.Key)
+		}
+	case syncer.EventTypeBatchAdd, syncer.EventTypeBatchDelete, syncer.EventTypeBatchInvalidate:
+		c.batchMu.Lock()
+		applyBatch(c.store, c.tags, e, func(entry syncer.SyncEntry, err error) {
+			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "key", entry.Key, "event", adaptor.LogEventSyncAdd)
+		})
+		c.batchMu.Unlock()
+	case syncer.EventTypeFlush:
+		// 其他实例的前缀与本实例不同时忽略
+		if e.Key != "" && !strings.HasPrefix(e.Key, c.prefix) {
+			return
+		}
+		n := invalidatePrefix(c.store, c.tags, utils.IfExpr(e.Key == "", c.prefix, e.Key))
+		logger.Info("local cache flushed", "solution", c.solutionName, "adaptor", c.Name(), "prefix", e.Key, "count", n, "event", adaptor.LogEventSyncFlush)
+	case syncer.EventTypeInvalidateTag:
+		// 仅处理key前缀相同的适配器的事件
+		if e.Key != c.prefix {
+			return
+		}
+		invalidateTag(c.store, c.tags, e.Tag)
+	}
+}
+
+// stale 判断数据是否已软过期
+func (c *FreeCache[K, V]) stale(expireAt time.Time) bool {
+	if c.staleTTL <= 0 || expireAt.IsZero() {
+		return false
+	}
+	return time.Until(expireAt) < c.staleTTL
+}
+
+// SaveSnapshot 将本地缓存中的数据写入快照文件，返回写入条数，存储需实现Iterable接口
+func (c *FreeCache[K, V]) SaveSnapshot(path string) (int, error) {
+	return writeSnapshot(c.store, c.tags, c.prefix, path)
+}
+
+// LoadSnapshot 从快照文件恢复本地缓存，跳过已过期的数据，返回恢复条数
+func (c *FreeCache[K, V]) LoadSnapshot(path string) (int, error) {
+	return readSnapshot(c.store, c.tags, c.prefix, path)
+}
+
+// Close 取消数据同步订阅并停止定期快照，配置了快照路径时写入最后一次快照，可重复调用
+// Syncer可被多个适配器共享，需由创建方单独关闭
+func (c *FreeCache[K, V]) Close(ctx context.Context) error {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
 	}
+	return c.snapshot.close(ctx)
+}
+
+// hot 判断key是否为热点key
+func (c *FreeCache[K, V]) hot(key string) bool {
+	return c.hotKey != nil && c.hotKey.IsHot(key)
 }
 
 // key 生成缓存key
@@ -208,5 +370,14 @@ func (c *FreeCache[K, V]) key(key K) string {
 
 // key1 生成缓存key
 func (c *FreeCache[K, V]) key1(key string) string {
-	return c.prefix + key
+	return c.prefix + c.generation.Prefix() + key
+}
+
+// resolveTTL 按对象的Expiring接口及过期时间策略计算过期时间(秒)，freecache的过期时间精度为秒，不足1秒按1秒计算
+func resolveTTL(value adaptor.Metadata, ttl int, policy adaptor.TTLPolicy) int {
+	d := adaptor.ResolveTTL(value, time.Duration(ttl)*time.Second, policy)
+	if d > 0 && d < time.Second {
+		return 1
+	}
+	return int(d.Seconds())
 }
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coocood/freecache"
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/syncer"
@@ -16,10 +20,12 @@ import (
 
 // 类型检测
 var _ adaptor.MultiAdaptor[string, adaptor.Metadata] = (*MultiFreeCache[string, adaptor.Metadata])(nil)
+var _ adaptor.Closer = (*MultiFreeCache[string, adaptor.Metadata])(nil)
+var _ adaptor.Invalidator = (*MultiFreeCache[string, adaptor.Metadata])(nil)
 
-// FreeCache 基于freecache的本地多值缓存实现
+// MultiFreeCache 本地多值缓存实现，默认基于freecache，可通过Store/TypedStore替换存储
 type MultiFreeCache[K comparable, V adaptor.Metadata] struct {
-	innerCache   *freecache.Cache
+	store        storage[V]
 	prefix       string
 	ttl          time.Duration
 	threshold    time.Duration
@@ -29,10 +35,49 @@ type MultiFreeCache[K comparable, V adaptor.Metadata] struct {
 	solutionName string
 	ttlZero      time.Duration
 	syncer       syncer.Syncer
+	// 热点key探测器及热点key过期时间
+	hotKey *hotkey.Detector
+	hotTTL time.Duration
+	// 本地缓存快照
+	snapshot *snapshotter[V]
+	// 标签索引，对象未实现Tagged接口时为nil
+	tags *tagIndex
+	// 取消数据同步订阅
+	unsubscribe context.CancelFunc
+	// 批量同步事件单条消息的最大字节数及是否压缩
+	syncBatchBytes int
+	syncCompress   bool
+	// 写入数据的同步方式
+	syncMode SyncMode
+	// 缓存代数，拼接至key前缀之后
+	generation *generation.Generation
+	// 过期时间策略
+	ttlPolicy adaptor.TTLPolicy
+	// 应用批量同步事件时持有写锁，读取时持有读锁，保证批量事件对读取整体可见
+	batchMu sync.RWMutex
 }
 
 // NewMultiFreeCache 多值本地缓存
+// icache为空时，配置了WithQuota则使用按场景名称隔离的freecache实例，否则使用全局共享实例
 func NewMultiFreeCache[K comparable, V adaptor.Metadata](icache *freecache.Cache, preAdaptor adaptor.MultiAdaptor[K, V], fns ...LocalCacheOptionFunc) *MultiFreeCache[K, V] {
+	if icache == nil {
+		icache = solutionFreeCache(fns...)
+	}
+	return newMultiLocalCache[K, V](newByteStorage[V](NewFreeCacheStore(icache)), preAdaptor, fns...)
+}
+
+// NewMultiLocalCache 创建基于字节存储的本地多值缓存对象
+func NewMultiLocalCache[K comparable, V adaptor.Metadata](store Store, preAdaptor adaptor.MultiAdaptor[K, V], fns ...LocalCacheOptionFunc) *MultiFreeCache[K, V] {
+	return newMultiLocalCache[K, V](newByteStorage[V](store), preAdaptor, fns...)
+}
+
+// NewTypedMultiLocalCache 创建基于对象存储的本地多值缓存对象，读取时无需反序列化
+func NewTypedMultiLocalCache[K comparable, V adaptor.Metadata](store TypedStore[V], preAdaptor adaptor.MultiAdaptor[K, V], fns ...LocalCacheOptionFunc) *MultiFreeCache[K, V] {
+	return newMultiLocalCache[K, V](newTypedStorage[V](store), preAdaptor, fns...)
+}
+
+// newMultiLocalCache 创建本地多值缓存对象
+func newMultiLocalCache[K comparable, V adaptor.Metadata](store storage[V], preAdaptor adaptor.MultiAdaptor[K, V], fns ...LocalCacheOptionFunc) *MultiFreeCache[K, V] {
 	// 默认+自定义配置
 	opts := DefaultLocalCacheOption()
 	for _, fn := range fns {
@@ -40,7 +85,7 @@ func NewMultiFreeCache[K comparable, V adaptor.Metadata](icache *freecache.Cache
 	}
 
 	multiCacheInst := &MultiFreeCache[K, V]{
-		innerCache:   icache,
+		store:        store,
 		prefix:       opts.Prefix,
 		ttl:          opts.TTL,
 		threshold:    opts.Threshold,
@@ -50,11 +95,30 @@ func NewMultiFreeCache[K comparable, V adaptor.Metadata](icache *freecache.Cache
 		solutionName: opts.SolutionName,
 		ttlZero:      opts.TTLZero,
 		syncer:       opts.Syncer,
+		hotKey:       opts.HotKey,
+		hotTTL:       opts.HotTTL,
+		// 批量同步
+		syncBatchBytes: opts.SyncBatchBytes,
+		syncCompress:   opts.SyncCompress,
+		syncMode:       opts.SyncMode,
+		generation:     opts.Generation,
+		ttlPolicy:      opts.TTLPolicy,
 	}
 
+	// 标签索引，需在快照恢复前创建，恢复时重建索引
+	multiCacheInst.tags = newTagIndex(store)
+
+	// 从快照恢复并启动定期快照
+	multiCacheInst.snapshot = newSnapshotter(store, multiCacheInst.tags, opts)
+
 	// 订阅数据同步事件
 	if multiCacheInst.syncer != nil {
-		multiCacheInst.syncer.Subscribe(context.Background(), multiCacheInst.sync)
+		ctx, cancel := context.WithCancel(context.Background())
+		multiCacheInst.unsubscribe = cancel
+		err := multiCacheInst.syncer.Subscribe(ctx, multiCacheInst.sync)
+		if err != nil {
+			logger.Error(err.Error(), "solution", opts.SolutionName, "adaptor", opts.Name, "event", adaptor.LogEventSync)
+		}
 	}
 
 	return multiCacheInst
@@ -71,10 +135,22 @@ func (c *MultiFreeCache[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], va
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
 	hasKeys := make(adaptor.Keys[K], 0)
 	hasValues := make(adaptor.ValueCol[V], 0)
+	// 整个读取过程持有读锁，不会读到仅应用了一部分的批量同步事件
+	c.batchMu.RLock()
 	for _, key := range keys {
 		startTime := time.Now()
-		buf, err := c.innerCache.Get(utils.Bytes(c.key(key)))
-		if errors.Is(err, freecache.ErrNotFound) {
+		// 跳过，热点key仍从本地缓存读取
+		if c.skipGet && !c.hot(fmt.Sprint(key)) {
+			metric.AddMeta(ctx, metrics.Meta{
+				AdaptorName: c.Name(),
+				Key:         fmt.Sprint(key),
+				Type:        metrics.Miss,
+			})
+			continue
+		}
+		// 读取并反序列化对象
+		val, _, err := c.store.load(c.key(key), fn)
+		if errors.Is(err, ErrNotFound) {
 			// key不存在
 			metric.AddMeta(ctx, metrics.Meta{
 				AdaptorName: c.Name(),
@@ -84,11 +160,14 @@ func (c *MultiFreeCache[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], va
 			continue
 		}
 		if err != nil {
-			return hasKeys, err
+			metric.AddMeta(ctx, metrics.Meta{
+				AdaptorName: c.Name(),
+				Key:         fmt.Sprint(key),
+				Type:        metrics.Miss,
+			})
+			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "key", key, "event", adaptor.LogEventGet)
+			continue
 		}
-		// 反序列化对象
-		val := fn()
-		val.Decode(buf)
 		vals[key] = val
 		hasKeys = append(hasKeys, key)
 		hasValues = append(hasValues, val)
@@ -101,9 +180,10 @@ func (c *MultiFreeCache[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], va
 		})
 
 	}
+	c.batchMu.RUnlock()
 
 	if c.preAdaptor != nil && len(hasValues) > 0 {
-		err := c.preAdaptor.Set(ctx, hasValues)
+		err := c.preAdaptor.Set(adaptor.WithRefill(ctx), hasValues)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", hasValues, "event", adaptor.LogEventRefill)
 		}
@@ -115,36 +195,43 @@ func (c *MultiFreeCache[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], va
 // Set 写入对象
 func (c *MultiFreeCache[K, V]) Set(ctx context.Context, vals adaptor.ValueCol[V]) error {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
+	var entries []syncer.SyncEntry
 	for _, val := range vals {
 		startTime := time.Now()
-		// 序列化对象
 		key := val.Key()
-		buf, err := val.Value()
-		if err != nil {
-			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", val, "event", adaptor.LogEventSet)
-			continue
-		}
 		// 写入缓存
 		ttl := int(c.ttl.Seconds()) + utils.SafeRand().Intn(int(c.threshold.Seconds()))
+		if c.hotTTL > 0 && c.hot(key) {
+			ttl = int(c.hotTTL.Seconds())
+		}
+		ttl = resolveTTL(val, ttl, c.ttlPolicy)
 		ttl = utils.IfExpr(val.Zero(), int(c.ttlZero.Seconds()), ttl)
-		err = c.innerCache.Set(utils.Bytes(c.key1(key)), buf, ttl)
+		// 对象实现Versioned接口时，缓存中已有更新版本的数据则放弃写入
+		written, buf, err := c.store.set(c.key1(key), val, time.Duration(ttl)*time.Second, c.syncer != nil && c.syncMode == SyncValue)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", val, "event", adaptor.LogEventSet)
 			continue
 		}
+		if !written {
+			continue
+		}
+		c.tags.add(c.key1(key), adaptor.TagsOf(val))
 
-		// 同步缓存数据写入操作
-		if c.syncer != nil {
-			setEvent := &syncer.CacheSyncEvent{
-				EventType: syncer.EventTypeAdd,
-				Key:       c.key1(key),
-				Val:       buf,
-				TTL:       time.Duration(ttl) * time.Second,
+		// 同步缓存数据写入操作，全部写入后批量广播
+		// 失效模式下读取路径的回填未变更数据，无需通知其他实例
+		if c.syncer != nil && !(c.syncMode == SyncInvalidate && adaptor.IsRefill(ctx)) {
+			entry := syncer.SyncEntry{
+				Key:     c.key1(key),
+				Val:     buf,
+				TTL:     time.Duration(ttl) * time.Second,
+				Version: adaptor.VersionOf(val),
+				Tags:    adaptor.TagsOf(val),
 			}
-			err := c.syncer.Emit(ctx, setEvent)
-			if err != nil {
-				logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", setEvent.Encode(), "event", adaptor.LogEventSync)
+			// 失效模式仅广播key及版本号
+			if c.syncMode == SyncInvalidate {
+				entry = syncer.SyncEntry{Key: entry.Key, Version: entry.Version}
 			}
+			entries = append(entries, entry)
 		}
 
 		metric.AddMeta(ctx, metrics.Meta{
@@ -155,32 +242,65 @@ func (c *MultiFreeCache[K, V]) Set(ctx context.Context, vals adaptor.ValueCol[V]
 		})
 
 	}
+	c.emit(ctx, utils.IfExpr(c.syncMode == SyncInvalidate, syncer.EventTypeBatchInvalidate, syncer.EventTypeBatchAdd), entries)
 	return nil
 }
 
 // Del 删除对象
 func (c *MultiFreeCache[K, V]) Del(ctx context.Context, keys adaptor.Keys[K]) error {
+	var entries []syncer.SyncEntry
 	for _, key := range keys {
-
 		// 删除本地缓存数据
-		c.innerCache.Del(utils.Bytes(c.key(key)))
-
-		// 同步缓存数据删除操作
+		c.store.del(c.key(key))
+		c.tags.remove(c.key(key))
 		if c.syncer != nil {
-			delEvent := &syncer.CacheSyncEvent{
-				EventType: syncer.EventTypeDelete,
-				Key:       c.key(key),
-			}
-			err := c.syncer.Emit(ctx, delEvent)
-			if err != nil {
-				logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", delEvent.Encode(), "event", adaptor.LogEventSync)
-			}
+			entries = append(entries, syncer.SyncEntry{Key: c.key(key)})
 		}
-
 	}
+	// 同步缓存数据删除操作
+	c.emit(ctx, syncer.EventTypeBatchDelete, entries)
 	return nil
 }
 
+// InvalidateTag 删除带有tag标签的本地数据，并通过Syncer广播至其他实例，返回本实例删除条数
+func (c *MultiFreeCache[K, V]) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	n := invalidateTag(c.store, c.tags, tag)
+	c.emitEvent(ctx, &syncer.CacheSyncEvent{EventType: syncer.EventTypeInvalidateTag, Key: c.prefix, Tag: tag})
+	return n, nil
+}
+
+// InvalidatePrefix 删除key以prefix开头的本地数据，并通过Syncer广播EventTypeFlush事件至其他实例，返回本实例删除条数
+// 存储未实现Iterable接口时清空整个存储
+func (c *MultiFreeCache[K, V]) InvalidatePrefix(ctx context.Context, prefix string) (int, error) {
+	n := invalidatePrefix(c.store, c.tags, c.key1(prefix))
+	c.emitEvent(ctx, &syncer.CacheSyncEvent{EventType: syncer.EventTypeFlush, Key: c.key1(prefix)})
+	return n, nil
+}
+
+// emitEvent 广播单条同步事件，失败时记录日志
+func (c *MultiFreeCache[K, V]) emitEvent(ctx context.Context, e *syncer.CacheSyncEvent) {
+	if c.syncer == nil {
+		return
+	}
+	if err := c.syncer.Emit(ctx, e); err != nil {
+		logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", e.Encode(), "event", adaptor.LogEventSync)
+	}
+}
+
+// emit 批量广播同步事件
+func (c *MultiFreeCache[K, V]) emit(ctx context.Context, eventType syncer.EventType, entries []syncer.SyncEntry) {
+	if c.syncer == nil || len(entries) == 0 {
+		return
+	}
+	emitBatch(ctx, c.syncer, eventType, entries, c.syncBatchBytes, c.syncCompress, func(e *syncer.CacheSyncEvent, err error) {
+		if e.Batch() {
+			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "count", len(e.Entries), "event", adaptor.LogEventSync)
+			return
+		}
+		logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", e.Encode(), "event", adaptor.LogEventSync)
+	})
+}
+
 // sync 数据同步
 func (c *MultiFreeCache[K, V]) sync(e *syncer.CacheSyncEvent) {
 	if c.syncer == nil {
@@ -192,13 +312,65 @@ func (c *MultiFreeCache[K, V]) sync(e *syncer.CacheSyncEvent) {
 	switch e.EventType {
 	case syncer.EventTypeAdd:
 		ttl := int(e.TTL.Seconds())
-		err := c.innerCache.Set(utils.Bytes(e.Key), e.Val, ttl)
+		// 对象实现Versioned接口时丢弃版本号小于本地缓存数据的同步事件
+		err := c.store.setRaw(e.Key, e.Val, e.Version, time.Duration(ttl)*time.Second)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", e, "event", adaptor.LogEventSyncAdd)
+			return
 		}
+		c.tags.add(e.Key, e.Tags)
 	case syncer.EventTypeDelete:
-		c.innerCache.Del(utils.Bytes(e.Key))
+		c.store.del(e.Key)
+		c.tags.remove(e.Key)
+	case syncer.EventTypeInvalidate:
+		// 对象实现Versioned接口时保留版本号不小于事件版本号的本地数据
+		if c.store.invalidate(e.Key, e.Version) {
+			c.tags.remove(e.Key)
+		}
+	case syncer.EventTypeBatchAdd, syncer.EventTypeBatchDelete, syncer.EventTypeBatchInvalidate:
+		c.batchMu.Lock()
+		applyBatch(c.store, c.tags, e, func(entry syncer.SyncEntry, err error) {
+			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "key", entry.Key, "event", adaptor.LogEventSyncAdd)
+		})
+		c.batchMu.Unlock()
+	case syncer.EventTypeFlush:
+		// 其他实例的前缀与本实例不同时忽略
+		if e.Key != "" && !strings.HasPrefix(e.Key, c.prefix) {
+			return
+		}
+		n := invalidatePrefix(c.store, c.tags, utils.IfExpr(e.Key == "", c.prefix, e.Key))
+		logger.Info("local cache flushed", "solution", c.solutionName, "adaptor", c.Name(), "prefix", e.Key, "count", n, "event", adaptor.LogEventSyncFlush)
+	case syncer.EventTypeInvalidateTag:
+		// 仅处理key前缀相同的适配器的事件
+		if e.Key != c.prefix {
+			return
+		}
+		invalidateTag(c.store, c.tags, e.Tag)
+	}
+}
+
+// SaveSnapshot 将本地缓存中的数据写入快照文件，返回写入条数，存储需实现Iterable接口
+func (c *MultiFreeCache[K, V]) SaveSnapshot(path string) (int, error) {
+	return writeSnapshot(c.store, c.tags, c.prefix, path)
+}
+
+// LoadSnapshot 从快照文件恢复本地缓存，跳过已过期的数据，返回恢复条数
+func (c *MultiFreeCache[K, V]) LoadSnapshot(path string) (int, error) {
+	return readSnapshot(c.store, c.tags, c.prefix, path)
+}
+
+// Close 取消数据同步订阅并停止定期快照，配置了快照路径时写入最后一次快照，可重复调用
+// Syncer可被多个适配器共享，需由创建方单独关闭
+func (c *MultiFreeCache[K, V]) Close(ctx context.Context) error {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
 	}
+	return c.snapshot.close(ctx)
+}
+
+// hot 判断key是否为热点key
+func (c *MultiFreeCache[K, V]) hot(key string) bool {
+	return c.hotKey != nil && c.hotKey.IsHot(key)
 }
 
 // key 生成缓存key
@@ -208,5 +380,5 @@ func (c *MultiFreeCache[K, V]) key(key K) string {
 
 // key1 生成缓存key
 func (c *MultiFreeCache[K, V]) key1(key string) string {
-	return c.prefix + key
+	return c.prefix + c.generation.Prefix() + key
 }
@@ -0,0 +1,90 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/rumis/multicache/utils"
+)
+
+// 类型检测
+var _ Store = (*FreeCacheStore)(nil)
+var _ Iterable[[]byte] = (*FreeCacheStore)(nil)
+
+// FreeCacheStore 基于freecache的字节存储
+type FreeCacheStore struct {
+	innerCache *freecache.Cache
+}
+
+// NewFreeCacheStore 创建基于freecache的字节存储
+func NewFreeCacheStore(icache *freecache.Cache) *FreeCacheStore {
+	return &FreeCacheStore{
+		innerCache: icache,
+	}
+}
+
+// Get 读取数据
+func (s *FreeCacheStore) Get(key string) ([]byte, time.Time, error) {
+	buf, expireAt, err := s.innerCache.GetWithExpiration(utils.Bytes(key))
+	if errors.Is(err, freecache.ErrNotFound) {
+		return nil, time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if expireAt == 0 {
+		return buf, time.Time{}, nil
+	}
+	return buf, time.Unix(int64(expireAt), 0), nil
+}
+
+// Set 写入数据，freecache的过期时间精度为秒
+func (s *FreeCacheStore) Set(key string, val []byte, ttl time.Duration) error {
+	err := s.innerCache.Set(utils.Bytes(key), val, int(ttl.Seconds()))
+	if errors.Is(err, freecache.ErrLargeEntry) {
+		// 删除key原有的数据，避免继续读取到旧数据
+		s.innerCache.Del(utils.Bytes(key))
+		return fmt.Errorf("%w: %w", ErrTooLarge, err)
+	}
+	return err
+}
+
+// Update 在freecache分段锁内读取数据并按需替换
+func (s *FreeCacheStore) Update(key string, fn UpdateFunc[[]byte]) (bool, error) {
+	_, replaced, err := s.innerCache.Update(utils.Bytes(key), func(value []byte, found bool) ([]byte, bool, int) {
+		val, replace, ttl := fn(value, found)
+		return val, replace, int(ttl.Seconds())
+	})
+	return replaced, err
+}
+
+// Del 删除数据
+func (s *FreeCacheStore) Del(key string) bool {
+	return s.innerCache.Del(utils.Bytes(key))
+}
+
+// Clear 清空数据
+func (s *FreeCacheStore) Clear() {
+	s.innerCache.Clear()
+}
+
+// Len 数据条数
+func (s *FreeCacheStore) Len() int {
+	return int(s.innerCache.EntryCount())
+}
+
+// Range 遍历未过期的数据，共享freecache实例时包含其他场景的数据
+func (s *FreeCacheStore) Range(fn func(key string, val []byte, expireAt time.Time) bool) {
+	iter := s.innerCache.NewIterator()
+	for entry := iter.Next(); entry != nil; entry = iter.Next() {
+		var expireAt time.Time
+		if entry.ExpireAt > 0 {
+			expireAt = time.Unix(int64(entry.ExpireAt), 0)
+		}
+		if !fn(string(entry.Key), entry.Value, expireAt) {
+			return
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// 类型检测
+var _ adaptor.Inspector[string] = (*FreeCache[string, adaptor.Metadata])(nil)
+var _ adaptor.Inspector[string] = (*MultiFreeCache[string, adaptor.Metadata])(nil)
+
+// Inspect 查询key的剩余过期时间及数据大小，对象存储返回估算的内存占用
+func (c *FreeCache[K, V]) Inspect(ctx context.Context, key K) (adaptor.EntryInfo, error) {
+	return inspect(c.store, c.Name(), c.key(key))
+}
+
+// Inspect 查询key的剩余过期时间及数据大小，对象存储返回估算的内存占用
+func (c *MultiFreeCache[K, V]) Inspect(ctx context.Context, key K) (adaptor.EntryInfo, error) {
+	return inspect(c.store, c.Name(), c.key(key))
+}
+
+// inspect 查询存储层中key的状态
+func inspect[V adaptor.Metadata](store storage[V], name string, key string) (adaptor.EntryInfo, error) {
+	info := adaptor.EntryInfo{Adaptor: name, Supported: true}
+	size, expireAt, err := store.inspect(key)
+	if errors.Is(err, ErrNotFound) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.Found = true
+	info.Size = size
+	if !expireAt.IsZero() {
+		info.TTL = time.Until(expireAt)
+	}
+	return info, nil
+}
@@ -0,0 +1,181 @@
+package local
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/utils"
+)
+
+// 类型检测
+var _ TypedStore[adaptor.Metadata] = (*MapStore[adaptor.Metadata])(nil)
+var _ Iterable[adaptor.Metadata] = (*MapStore[adaptor.Metadata])(nil)
+
+// mapStoreEvictSamples 容量已满时单次淘汰及写入新key时清理过期对象的采样数量
+const mapStoreEvictSamples = 16
+
+// mapEntry 对象存储条目
+type mapEntry[V adaptor.Metadata] struct {
+	val      V
+	expireAt time.Time
+}
+
+// MapStore 基于进程内map的对象存储，保存反序列化后的对象，写入及读取时浅拷贝对象
+// 容量已满时优先淘汰采样到的过期对象，无过期对象时随机淘汰；容量未满时写入新key同样采样清理过期对象
+type MapStore[V adaptor.Metadata] struct {
+	mu         sync.RWMutex
+	items      map[string]mapEntry[V]
+	maxEntries int
+}
+
+// NewMapStore 创建对象存储，maxEntries不大于零表示不限制对象数量
+func NewMapStore[V adaptor.Metadata](maxEntries int) *MapStore[V] {
+	return &MapStore[V]{
+		items:      make(map[string]mapEntry[V]),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get 读取对象
+func (s *MapStore[V]) Get(key string) (V, time.Time, error) {
+	s.mu.RLock()
+	e, ok := s.items[key]
+	s.mu.RUnlock()
+	if !ok || e.expired(time.Now()) {
+		var zero V
+		return zero, time.Time{}, ErrNotFound
+	}
+	return shallowCopy(e.val), e.expireAt, nil
+}
+
+// Set 写入对象
+func (s *MapStore[V]) Set(key string, val V, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, shallowCopy(val), ttl)
+	return nil
+}
+
+// Update 在锁内读取对象并按需替换
+func (s *MapStore[V]) Update(key string, fn UpdateFunc[V]) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.items[key]
+	if found && e.expired(time.Now()) {
+		found = false
+		e = mapEntry[V]{}
+	}
+	val, replace, ttl := fn(e.val, found)
+	if !replace {
+		return false, nil
+	}
+	s.set(key, shallowCopy(val), ttl)
+	return true, nil
+}
+
+// Del 删除对象
+func (s *MapStore[V]) Del(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[key]
+	delete(s.items, key)
+	return ok
+}
+
+// Clear 清空对象
+func (s *MapStore[V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = make(map[string]mapEntry[V])
+}
+
+// Len 对象数量，包含已过期但尚未淘汰的对象
+func (s *MapStore[V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
+
+// Range 遍历未过期的对象
+// 锁内仅复制条目，释放锁后再回调，快照序列化期间不阻塞读写
+func (s *MapStore[V]) Range(fn func(key string, val V, expireAt time.Time) bool) {
+	s.mu.RLock()
+	now := time.Now()
+	entries := make([]rangeEntry[V], 0, len(s.items))
+	for key, e := range s.items {
+		if e.expired(now) {
+			continue
+		}
+		entries = append(entries, rangeEntry[V]{key: key, val: e.val, expireAt: e.expireAt})
+	}
+	s.mu.RUnlock()
+	rangeEntries(entries, fn)
+}
+
+// set 写入对象，调用方需持有写锁
+// 写入新key时采样清理过期对象，避免不限制对象数量时写入后不再读取的过期对象无限堆积
+func (s *MapStore[V]) set(key string, val V, ttl time.Duration) {
+	if _, ok := s.items[key]; !ok {
+		if s.maxEntries > 0 && len(s.items) >= s.maxEntries {
+			s.evict()
+		} else {
+			s.expire(time.Now())
+		}
+	}
+	e := mapEntry[V]{val: val}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	s.items[key] = e
+}
+
+// evict 淘汰对象，调用方需持有写锁
+func (s *MapStore[V]) evict() {
+	now := time.Now()
+	victim := ""
+	n := 0
+	for key, e := range s.items {
+		if e.expired(now) {
+			delete(s.items, key)
+			continue
+		}
+		if n == 0 {
+			victim = key
+		}
+		n++
+		if n >= mapStoreEvictSamples {
+			break
+		}
+	}
+	// 采样中无过期对象时淘汰首个采样对象，map的遍历顺序随机
+	if len(s.items) >= s.maxEntries && n > 0 {
+		delete(s.items, victim)
+	}
+}
+
+// expire 采样删除过期对象，调用方需持有写锁
+func (s *MapStore[V]) expire(now time.Time) {
+	n := 0
+	for key, e := range s.items {
+		if e.expired(now) {
+			delete(s.items, key)
+		}
+		n++
+		if n >= mapStoreEvictSamples {
+			return
+		}
+	}
+}
+
+// expired 判断对象是否已过期
+func (e mapEntry[V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+// shallowCopy 浅拷贝对象
+func shallowCopy[V adaptor.Metadata](val V) V {
+	cp := utils.NewOf(val)
+	utils.Assign(cp, val)
+	return cp
+}
@@ -0,0 +1,428 @@
+package local
+
+import (
+	"container/heap"
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/utils"
+)
+
+// 类型检测
+var _ TypedStore[adaptor.Metadata] = (*ObjectStore[adaptor.Metadata])(nil)
+var _ Iterable[adaptor.Metadata] = (*ObjectStore[adaptor.Metadata])(nil)
+var _ Assigner[adaptor.Metadata] = (*ObjectStore[adaptor.Metadata])(nil)
+
+// EvictionPolicy 淘汰策略
+type EvictionPolicy int
+
+const (
+	// EvictionLRU 淘汰最近最少使用的对象
+	EvictionLRU EvictionPolicy = iota
+	// EvictionLFU 淘汰访问次数最少的对象，访问次数相同时淘汰最久未访问的对象
+	EvictionLFU
+)
+
+// ObjectStoreOption 对象存储选项
+type ObjectStoreOption[V adaptor.Metadata] struct {
+	// 对象总开销上限，不大于零表示不限制
+	MaxCost Size
+	// 淘汰策略
+	Eviction EvictionPolicy
+	// 对象开销计算函数，默认按反射估算对象占用的内存
+	Cost func(V) int64
+	// 对象复制函数，写入及读取时复制对象，为空且未声明不可变时通过Value/Decode序列化复制
+	Clone func(V) V
+	// 对象不可变，写入及读取时均不复制对象，调用方需保证写入后及读取到的对象不被修改
+	Immutable bool
+}
+
+// ObjectStoreOptionFunc 对象存储配置函数
+type ObjectStoreOptionFunc[V adaptor.Metadata] func(*ObjectStoreOption[V])
+
+// DefaultObjectStoreOption 默认对象存储配置
+func DefaultObjectStoreOption[V adaptor.Metadata]() ObjectStoreOption[V] {
+	return ObjectStoreOption[V]{
+		MaxCost:  64 * MB,
+		Eviction: EvictionLRU,
+	}
+}
+
+// WithMaxCost 设置对象总开销上限
+func WithMaxCost[V adaptor.Metadata](maxCost Size) ObjectStoreOptionFunc[V] {
+	return func(option *ObjectStoreOption[V]) {
+		option.MaxCost = maxCost
+	}
+}
+
+// WithEviction 设置淘汰策略
+func WithEviction[V adaptor.Metadata](policy EvictionPolicy) ObjectStoreOptionFunc[V] {
+	return func(option *ObjectStoreOption[V]) {
+		option.Eviction = policy
+	}
+}
+
+// WithCost 设置对象开销计算函数
+func WithCost[V adaptor.Metadata](fn func(V) int64) ObjectStoreOptionFunc[V] {
+	return func(option *ObjectStoreOption[V]) {
+		option.Cost = fn
+	}
+}
+
+// WithClone 设置对象复制函数
+func WithClone[V adaptor.Metadata](fn func(V) V) ObjectStoreOptionFunc[V] {
+	return func(option *ObjectStoreOption[V]) {
+		option.Clone = fn
+	}
+}
+
+// WithImmutable 声明对象不可变，读写均不复制对象
+func WithImmutable[V adaptor.Metadata]() ObjectStoreOptionFunc[V] {
+	return func(option *ObjectStoreOption[V]) {
+		option.Immutable = true
+	}
+}
+
+// objectEntry 对象存储条目
+type objectEntry[V adaptor.Metadata] struct {
+	key      string
+	val      V
+	expireAt time.Time
+	cost     int64
+	// LRU链表节点
+	elem *list.Element
+	// LFU访问次数、最近访问序号及堆中下标
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+// ObjectStore 保存对象本身的本地对象存储，支持过期时间、开销上限及LRU/LFU淘汰
+// 对象通过Clone复制或声明为不可变后不复制，读取命中时无需反序列化；两者均未配置时通过Value/Decode复制，
+// 避免对象中的切片、map及指针在存储与调用方之间共享，但无法省去序列化开销
+type ObjectStore[V adaptor.Metadata] struct {
+	mu      sync.Mutex
+	items   map[string]*objectEntry[V]
+	evictor evictor[V]
+	cost    int64
+	opts    ObjectStoreOption[V]
+}
+
+// NewObjectStore 创建对象存储
+func NewObjectStore[V adaptor.Metadata](fns ...ObjectStoreOptionFunc[V]) *ObjectStore[V] {
+	opts := DefaultObjectStoreOption[V]()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	if opts.Cost == nil {
+		opts.Cost = func(v V) int64 {
+			return utils.SizeOf(v)
+		}
+	}
+	return &ObjectStore[V]{
+		items:   make(map[string]*objectEntry[V]),
+		evictor: newEvictor[V](opts.Eviction),
+		opts:    opts,
+	}
+}
+
+// Get 读取对象
+func (s *ObjectStore[V]) Get(key string) (V, time.Time, error) {
+	s.mu.Lock()
+	e, ok := s.items[key]
+	if ok && e.expired(time.Now()) {
+		s.remove(e)
+		ok = false
+	}
+	if !ok {
+		s.mu.Unlock()
+		var zero V
+		return zero, time.Time{}, ErrNotFound
+	}
+	s.evictor.touch(e)
+	val, expireAt := e.val, e.expireAt
+	s.mu.Unlock()
+	val, err := s.copy(val)
+	return val, expireAt, err
+}
+
+// GetInto 读取对象并复制至dst
+func (s *ObjectStore[V]) GetInto(key string, dst V) (time.Time, error) {
+	s.mu.Lock()
+	e, ok := s.items[key]
+	if ok && e.expired(time.Now()) {
+		s.remove(e)
+		ok = false
+	}
+	if !ok {
+		s.mu.Unlock()
+		return time.Time{}, ErrNotFound
+	}
+	s.evictor.touch(e)
+	val, expireAt := e.val, e.expireAt
+	s.mu.Unlock()
+	return expireAt, s.copyInto(dst, val)
+}
+
+// Set 写入对象，开销超过上限的对象不写入并删除key原有的对象，返回ErrTooLarge
+func (s *ObjectStore[V]) Set(key string, val V, ttl time.Duration) error {
+	val, err := s.copy(val)
+	if err != nil {
+		return err
+	}
+	cost := s.opts.Cost(val) + int64(len(key))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(key, val, ttl, cost)
+}
+
+// Update 在锁内读取对象并按需替换
+func (s *ObjectStore[V]) Update(key string, fn UpdateFunc[V]) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cur V
+	e, found := s.items[key]
+	if found && e.expired(time.Now()) {
+		s.remove(e)
+		found = false
+	}
+	if found {
+		cur = e.val
+	}
+	val, replace, ttl := fn(cur, found)
+	if !replace {
+		return false, nil
+	}
+	val, err := s.copy(val)
+	if err != nil {
+		return false, err
+	}
+	if err := s.set(key, val, ttl, s.opts.Cost(val)+int64(len(key))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Del 删除对象
+func (s *ObjectStore[V]) Del(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if ok {
+		s.remove(e)
+	}
+	return ok
+}
+
+// Clear 清空对象
+func (s *ObjectStore[V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = make(map[string]*objectEntry[V])
+	s.evictor = newEvictor[V](s.opts.Eviction)
+	s.cost = 0
+}
+
+// Len 对象数量
+func (s *ObjectStore[V]) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// Range 遍历未过期的对象，不更新淘汰顺序
+// 锁内仅复制条目，释放锁后再回调，快照序列化期间不阻塞读写
+func (s *ObjectStore[V]) Range(fn func(key string, val V, expireAt time.Time) bool) {
+	s.mu.Lock()
+	now := time.Now()
+	entries := make([]rangeEntry[V], 0, len(s.items))
+	for key, e := range s.items {
+		if e.expired(now) {
+			continue
+		}
+		entries = append(entries, rangeEntry[V]{key: key, val: e.val, expireAt: e.expireAt})
+	}
+	s.mu.Unlock()
+	rangeEntries(entries, fn)
+}
+
+// Cost 当前对象总开销
+func (s *ObjectStore[V]) Cost() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cost
+}
+
+// set 写入对象并按淘汰策略淘汰超出开销上限的对象，调用方需持有锁
+// 开销超过上限时删除key原有的对象并返回ErrTooLarge，避免继续读取到旧对象
+func (s *ObjectStore[V]) set(key string, val V, ttl time.Duration, cost int64) error {
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	maxCost := int64(s.opts.MaxCost)
+	if maxCost > 0 && cost > maxCost {
+		return ErrTooLarge
+	}
+	e := &objectEntry[V]{
+		key:  key,
+		val:  val,
+		cost: cost,
+	}
+	if ttl > 0 {
+		e.expireAt = time.Now().Add(ttl)
+	}
+	for maxCost > 0 && s.cost+cost > maxCost {
+		s.remove(s.evictor.victim())
+	}
+	s.items[key] = e
+	s.evictor.push(e)
+	s.cost += cost
+	return nil
+}
+
+// remove 删除对象，调用方需持有锁
+func (s *ObjectStore[V]) remove(e *objectEntry[V]) {
+	delete(s.items, e.key)
+	s.evictor.remove(e)
+	s.cost -= e.cost
+}
+
+// copy 按选项复制对象
+func (s *ObjectStore[V]) copy(val V) (V, error) {
+	if s.opts.Immutable {
+		return val, nil
+	}
+	if s.opts.Clone != nil {
+		return s.opts.Clone(val), nil
+	}
+	cp := utils.NewOf(val)
+	return cp, s.copyInto(cp, val)
+}
+
+// copyInto 按选项将对象复制至dst，未配置Clone且未声明不可变时直接反序列化至dst，仅复制一次
+func (s *ObjectStore[V]) copyInto(dst V, val V) error {
+	if s.opts.Immutable {
+		utils.Assign(dst, val)
+		return nil
+	}
+	if s.opts.Clone != nil {
+		utils.Assign(dst, s.opts.Clone(val))
+		return nil
+	}
+	buf, err := val.Value()
+	if err != nil {
+		return err
+	}
+	return dst.Decode(buf)
+}
+
+// expired 判断对象是否已过期
+func (e *objectEntry[V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+// evictor 淘汰策略实现
+type evictor[V adaptor.Metadata] interface {
+	// push 加入新对象
+	push(e *objectEntry[V])
+	// touch 记录对象访问
+	touch(e *objectEntry[V])
+	// remove 移除对象
+	remove(e *objectEntry[V])
+	// victim 选择淘汰对象
+	victim() *objectEntry[V]
+}
+
+// newEvictor 创建淘汰策略实现
+func newEvictor[V adaptor.Metadata](policy EvictionPolicy) evictor[V] {
+	if policy == EvictionLFU {
+		return &lfuEvictor[V]{}
+	}
+	return &lruEvictor[V]{ll: list.New()}
+}
+
+// lruEvictor LRU淘汰，链表头部为最近访问的对象
+type lruEvictor[V adaptor.Metadata] struct {
+	ll *list.List
+}
+
+func (l *lruEvictor[V]) push(e *objectEntry[V]) {
+	e.elem = l.ll.PushFront(e)
+}
+
+func (l *lruEvictor[V]) touch(e *objectEntry[V]) {
+	l.ll.MoveToFront(e.elem)
+}
+
+func (l *lruEvictor[V]) remove(e *objectEntry[V]) {
+	l.ll.Remove(e.elem)
+}
+
+func (l *lruEvictor[V]) victim() *objectEntry[V] {
+	return l.ll.Back().Value.(*objectEntry[V])
+}
+
+// lfuEvictor LFU淘汰，按访问次数及最近访问序号组成小顶堆
+type lfuEvictor[V adaptor.Metadata] struct {
+	entries []*objectEntry[V]
+	clock   uint64
+}
+
+func (l *lfuEvictor[V]) push(e *objectEntry[V]) {
+	l.clock++
+	e.freq, e.tick = 1, l.clock
+	heap.Push(l, e)
+}
+
+func (l *lfuEvictor[V]) touch(e *objectEntry[V]) {
+	l.clock++
+	e.freq++
+	e.tick = l.clock
+	heap.Fix(l, e.index)
+}
+
+func (l *lfuEvictor[V]) remove(e *objectEntry[V]) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfuEvictor[V]) victim() *objectEntry[V] {
+	return l.entries[0]
+}
+
+// Len 实现heap.Interface
+func (l *lfuEvictor[V]) Len() int {
+	return len(l.entries)
+}
+
+// Less 实现heap.Interface
+func (l *lfuEvictor[V]) Less(i, j int) bool {
+	if l.entries[i].freq != l.entries[j].freq {
+		return l.entries[i].freq < l.entries[j].freq
+	}
+	return l.entries[i].tick < l.entries[j].tick
+}
+
+// Swap 实现heap.Interface
+func (l *lfuEvictor[V]) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+// Push 实现heap.Interface
+func (l *lfuEvictor[V]) Push(x any) {
+	e := x.(*objectEntry[V])
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+// Pop 实现heap.Interface
+func (l *lfuEvictor[V]) Pop() any {
+	n := len(l.entries)
+	e := l.entries[n-1]
+	l.entries[n-1] = nil
+	l.entries = l.entries[:n-1]
+	return e
+}
@@ -3,7 +3,11 @@ package local
 import (
 	"time"
 
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
 	"github.com/rumis/multicache/syncer"
+	"github.com/rumis/multicache/utils"
 )
 
 // LocalCacheOption 本地缓存选项
@@ -16,8 +20,44 @@ type LocalCacheOption struct {
 	SolutionName string
 	TTLZero      time.Duration
 	Syncer       syncer.Syncer
+	// 软过期后仍可继续提供服务的时长，零值表示不启用
+	// 数据在TTL后软过期，在TTL+StaleTTL后硬过期
+	// 仅FreeCache支持，MultiFreeCache忽略
+	StaleTTL time.Duration
+	// 场景独立freecache实例的内存配额，未指定freecache实例时生效
+	// 配额大于零时同一场景名称的本地缓存共用一个独立实例，否则使用全局共享实例
+	Quota Size
+	// 热点key探测器，探测到的热点key即使配置了SkipGet也从本地缓存读取
+	HotKey *hotkey.Detector
+	// 热点key的本地缓存过期时间，零值表示与普通key一致
+	HotTTL time.Duration
+	// 快照文件路径，配置后构造时从快照恢复，Close时写入快照
+	SnapshotPath string
+	// 定期写入快照的间隔，零值表示仅在Close时写入
+	SnapshotInterval time.Duration
+	// 批量写入及删除的同步事件单条消息的最大字节数(压缩前)，超过时拆分为多条消息，默认零值表示每条数据单独同步
+	SyncBatchBytes int
+	// 批量同步事件是否压缩
+	SyncCompress bool
+	// 写入数据的同步方式，默认广播完整数据
+	SyncMode SyncMode
+	// 缓存代数，拼接至key前缀之后
+	Generation *generation.Generation
+	// 过期时间策略，为空时仅使用TTL及对象的Expiring接口
+	TTLPolicy adaptor.TTLPolicy
 }
 
+// SyncMode 写入数据的同步方式
+type SyncMode int
+
+const (
+	// SyncValue 广播完整数据，接收方写入本地缓存
+	SyncValue SyncMode = iota
+	// SyncInvalidate 仅广播key及版本号，接收方删除本地数据，下次读取时从下层缓存加载
+	// 适用于对象较大或各实例读取的key分布差异较大的场景
+	SyncInvalidate
+)
+
 // LocalCacheOptionFunc 本地缓存配置函数
 type LocalCacheOptionFunc func(*LocalCacheOption)
 
@@ -87,3 +127,70 @@ func WithSyncer(syncer syncer.Syncer) LocalCacheOptionFunc {
 		option.Syncer = syncer
 	}
 }
+
+// WithStaleTTL 设置软过期后仍可继续提供服务的时长
+// 仅单值适配器FreeCache支持软过期，批量适配器MultiFreeCache忽略该选项，MultiCache不支持软过期
+func WithStaleTTL(ttl time.Duration) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.StaleTTL = ttl
+	}
+}
+
+// WithQuota 设置场景独立freecache实例的内存配额
+func WithQuota(quota Size) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.Quota = quota
+	}
+}
+
+// WithHotKey 设置热点key探测器及热点key的本地缓存过期时间
+// 探测器需与缓存场景的WithHotKey使用同一实例，本地缓存仅查询探测结果，不记录访问
+func WithHotKey(detector *hotkey.Detector, ttl time.Duration) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.HotKey = detector
+		option.HotTTL = ttl
+	}
+}
+
+// WithSnapshot 设置快照文件路径及定期写入快照的间隔
+// 同一进程内的多个本地缓存需使用不同的快照文件
+func WithSnapshot(path string, interval time.Duration) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.SnapshotPath = path
+		option.SnapshotInterval = interval
+	}
+}
+
+// WithSyncBatch 开启批量同步，设置批量同步事件单条消息的最大字节数(压缩前)及是否压缩，maxBytes不大于零时每条数据单独同步
+// 批量事件采用二进制编码，旧版本接收方无法识别，默认关闭，滚动升级完成后再开启，建议maxBytes取256KB
+// maxBytes不超过syncer.MaxBatchBytes的一半，为估算误差及标签留出余量，避免接收方按解压上限丢弃
+func WithSyncBatch(maxBytes int, compress bool) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.SyncBatchBytes = utils.IfExpr(maxBytes > syncer.MaxBatchBytes/2, syncer.MaxBatchBytes/2, maxBytes)
+		option.SyncCompress = compress
+	}
+}
+
+// WithSyncMode 设置写入数据的同步方式
+// SyncInvalidate模式下的失效事件旧版本接收方无法识别，滚动升级完成后再开启
+func WithSyncMode(mode SyncMode) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.SyncMode = mode
+	}
+}
+
+// WithGeneration 设置缓存代数，代数拼接至key前缀之后，代数变更后旧代数的数据无法访问并随过期时间淘汰
+// 需与同一缓存场景的其他适配器及缓存场景的WithGeneration使用同一实例
+func WithGeneration(g *generation.Generation) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.Generation = g
+	}
+}
+
+// WithTTLPolicy 设置过期时间策略，策略以TTL(热点key为热点key过期时间)或对象Expiring接口返回的过期时间为基础计算最终过期时间
+// 零值对象仍使用TTLZero
+func WithTTLPolicy(policy adaptor.TTLPolicy) LocalCacheOptionFunc {
+	return func(option *LocalCacheOption) {
+		option.TTLPolicy = policy
+	}
+}
@@ -0,0 +1,86 @@
+package local
+
+import "hash/maphash"
+
+// sketchDepth count-min sketch的行数
+const sketchDepth = 4
+
+// cmSketch 记录key访问频率的count-min sketch
+// 计数总数达到阈值后所有计数减半，使频率估计偏向近期访问
+type cmSketch struct {
+	seed      maphash.Seed
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+// newCMSketch 创建count-min sketch，numCounters为每行计数器数量，向上取整为2的幂
+func newCMSketch(numCounters int) *cmSketch {
+	width := 1
+	for width < numCounters {
+		width <<= 1
+	}
+	s := &cmSketch{
+		seed:    maphash.MakeSeed(),
+		mask:    uint64(width - 1),
+		resetAt: width * 10,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// hash 计算key的哈希值
+func (s *cmSketch) hash(key string) uint64 {
+	return maphash.String(s.seed, key)
+}
+
+// increment 增加key的访问频率
+func (s *cmSketch) increment(h uint64) {
+	h1, h2 := h, h>>32|h<<32
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < 255 {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+// estimate 估计key的访问频率
+func (s *cmSketch) estimate(h uint64) uint8 {
+	h1, h2 := h, h>>32|h<<32
+	min := uint8(255)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < min {
+			min = s.rows[i][idx]
+		}
+	}
+	return min
+}
+
+// reset 所有计数减半
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions = 0
+}
+
+// clear 清空所有计数
+func (s *cmSketch) clear() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+	s.additions = 0
+}
@@ -0,0 +1,307 @@
+package local
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/utils"
+)
+
+// snapshotMagic 快照文件头，snapshotMagicV1为不含标签的旧版本文件头，恢复时兼容
+const (
+	snapshotMagic   = "MCSNAP02"
+	snapshotMagicV1 = "MCSNAP01"
+)
+
+// ErrInvalidSnapshot 快照文件格式错误或已损坏
+var ErrInvalidSnapshot = errors.New("invalid snapshot file")
+
+// 快照文件格式：
+//
+//	文件头 MCSNAP02
+//	若干条记录 uvarint(key长度) key uvarint(value长度) value uvarint(过期时间戳，秒，0表示永不过期)
+//	          uvarint(标签数量) 若干个 uvarint(标签长度) 标签
+//	结束标记 uvarint(0)
+//	4字节大端CRC32(IEEE)，覆盖文件头至结束标记
+//
+// 记录中的key不含适配器前缀，恢复时使用当前前缀，value为存储中的原始数据(含版本号头部)
+// 标签取自标签索引，恢复时重建索引，保证恢复的数据仍可按标签删除；MCSNAP01格式的记录不含标签数量及标签
+
+// writeSnapshot 将存储中以prefix开头的未过期数据及其标签写入快照文件，先写临时文件再重命名，返回写入条数
+func writeSnapshot[V adaptor.Metadata](store storage[V], index *tagIndex, prefix string, path string) (int, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := crc32.NewIEEE()
+	w := bufio.NewWriter(io.MultiWriter(tmp, hash))
+	var varint [binary.MaxVarintLen64]byte
+	writeBytes := func(buf []byte) {
+		n := binary.PutUvarint(varint[:], uint64(len(buf)))
+		w.Write(varint[:n])
+		w.Write(buf)
+	}
+
+	w.WriteString(snapshotMagic)
+	count := 0
+	now := time.Now()
+	err = store.iterate(prefix, func(key string, buf []byte, expireAt time.Time) bool {
+		// 空key用作结束标记，不写入快照
+		if len(key) == len(prefix) || (!expireAt.IsZero() && !expireAt.After(now)) {
+			return true
+		}
+		var expire uint64
+		if !expireAt.IsZero() {
+			expire = uint64(expireAt.Unix())
+		}
+		writeBytes(utils.Bytes(key[len(prefix):]))
+		writeBytes(buf)
+		n := binary.PutUvarint(varint[:], expire)
+		w.Write(varint[:n])
+		tags := index.tagsOf(key)
+		n = binary.PutUvarint(varint[:], uint64(len(tags)))
+		w.Write(varint[:n])
+		for _, tag := range tags {
+			writeBytes(utils.Bytes(tag))
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	// 空key作为结束标记
+	w.WriteByte(0)
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(tmp, binary.BigEndian, hash.Sum32()); err != nil {
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return count, os.Rename(tmp.Name(), path)
+}
+
+// readSnapshot 校验快照文件后将未过期的数据写入存储并重建标签索引，返回恢复条数
+// 剩余过期时间不足1秒的数据视为已过期
+func readSnapshot[V adaptor.Metadata](store storage[V], index *tagIndex, prefix string, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// 第一遍校验文件完整性
+	magic, err := verifySnapshot(f)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(int64(len(snapshotMagic)), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	r := bufio.NewReader(f)
+	count := 0
+	now := time.Now()
+	for {
+		key, err := readBytes(r)
+		if err != nil {
+			return count, err
+		}
+		if len(key) == 0 {
+			return count, nil
+		}
+		buf, err := readBytes(r)
+		if err != nil {
+			return count, err
+		}
+		expire, err := binary.ReadUvarint(r)
+		if err != nil {
+			return count, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		var tags []string
+		if magic == snapshotMagic {
+			if tags, err = readTags(r); err != nil {
+				return count, err
+			}
+		}
+
+		var ttl time.Duration
+		if expire > 0 {
+			ttl = time.Unix(int64(expire), 0).Sub(now)
+			if ttl < time.Second {
+				continue
+			}
+		}
+		version, _, _ := utils.DecodeVersion(buf)
+		if err := store.setRaw(prefix+string(key), buf, version, ttl); err != nil {
+			return count, err
+		}
+		index.add(prefix+string(key), tags)
+		count++
+	}
+}
+
+// verifySnapshot 校验快照文件头及CRC32，返回文件头
+func verifySnapshot(f *os.File) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size() - 4
+	if size < int64(len(snapshotMagic))+1 {
+		return "", ErrInvalidSnapshot
+	}
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(f, magic); err != nil || (string(magic) != snapshotMagic && string(magic) != snapshotMagicV1) {
+		return "", ErrInvalidSnapshot
+	}
+	hash := crc32.NewIEEE()
+	hash.Write(magic)
+	if _, err := io.CopyN(hash, f, size-int64(len(snapshotMagic))); err != nil {
+		return "", err
+	}
+	var sum uint32
+	if err := binary.Read(f, binary.BigEndian, &sum); err != nil || sum != hash.Sum32() {
+		return "", ErrInvalidSnapshot
+	}
+	return string(magic), nil
+}
+
+// readBytes 读取长度前缀的字节数组
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	return buf, nil
+}
+
+// readTags 读取记录中的标签
+func readTags(r *bufio.Reader) ([]string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	var tags []string
+	for i := uint64(0); i < n; i++ {
+		tag, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, string(tag))
+	}
+	return tags, nil
+}
+
+// snapshotter 本地缓存快照，构造时从快照恢复，按间隔及关闭时写入快照
+type snapshotter[V adaptor.Metadata] struct {
+	store        storage[V]
+	tags         *tagIndex
+	prefix       string
+	path         string
+	interval     time.Duration
+	name         string
+	solutionName string
+	// 串行化快照写入
+	mu        sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newSnapshotter 创建本地缓存快照，未配置快照路径时返回nil
+func newSnapshotter[V adaptor.Metadata](store storage[V], tags *tagIndex, opts LocalCacheOption) *snapshotter[V] {
+	if opts.SnapshotPath == "" {
+		return nil
+	}
+	s := &snapshotter[V]{
+		store:        store,
+		tags:         tags,
+		prefix:       opts.Prefix,
+		path:         opts.SnapshotPath,
+		interval:     opts.SnapshotInterval,
+		name:         opts.Name,
+		solutionName: opts.SolutionName,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	n, err := readSnapshot(store, s.tags, s.prefix, s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error(err.Error(), "solution", s.solutionName, "adaptor", s.name, "path", s.path, "restored", n, "event", adaptor.LogEventSnapshot)
+	}
+	if s.interval > 0 {
+		go s.run()
+	} else {
+		close(s.done)
+	}
+	return s
+}
+
+// save 写入快照
+func (s *snapshotter[V]) save() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := writeSnapshot(s.store, s.tags, s.prefix, s.path)
+	if err != nil {
+		logger.Error(err.Error(), "solution", s.solutionName, "adaptor", s.name, "path", s.path, "event", adaptor.LogEventSnapshot)
+	}
+	return n, err
+}
+
+// run 按间隔写入快照
+func (s *snapshotter[V]) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.save()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// close 停止定期快照并写入最后一次快照
+func (s *snapshotter[V]) close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		_, err = s.save()
+	})
+	return err
+}
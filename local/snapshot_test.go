@@ -0,0 +1,125 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/rumis/multicache/metrics"
+	"github.com/rumis/multicache/tests"
+)
+
+func TestSnapshot(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "snapshot_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+	path := filepath.Join(t.TempDir(), "student.snap")
+
+	// 共享freecache实例中其他前缀的数据不写入快照
+	icache := freecache.NewCache(int(MB))
+	icache.Set([]byte("other_张三"), []byte("x"), 0)
+	c := NewFreeCache[string, *tests.Student](icache, nil, WithSnapshot(path, time.Hour))
+	for _, name := range []string{"张三", "李四"} {
+		if err := c.Set(ctx, &tests.Student{Name: name, Age: 18}); err != nil {
+			t.Fatal("Set Error", err)
+		}
+	}
+	// 关闭时写入快照
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal("Close Error", err)
+	}
+
+	// 构造时从快照恢复
+	restored := NewFreeCache[string, *tests.Student](freecache.NewCache(int(MB)), nil, WithSnapshot(path, 0))
+	var s tests.Student
+	if ok, err := restored.Get(ctx, "李四", &s); !ok || err != nil || s.Age != 18 {
+		t.Errorf("Get() = %v, %v, %v", s, ok, err)
+	}
+	info, _ := restored.Inspect(ctx, "张三")
+	// 默认TTL为30秒，freecache的过期时间精度为秒，恢复后剩余时间向下取整
+	if !info.Found || info.TTL < 28*time.Second {
+		t.Errorf("info = %+v, ttl should be kept", info)
+	}
+	if restored.store.(*byteStorage[*tests.Student]).store.Len() != 2 {
+		t.Error("entries with other prefix should not be restored")
+	}
+
+	// 对象存储的快照可恢复至字节存储
+	typed := NewTypedLocalCache[string, *tests.Student](NewMapStore[*tests.Student](0), nil, WithTTL(time.Hour))
+	typed.Set(ctx, &tests.Student{Name: "王五", Age: 20})
+	if n, err := typed.SaveSnapshot(path); n != 1 || err != nil {
+		t.Fatalf("SaveSnapshot() = %d, %v", n, err)
+	}
+	if n, err := restored.LoadSnapshot(path); n != 1 || err != nil {
+		t.Fatalf("LoadSnapshot() = %d, %v", n, err)
+	}
+	if ok, _ := restored.Get(ctx, "王五", &s); !ok || s.Age != 20 {
+		t.Errorf("Get() = %v, %v", s, ok)
+	}
+
+	// 不可遍历的存储
+	if _, err := NewTypedLocalCache[string, *tests.Student](nonIterableStore{}, nil).SaveSnapshot(path); !errors.Is(err, ErrNotIterable) {
+		t.Errorf("err = %v, want ErrNotIterable", err)
+	}
+
+	// 损坏的快照
+	buf, _ := os.ReadFile(path)
+	buf[len(buf)-5] ^= 0xff
+	os.WriteFile(path, buf, 0644)
+	if _, err := restored.LoadSnapshot(path); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("err = %v, want ErrInvalidSnapshot", err)
+	}
+}
+
+func TestSnapshotTags(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "snapshot_tags_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+	path := filepath.Join(t.TempDir(), "student.snap")
+
+	c := NewFreeCache[string, *tests.TaggedStudent](freecache.NewCache(int(MB)), nil)
+	c.Set(ctx, &tests.TaggedStudent{Student: tests.Student{Name: "张三", Age: 18}})
+	c.Set(ctx, &tests.TaggedStudent{Student: tests.Student{Name: "李四", Age: 19}})
+	if n, err := c.SaveSnapshot(path); n != 2 || err != nil {
+		t.Fatalf("SaveSnapshot() = %d, %v", n, err)
+	}
+
+	// 恢复时重建标签索引，恢复的数据仍可按标签删除
+	restored := NewFreeCache[string, *tests.TaggedStudent](freecache.NewCache(int(MB)), nil, WithSnapshot(path, 0))
+	if n, _ := restored.InvalidateTag(ctx, "age:18"); n != 1 {
+		t.Errorf("invalidated = %d, want 1", n)
+	}
+	if ok, _ := restored.Get(ctx, "张三", &tests.TaggedStudent{}); ok {
+		t.Error("tagged entry restored from snapshot not invalidated")
+	}
+	if ok, _ := restored.Get(ctx, "李四", &tests.TaggedStudent{}); !ok {
+		t.Error("entry with other tag invalidated")
+	}
+}
+
+func TestSnapshotExpired(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "snapshot_expired_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+	path := filepath.Join(t.TempDir(), "student.snap")
+
+	c := NewLocalCache[string, *tests.Student](NewAdmissionStore(MB), nil, WithTTL(time.Second), WithThreshold(time.Second))
+	c.Set(ctx, &tests.Student{Name: "张三", Age: 18})
+	if n, err := c.SaveSnapshot(path); n != 1 || err != nil {
+		t.Fatalf("SaveSnapshot() = %d, %v", n, err)
+	}
+	// 恢复时跳过已过期的数据
+	time.Sleep(1100 * time.Millisecond)
+	if n, err := c.LoadSnapshot(path); n != 0 || err != nil {
+		t.Errorf("LoadSnapshot() = %d, %v", n, err)
+	}
+}
+
+// nonIterableStore 未实现Iterable接口的对象存储
+type nonIterableStore struct {
+	TypedStore[*tests.Student]
+}
@@ -0,0 +1,308 @@
+package local
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/utils"
+)
+
+// storage 本地缓存适配器的存储层，屏蔽字节存储与对象存储的差异
+// 对象实现Versioned接口时，写入比较版本号，缓存中已有更新版本的数据时放弃写入
+type storage[V adaptor.Metadata] interface {
+	// get 读取对象至value，返回过期时间
+	get(key string, value V) (time.Time, error)
+	// load 读取对象，对象存储可直接返回存储中的对象，无需通过fn创建对象
+	load(key string, fn adaptor.NewValueFunc[V]) (V, time.Time, error)
+	// set 写入对象，返回是否写入，encode为true时同时返回同步事件使用的序列化数据
+	set(key string, value V, ttl time.Duration, encode bool) (bool, []byte, error)
+	// setRaw 写入同步事件中的序列化数据
+	setRaw(key string, buf []byte, version int64, ttl time.Duration) error
+	// del 删除对象
+	del(key string)
+	// invalidate 删除对象，对象实现Versioned接口且version大于零时保留版本号不小于version的对象
+	// 返回对象是否已不在存储中，保留对象时返回false
+	invalidate(key string, version int64) bool
+	// inspect 查询对象大小及过期时间，key不存在时返回ErrNotFound
+	inspect(key string) (int64, time.Time, error)
+	// iterate 遍历以prefix开头的未过期数据，对象存储中的对象序列化后传入，存储未实现Iterable时返回ErrNotIterable
+	iterate(prefix string, fn func(key string, buf []byte, expireAt time.Time) bool) error
+	// purge 删除以prefix开头的数据，返回删除条数
+	purge(prefix string) int
+}
+
+// ErrNotIterable 存储未实现Iterable接口
+var ErrNotIterable = errors.New("store is not iterable")
+
+// byteStorage 基于字节存储的存储层，带版本号的数据附加版本号头部
+type byteStorage[V adaptor.Metadata] struct {
+	store     Store
+	versioned bool
+}
+
+// newByteStorage 创建基于字节存储的存储层
+func newByteStorage[V adaptor.Metadata](store Store) *byteStorage[V] {
+	return &byteStorage[V]{
+		store:     store,
+		versioned: adaptor.IsVersioned[V](),
+	}
+}
+
+func (s *byteStorage[V]) get(key string, value V) (time.Time, error) {
+	buf, expireAt, err := s.store.Get(key)
+	if err != nil {
+		return expireAt, err
+	}
+	if s.versioned {
+		_, buf, _ = utils.DecodeVersion(buf)
+	}
+	return expireAt, value.Decode(buf)
+}
+
+func (s *byteStorage[V]) load(key string, fn adaptor.NewValueFunc[V]) (V, time.Time, error) {
+	val := fn()
+	expireAt, err := s.get(key, val)
+	return val, expireAt, err
+}
+
+func (s *byteStorage[V]) set(key string, value V, ttl time.Duration, encode bool) (bool, []byte, error) {
+	buf, err := value.Value()
+	if err != nil {
+		return false, nil, err
+	}
+	if !s.versioned {
+		return true, buf, s.store.Set(key, buf, ttl)
+	}
+	version := any(value).(adaptor.Versioned).Version()
+	buf = utils.EncodeVersion(version, buf)
+	written, err := s.setVersioned(key, buf, version, ttl)
+	return written, buf, err
+}
+
+func (s *byteStorage[V]) setRaw(key string, buf []byte, version int64, ttl time.Duration) error {
+	if !s.versioned {
+		return s.store.Set(key, buf, ttl)
+	}
+	_, err := s.setVersioned(key, buf, version, ttl)
+	return err
+}
+
+func (s *byteStorage[V]) del(key string) {
+	s.store.Del(key)
+}
+
+func (s *byteStorage[V]) invalidate(key string, version int64) bool {
+	if s.versioned && version > 0 {
+		buf, _, err := s.store.Get(key)
+		if err != nil {
+			return true
+		}
+		if current, _, ok := utils.DecodeVersion(buf); ok && current >= version {
+			return false
+		}
+	}
+	s.store.Del(key)
+	return true
+}
+
+func (s *byteStorage[V]) purge(prefix string) int {
+	return purgeStore[[]byte](s.store, prefix)
+}
+
+func (s *byteStorage[V]) inspect(key string) (int64, time.Time, error) {
+	buf, expireAt, err := s.store.Get(key)
+	if err != nil {
+		return 0, expireAt, err
+	}
+	if s.versioned {
+		_, buf, _ = utils.DecodeVersion(buf)
+	}
+	return int64(len(buf)), expireAt, nil
+}
+
+func (s *byteStorage[V]) iterate(prefix string, fn func(key string, buf []byte, expireAt time.Time) bool) error {
+	iter, ok := s.store.(Iterable[[]byte])
+	if !ok {
+		return ErrNotIterable
+	}
+	iter.Range(func(key string, buf []byte, expireAt time.Time) bool {
+		if !strings.HasPrefix(key, prefix) {
+			return true
+		}
+		return fn(key, buf, expireAt)
+	})
+	return nil
+}
+
+// setVersioned 比较版本号后写入带版本号头部的数据
+func (s *byteStorage[V]) setVersioned(key string, buf []byte, version int64, ttl time.Duration) (bool, error) {
+	return s.store.Update(key, func(cur []byte, found bool) ([]byte, bool, time.Duration) {
+		if found {
+			if current, _, ok := utils.DecodeVersion(cur); ok && current > version {
+				return nil, false, 0
+			}
+		}
+		return buf, true, ttl
+	})
+}
+
+// typedStorage 基于对象存储的存储层，对象的复制由对象存储负责，单值读取时将读取到的对象浅拷贝至调用方对象
+type typedStorage[V adaptor.Metadata] struct {
+	store     TypedStore[V]
+	versioned bool
+}
+
+// newTypedStorage 创建基于对象存储的存储层
+func newTypedStorage[V adaptor.Metadata](store TypedStore[V]) *typedStorage[V] {
+	return &typedStorage[V]{
+		store:     store,
+		versioned: adaptor.IsVersioned[V](),
+	}
+}
+
+func (s *typedStorage[V]) get(key string, value V) (time.Time, error) {
+	// 存储直接复制至value，避免先复制再赋值
+	if assigner, ok := s.store.(Assigner[V]); ok {
+		return assigner.GetInto(key, value)
+	}
+	val, expireAt, err := s.store.Get(key)
+	if err != nil {
+		return expireAt, err
+	}
+	utils.Assign(value, val)
+	return expireAt, nil
+}
+
+func (s *typedStorage[V]) load(key string, fn adaptor.NewValueFunc[V]) (V, time.Time, error) {
+	return s.store.Get(key)
+}
+
+func (s *typedStorage[V]) set(key string, value V, ttl time.Duration, encode bool) (bool, []byte, error) {
+	var written bool
+	var err error
+	var version int64
+	if s.versioned {
+		version = any(value).(adaptor.Versioned).Version()
+		written, err = s.setVersioned(key, value, version, ttl)
+	} else {
+		written, err = true, s.store.Set(key, value, ttl)
+	}
+	if err != nil || !written || !encode {
+		return written, nil, err
+	}
+
+	buf, err := value.Value()
+	if err != nil {
+		return written, nil, err
+	}
+	if s.versioned {
+		buf = utils.EncodeVersion(version, buf)
+	}
+	return written, buf, nil
+}
+
+func (s *typedStorage[V]) setRaw(key string, buf []byte, version int64, ttl time.Duration) error {
+	var zero V
+	val := utils.NewOf(zero)
+	if s.versioned {
+		_, buf, _ = utils.DecodeVersion(buf)
+	}
+	if err := val.Decode(buf); err != nil {
+		return err
+	}
+	if !s.versioned {
+		return s.store.Set(key, val, ttl)
+	}
+	_, err := s.setVersioned(key, val, version, ttl)
+	return err
+}
+
+func (s *typedStorage[V]) del(key string) {
+	s.store.Del(key)
+}
+
+func (s *typedStorage[V]) invalidate(key string, version int64) bool {
+	if s.versioned && version > 0 {
+		cur, _, err := s.store.Get(key)
+		if err != nil {
+			return true
+		}
+		if any(cur).(adaptor.Versioned).Version() >= version {
+			return false
+		}
+	}
+	s.store.Del(key)
+	return true
+}
+
+func (s *typedStorage[V]) purge(prefix string) int {
+	return purgeStore[V](s.store, prefix)
+}
+
+func (s *typedStorage[V]) inspect(key string) (int64, time.Time, error) {
+	val, expireAt, err := s.store.Get(key)
+	if err != nil {
+		return 0, expireAt, err
+	}
+	return utils.SizeOf(val), expireAt, nil
+}
+
+func (s *typedStorage[V]) iterate(prefix string, fn func(key string, buf []byte, expireAt time.Time) bool) error {
+	iter, ok := s.store.(Iterable[V])
+	if !ok {
+		return ErrNotIterable
+	}
+	var err error
+	iter.Range(func(key string, val V, expireAt time.Time) bool {
+		if !strings.HasPrefix(key, prefix) {
+			return true
+		}
+		var buf []byte
+		buf, err = val.Value()
+		if err != nil {
+			return false
+		}
+		if s.versioned {
+			buf = utils.EncodeVersion(any(val).(adaptor.Versioned).Version(), buf)
+		}
+		return fn(key, buf, expireAt)
+	})
+	return err
+}
+
+// setVersioned 比较版本号后写入对象
+func (s *typedStorage[V]) setVersioned(key string, val V, version int64, ttl time.Duration) (bool, error) {
+	return s.store.Update(key, func(cur V, found bool) (V, bool, time.Duration) {
+		if found && any(cur).(adaptor.Versioned).Version() > version {
+			return cur, false, 0
+		}
+		return val, true, ttl
+	})
+}
+
+// purgeStore 删除存储中以prefix开头的数据，存储未实现Iterable时无法按前缀删除，清空整个存储
+func purgeStore[T any](store interface {
+	Del(key string) bool
+	Clear()
+	Len() int
+}, prefix string) int {
+	iter, ok := store.(Iterable[T])
+	if !ok {
+		n := store.Len()
+		store.Clear()
+		return n
+	}
+	keys := make([]string, 0)
+	iter.Range(func(key string, _ T, _ time.Time) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	for _, key := range keys {
+		store.Del(key)
+	}
+	return len(keys)
+}
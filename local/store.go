@@ -0,0 +1,79 @@
+package local
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// ErrNotFound 数据不存在
+var ErrNotFound = errors.New("entry not found")
+
+// ErrTooLarge 数据超过存储的容量上限，写入被拒绝且key原有的数据被删除
+var ErrTooLarge = errors.New("entry too large")
+
+// Store 本地缓存字节存储接口，本地缓存适配器基于该接口读写序列化后的数据
+type Store interface {
+	// Get 读取数据及其过期时间，零值表示永不过期，数据不存在时返回ErrNotFound
+	Get(key string) ([]byte, time.Time, error)
+	// Set 写入数据，ttl不大于零表示永不过期
+	Set(key string, val []byte, ttl time.Duration) error
+	// Update 在存储内部的锁内读取数据并按需替换，返回是否替换
+	Update(key string, fn UpdateFunc[[]byte]) (bool, error)
+	// Del 删除数据，返回数据是否存在
+	Del(key string) bool
+	// Clear 清空数据
+	Clear()
+	// Len 数据条数
+	Len() int
+}
+
+// TypedStore 本地缓存对象存储接口，直接保存反序列化后的对象，读取时无需反序列化
+// 写入及读取时由存储负责按需复制对象，V需为指针类型
+type TypedStore[V adaptor.Metadata] interface {
+	// Get 读取对象及其过期时间，零值表示永不过期，对象不存在时返回ErrNotFound
+	Get(key string) (V, time.Time, error)
+	// Set 写入对象，ttl不大于零表示永不过期
+	Set(key string, val V, ttl time.Duration) error
+	// Update 在存储内部的锁内读取对象并按需替换，返回是否替换，fn的入参为存储内部对象，不可修改
+	Update(key string, fn UpdateFunc[V]) (bool, error)
+	// Del 删除对象，返回对象是否存在
+	Del(key string) bool
+	// Clear 清空对象
+	Clear()
+	// Len 对象数量
+	Len() int
+}
+
+// Assigner 可将对象直接复制至调用方对象的存储(可选)，单值读取时由存储复制一次，避免先复制再赋值
+type Assigner[V adaptor.Metadata] interface {
+	// GetInto 读取对象并复制至dst，返回过期时间，对象不存在时返回ErrNotFound
+	GetInto(key string, dst V) (time.Time, error)
+}
+
+// Iterable 可遍历的存储(可选)，用于本地缓存快照，T为[]byte(字节存储)或对象类型(对象存储)
+type Iterable[T any] interface {
+	// Range 遍历未过期的数据，fn返回false时停止遍历
+	// 对象存储传入的是存储内部对象，不可修改；fn在存储锁外调用，耗时的序列化不阻塞存储的读写
+	Range(fn func(key string, val T, expireAt time.Time) bool)
+}
+
+// rangeEntry 遍历时在锁内复制的条目，释放锁后再逐条回调
+type rangeEntry[T any] struct {
+	key      string
+	val      T
+	expireAt time.Time
+}
+
+// rangeEntries 依次回调复制的条目，fn返回false时停止
+func rangeEntries[T any](entries []rangeEntry[T], fn func(key string, val T, expireAt time.Time) bool) {
+	for _, e := range entries {
+		if !fn(e.key, e.val, e.expireAt) {
+			return
+		}
+	}
+}
+
+// UpdateFunc 数据更新函数，入参为当前数据及其是否存在，返回新数据、是否替换及过期时间
+type UpdateFunc[T any] func(val T, found bool) (T, bool, time.Duration)
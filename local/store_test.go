@@ -0,0 +1,226 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/coocood/freecache"
+	"github.com/rumis/multicache/tests"
+)
+
+func TestStore(t *testing.T) {
+	stores := map[string]Store{
+		"freecache": NewFreeCacheStore(freecache.NewCache(1024 * 1024)),
+		"admission": NewAdmissionStore(MB),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if err := store.Set("张三", []byte("18"), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			buf, expireAt, err := store.Get("张三")
+			if err != nil || string(buf) != "18" || expireAt.IsZero() {
+				t.Errorf("Get() = %s, %v, %v", buf, expireAt, err)
+			}
+			replaced, err := store.Update("张三", func(val []byte, found bool) ([]byte, bool, time.Duration) {
+				return []byte("19"), found && string(val) == "18", time.Minute
+			})
+			if !replaced || err != nil {
+				t.Errorf("Update() = %v, %v", replaced, err)
+			}
+			if buf, _, _ := store.Get("张三"); string(buf) != "19" {
+				t.Errorf("Get() = %s, want 19", buf)
+			}
+			if store.Len() != 1 || !store.Del("张三") {
+				t.Error("Del should remove the entry")
+			}
+			if _, _, err := store.Get("张三"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, want ErrNotFound", err)
+			}
+			// 超过容量上限的写入被拒绝并删除原有数据
+			store.Set("李四", []byte("20"), time.Minute)
+			if err := store.Set("李四", make([]byte, 2*MB), time.Minute); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("err = %v, want ErrTooLarge", err)
+			}
+			if _, _, err := store.Get("李四"); !errors.Is(err, ErrNotFound) {
+				t.Errorf("err = %v, stale entry should be removed", err)
+			}
+			store.Set("李四", []byte("20"), time.Minute)
+			store.Clear()
+			if store.Len() != 0 {
+				t.Error("Clear should remove all entries")
+			}
+		})
+	}
+}
+
+func TestAdmissionStore(t *testing.T) {
+	store := NewAdmissionStore(10 * KB)
+	val := make([]byte, 1000)
+
+	// 频繁访问的数据写满存储
+	for i := 0; i < 9; i++ {
+		key := fmt.Sprintf("hot_%d", i)
+		store.Set(key, val, time.Minute)
+		for j := 0; j < 10; j++ {
+			store.Get(key)
+		}
+	}
+	// 仅访问一次的数据不能淘汰频繁访问的数据
+	for i := 0; i < 100; i++ {
+		store.Set(fmt.Sprintf("cold_%d", i), val, time.Minute)
+	}
+	for i := 0; i < 9; i++ {
+		if _, _, err := store.Get(fmt.Sprintf("hot_%d", i)); err != nil {
+			t.Errorf("hot_%d should be kept", i)
+		}
+	}
+	if store.cost > store.maxCost {
+		t.Errorf("cost = %d, want <= %d", store.cost, store.maxCost)
+	}
+}
+
+func TestMapStore(t *testing.T) {
+	store := NewMapStore[*tests.Student](2)
+	store.Set("张三", &tests.Student{Name: "张三"}, time.Millisecond)
+	store.Set("李四", &tests.Student{Name: "李四"}, 0)
+	time.Sleep(10 * time.Millisecond)
+	if _, _, err := store.Get("张三"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	// 容量已满时优先淘汰过期对象
+	store.Set("王五", &tests.Student{Name: "王五"}, 0)
+	if store.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", store.Len())
+	}
+	if val, _, err := store.Get("李四"); err != nil || val.Name != "李四" {
+		t.Errorf("Get() = %v, %v", val, err)
+	}
+
+	// 不限制对象数量时写入新key采样清理过期对象
+	unlimited := NewMapStore[*tests.Student](0)
+	for i := 0; i < 1000; i++ {
+		unlimited.Set(fmt.Sprint("expired_", i), &tests.Student{}, time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 1000; i++ {
+		unlimited.Set(fmt.Sprint("live_", i), &tests.Student{}, 0)
+	}
+	if n := unlimited.Len(); n > 1100 {
+		t.Errorf("Len() = %d, expired entries should be evicted", n)
+	}
+}
+
+func TestObjectStore(t *testing.T) {
+	cost := WithCost(func(*tests.Student) int64 { return 100 })
+	newStudent := func(name string) *tests.Student {
+		return &tests.Student{Name: name}
+	}
+
+	// LRU：淘汰最近最少使用的对象
+	lru := NewObjectStore(cost, WithMaxCost[*tests.Student](310))
+	lru.Set("a", newStudent("a"), 0)
+	lru.Set("b", newStudent("b"), 0)
+	lru.Set("c", newStudent("c"), 0)
+	lru.Get("a")
+	lru.Set("d", newStudent("d"), 0)
+	if _, _, err := lru.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Error("LRU should evict b")
+	}
+	if lru.Len() != 3 || lru.Cost() != 303 {
+		t.Errorf("Len() = %d, Cost() = %d", lru.Len(), lru.Cost())
+	}
+	// 遍历回调在锁外执行，回调内可读写存储
+	n := 0
+	lru.Range(func(key string, val *tests.Student, expireAt time.Time) bool {
+		if _, _, err := lru.Get(key); err == nil {
+			n++
+		}
+		return true
+	})
+	if n != 3 {
+		t.Errorf("Range() visited %d entries, want 3", n)
+	}
+
+	// LFU：淘汰访问次数最少的对象
+	lfu := NewObjectStore(cost, WithMaxCost[*tests.Student](310), WithEviction[*tests.Student](EvictionLFU))
+	lfu.Set("a", newStudent("a"), 0)
+	lfu.Set("b", newStudent("b"), 0)
+	lfu.Set("c", newStudent("c"), 0)
+	lfu.Get("a")
+	lfu.Get("b")
+	lfu.Get("c")
+	lfu.Get("a")
+	lfu.Get("c")
+	lfu.Set("d", newStudent("d"), 0)
+	if _, _, err := lfu.Get("b"); !errors.Is(err, ErrNotFound) {
+		t.Error("LFU should evict b")
+	}
+
+	// 不可变对象读写均不复制
+	immutable := NewObjectStore(WithImmutable[*tests.Student]())
+	s := newStudent("张三")
+	immutable.Set("张三", s, time.Minute)
+	if val, _, _ := immutable.Get("张三"); val != s {
+		t.Error("immutable store should return the stored object")
+	}
+
+	// 通过Clone复制对象
+	var clones int
+	cloned := NewObjectStore(WithClone(func(s *tests.Student) *tests.Student {
+		clones++
+		cp := *s
+		return &cp
+	}))
+	cloned.Set("张三", s, time.Minute)
+	if val, _, _ := cloned.Get("张三"); val == s || val.Name != "张三" || clones != 2 {
+		t.Errorf("clone store should copy on write and read, clones = %d", clones)
+	}
+
+	// 未配置Clone及不可变时通过序列化复制，对象中的切片不与调用方共享
+	copied := NewObjectStore[*courseStudent]()
+	stored := &courseStudent{Name: "张三", Courses: []string{"语文"}}
+	copied.Set("张三", stored, time.Minute)
+	stored.Courses[0] = "数学"
+	got, _, _ := copied.Get("张三")
+	got.Courses[0] = "英语"
+	var dst courseStudent
+	if _, err := copied.GetInto("张三", &dst); err != nil || dst.Courses[0] != "语文" {
+		t.Errorf("GetInto() = %+v, %v, stored object should not be shared", dst, err)
+	}
+
+	// 开销超过上限的对象被拒绝并删除原有对象
+	sized := NewObjectStore(WithCost(func(s *tests.Student) int64 { return int64(s.Age) }), WithMaxCost[*tests.Student](100))
+	sized.Set("张三", &tests.Student{Name: "张三", Age: 18}, 0)
+	if err := sized.Set("张三", &tests.Student{Name: "张三", Age: 200}, 0); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+	if _, _, err := sized.Get("张三"); !errors.Is(err, ErrNotFound) || sized.Cost() != 0 {
+		t.Errorf("err = %v, cost = %d, stale object should be removed", err, sized.Cost())
+	}
+}
+
+// courseStudent 含切片字段的测试对象
+type courseStudent struct {
+	Name    string   `json:"name"`
+	Courses []string `json:"courses"`
+}
+
+func (s *courseStudent) Key() string {
+	return s.Name
+}
+
+func (s *courseStudent) Value() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+func (s *courseStudent) Decode(buf []byte) error {
+	return json.Unmarshal(buf, s)
+}
+
+func (s *courseStudent) Zero() bool {
+	return s.Name == ""
+}
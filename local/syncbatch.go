@@ -0,0 +1,84 @@
+package local
+
+import (
+	"context"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/syncer"
+)
+
+// emitBatch 按maxBytes将数据拆分为多条批量同步事件广播，拆分后仅含一条数据时广播单条事件
+// maxBytes不大于零时每条数据单独广播，广播失败时调用onErr
+func emitBatch(ctx context.Context, s syncer.Syncer, eventType syncer.EventType, entries []syncer.SyncEntry, maxBytes int, compress bool, onErr func(e *syncer.CacheSyncEvent, err error)) {
+	emit := func(batch []syncer.SyncEntry) {
+		if len(batch) == 0 {
+			return
+		}
+		e := &syncer.CacheSyncEvent{EventType: eventType, Entries: batch, Compress: compress}
+		if len(batch) == 1 {
+			e = singleEvent(eventType, batch[0])
+		}
+		if err := s.Emit(ctx, e); err != nil {
+			onErr(e, err)
+		}
+	}
+	if maxBytes <= 0 {
+		for i := range entries {
+			emit(entries[i : i+1])
+		}
+		return
+	}
+	start, size := 0, 0
+	for i, entry := range entries {
+		entrySize := syncer.EntrySize(entry.Key, entry.Val)
+		if i > start && size+entrySize > maxBytes {
+			emit(entries[start:i])
+			start, size = i, 0
+		}
+		size += entrySize
+	}
+	emit(entries[start:])
+}
+
+// singleEvent 批量事件中的单条数据对应的单条同步事件
+func singleEvent(eventType syncer.EventType, entry syncer.SyncEntry) *syncer.CacheSyncEvent {
+	switch eventType {
+	case syncer.EventTypeBatchDelete:
+		return &syncer.CacheSyncEvent{EventType: syncer.EventTypeDelete, Key: entry.Key}
+	case syncer.EventTypeBatchInvalidate:
+		return &syncer.CacheSyncEvent{EventType: syncer.EventTypeInvalidate, Key: entry.Key, Version: entry.Version}
+	}
+	return &syncer.CacheSyncEvent{
+		EventType: syncer.EventTypeAdd,
+		Key:       entry.Key,
+		Val:       entry.Val,
+		TTL:       entry.TTL,
+		Version:   entry.Version,
+		Tags:      entry.Tags,
+	}
+}
+
+// applyBatch 应用批量同步事件，事件在解码时已整体校验，损坏的批量事件不会被部分应用
+// 调用方需持有适配器的batchMu写锁，保证读取时不会看到仅应用了一部分的批量事件
+// 单条数据写入失败不影响其余数据，失败时调用onErr
+func applyBatch[V adaptor.Metadata](store storage[V], index *tagIndex, e *syncer.CacheSyncEvent, onErr func(entry syncer.SyncEntry, err error)) {
+	for _, entry := range e.Entries {
+		switch e.EventType {
+		case syncer.EventTypeBatchDelete:
+			store.del(entry.Key)
+			index.remove(entry.Key)
+			continue
+		case syncer.EventTypeBatchInvalidate:
+			if store.invalidate(entry.Key, entry.Version) {
+				index.remove(entry.Key)
+			}
+			continue
+		}
+		// 对象实现Versioned接口时丢弃版本号小于本地缓存数据的同步数据
+		if err := store.setRaw(entry.Key, entry.Val, entry.Version, entry.TTL); err != nil {
+			onErr(entry, err)
+			continue
+		}
+		index.add(entry.Key, entry.Tags)
+	}
+}
@@ -0,0 +1,94 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/coocood/freecache"
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/metrics"
+	"github.com/rumis/multicache/syncer"
+	"github.com/rumis/multicache/tests"
+)
+
+func TestMultiFreeCacheSyncBatch(t *testing.T) {
+
+	ctx := context.WithValue(context.Background(), metrics.MetricsTraceKey, "sync_batch_test")
+	ctx = context.WithValue(ctx, metrics.MetricsClient, metrics.Metrics(metrics.NewMetricsLogger()))
+
+	s := &memorySyncer{clientId: "sender"}
+	sender := NewMultiFreeCache[string, *tests.Student](freecache.NewCache(int(MB)), nil, WithSyncer(s), WithSyncBatch(4*1024, true))
+	peerCache := freecache.NewCache(int(MB))
+	peer := NewMultiFreeCache[string, *tests.Student](peerCache, nil, WithSyncer(&memorySyncer{clientId: "peer"}))
+	s.handlers = append(s.handlers, peer.sync)
+
+	vals := make(adaptor.ValueCol[*tests.Student], 0, 500)
+	keys := make(adaptor.Keys[string], 0, 500)
+	for i := 0; i < 500; i++ {
+		vals = append(vals, &tests.Student{Name: fmt.Sprint("student_", i), Age: i})
+		keys = append(keys, fmt.Sprint("student_", i))
+	}
+	sender.Set(ctx, vals)
+	// 超过单条消息大小上限时拆分
+	if s.emits < 2 || s.emits > 20 {
+		t.Errorf("emits = %d", s.emits)
+	}
+	if peerCache.EntryCount() != 500 {
+		t.Errorf("peer entries = %d, want 500", peerCache.EntryCount())
+	}
+
+	s.emits = 0
+	sender.Del(ctx, keys)
+	if s.emits < 1 || peerCache.EntryCount() != 0 {
+		t.Errorf("emits = %d, peer entries = %d", s.emits, peerCache.EntryCount())
+	}
+
+	// 单条数据广播单条事件
+	s.emits = 0
+	sender.Set(ctx, vals[:1])
+	if s.emits != 1 || s.last.EventType != syncer.EventTypeAdd {
+		t.Errorf("emits = %d, last = %+v", s.emits, s.last)
+	}
+
+	// 默认关闭批量同步，每条数据单独广播，兼容旧版本接收方
+	ds := &memorySyncer{clientId: "default_sender"}
+	NewMultiFreeCache[string, *tests.Student](freecache.NewCache(int(MB)), nil, WithSyncer(ds)).Set(ctx, vals[:3])
+	if ds.emits != 3 || ds.last.EventType != syncer.EventTypeAdd {
+		t.Errorf("default emits = %d, last = %+v", ds.emits, ds.last)
+	}
+}
+
+// memorySyncer 进程内同步器，广播时经编解码后同步调用处理函数
+type memorySyncer struct {
+	clientId string
+	handlers []syncer.EventHandler
+	emits    int
+	last     *syncer.CacheSyncEvent
+}
+
+func (s *memorySyncer) ClientID() string {
+	return s.clientId
+}
+
+func (s *memorySyncer) Emit(ctx context.Context, e *syncer.CacheSyncEvent) error {
+	e.ClientID = s.clientId
+	s.emits++
+	s.last = e
+	for _, fn := range s.handlers {
+		decoded := &syncer.CacheSyncEvent{}
+		if err := decoded.Decode([]byte(e.Encode())); err != nil {
+			return err
+		}
+		fn(decoded)
+	}
+	return nil
+}
+
+func (s *memorySyncer) Subscribe(ctx context.Context, fn syncer.EventHandler) error {
+	return nil
+}
+
+func (s *memorySyncer) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,156 @@
+package local
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+// minPruneKeys 标签索引中的key数量达到该值后才开始清理
+const minPruneKeys = 1024
+
+// tagIndex 本地缓存的标签索引，记录标签与key的关联，nil表示对象未实现Tagged接口，所有操作均为空操作
+// 索引不感知存储的淘汰及过期，key数量达到上次清理后的两倍时清理存储中已不存在的key
+type tagIndex struct {
+	mu sync.Mutex
+	// 标签 -> key集合
+	tags map[string]map[string]struct{}
+	// key -> 标签
+	keys map[string][]string
+	// 判断key是否仍在存储中
+	exists func(key string) bool
+	// 下一次清理时的key数量
+	pruneAt int
+}
+
+// newTagIndex 创建标签索引，对象类型未实现Tagged接口时返回nil
+func newTagIndex[V adaptor.Metadata](store storage[V]) *tagIndex {
+	if !adaptor.IsTagged[V]() {
+		return nil
+	}
+	return &tagIndex{
+		tags: make(map[string]map[string]struct{}),
+		keys: make(map[string][]string),
+		exists: func(key string) bool {
+			_, _, err := store.inspect(key)
+			return err == nil
+		},
+		pruneAt: minPruneKeys,
+	}
+}
+
+// add 记录key的标签，替换key原有的标签
+func (x *tagIndex) add(key string, tags []string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.unlink(key)
+	if len(tags) == 0 {
+		return
+	}
+	x.keys[key] = tags
+	for _, tag := range tags {
+		keys, ok := x.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			x.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	if len(x.keys) >= x.pruneAt {
+		x.prune()
+	}
+}
+
+// tagsOf 返回key的标签
+func (x *tagIndex) tagsOf(key string) []string {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.keys[key]
+}
+
+// remove 删除key的标签
+func (x *tagIndex) remove(key string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.unlink(key)
+}
+
+// removePrefix 删除以prefix开头的key的标签
+func (x *tagIndex) removePrefix(prefix string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for key := range x.keys {
+		if strings.HasPrefix(key, prefix) {
+			x.unlink(key)
+		}
+	}
+}
+
+// take 取出带有tag标签的key并从索引中删除
+func (x *tagIndex) take(tag string) []string {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keys := make([]string, 0, len(x.tags[tag]))
+	for key := range x.tags[tag] {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		x.unlink(key)
+	}
+	return keys
+}
+
+// unlink 解除key与其标签的关联，调用方需持有锁
+func (x *tagIndex) unlink(key string) {
+	for _, tag := range x.keys[key] {
+		delete(x.tags[tag], key)
+		if len(x.tags[tag]) == 0 {
+			delete(x.tags, tag)
+		}
+	}
+	delete(x.keys, key)
+}
+
+// prune 清理存储中已不存在的key，调用方需持有锁
+func (x *tagIndex) prune() {
+	for key := range x.keys {
+		if !x.exists(key) {
+			x.unlink(key)
+		}
+	}
+	x.pruneAt = len(x.keys) * 2
+	if x.pruneAt < minPruneKeys {
+		x.pruneAt = minPruneKeys
+	}
+}
+
+// invalidateTag 删除本地缓存中带有tag标签的数据，返回删除条数
+func invalidateTag[V adaptor.Metadata](store storage[V], index *tagIndex, tag string) int {
+	keys := index.take(tag)
+	for _, key := range keys {
+		store.del(key)
+	}
+	return len(keys)
+}
+
+// invalidatePrefix 删除本地缓存中以prefix开头的数据，返回删除条数
+func invalidatePrefix[V adaptor.Metadata](store storage[V], index *tagIndex, prefix string) int {
+	index.removePrefix(prefix)
+	return store.purge(prefix)
+}
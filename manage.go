@@ -0,0 +1,238 @@
+package multicache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/utils"
+)
+
+// named 具有名称的适配器
+type named interface {
+	Name() string
+}
+
+// Name 缓存场景名称
+func (c *Cache[K, V]) Name() string {
+	return c.name
+}
+
+// Layers 各级适配器名称
+func (c *Cache[K, V]) Layers() []string {
+	return layerNames(c.adaptors)
+}
+
+// Inspect 查询key在各级适配器中的状态，未实现adaptor.Inspector的适配器返回Supported为false的结果
+func (c *Cache[K, V]) Inspect(ctx context.Context, key string) ([]adaptor.EntryInfo, error) {
+	k, err := utils.ParseKey[K](key)
+	if err != nil {
+		return nil, err
+	}
+	return inspectLayers(ctx, c.adaptors, k), nil
+}
+
+// Purge 删除key的缓存，不修改数据源
+// layer为空时自底向上删除各级缓存，否则仅删除指定适配器中的缓存，本地缓存的删除通过其Syncer广播至其他实例
+func (c *Cache[K, V]) Purge(ctx context.Context, key string, layer string) error {
+	k, err := utils.ParseKey[K](key)
+	if err != nil {
+		return err
+	}
+	if layer == "" {
+		return c.evict(ctx, k)
+	}
+	adap, err := findLayer(c.adaptors, layer)
+	if err != nil {
+		return err
+	}
+	err = adap.Del(ctx, k)
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "adaptor", layer, "key", key, "event", adaptor.LogEventEvict)
+	}
+	return err
+}
+
+// Scan 在指定适配器中按前缀扫描key，适配器需实现adaptor.Scanner
+func (c *Cache[K, V]) Scan(ctx context.Context, layer string, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	adap, err := findLayer(c.adaptors, layer)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scanLayer(ctx, adap, prefix, cursor, count)
+}
+
+// InvalidateTag 自底向上删除各级缓存中带有tag标签的数据，不修改数据源
+// 对象需实现adaptor.Tagged接口，未实现adaptor.Invalidator的适配器跳过，本地缓存的删除通过其Syncer广播至其他实例
+func (c *Cache[K, V]) InvalidateTag(ctx context.Context, tag string) error {
+	return invalidateLayers(c.name, c.adaptors, "tag", tag, func(inv adaptor.Invalidator) (int, error) {
+		return inv.InvalidateTag(ctx, tag)
+	})
+}
+
+// InvalidatePrefix 自底向上删除各级缓存中key以prefix开头的数据，不修改数据源
+// 未实现adaptor.Invalidator的适配器跳过，本地缓存的删除通过其Syncer广播至其他实例
+func (c *Cache[K, V]) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return invalidateLayers(c.name, c.adaptors, "prefix", prefix, func(inv adaptor.Invalidator) (int, error) {
+		return inv.InvalidatePrefix(ctx, prefix)
+	})
+}
+
+// Flush 代数加一，清空整个缓存场景，不修改数据源
+// 配置了同一代数的适配器中旧代数的数据立即无法访问并随过期时间淘汰，其他实例通过代数的Syncer或定期刷新感知
+func (c *Cache[K, V]) Flush(ctx context.Context) error {
+	return flush(ctx, c.name, c.generation)
+}
+
+// Name 缓存场景名称
+func (c *MultiCache[K, V]) Name() string {
+	return c.name
+}
+
+// Layers 各级适配器名称
+func (c *MultiCache[K, V]) Layers() []string {
+	return layerNames(c.adaptors)
+}
+
+// Inspect 查询key在各级适配器中的状态，未实现adaptor.Inspector的适配器返回Supported为false的结果
+func (c *MultiCache[K, V]) Inspect(ctx context.Context, key string) ([]adaptor.EntryInfo, error) {
+	k, err := utils.ParseKey[K](key)
+	if err != nil {
+		return nil, err
+	}
+	return inspectLayers(ctx, c.adaptors, k), nil
+}
+
+// Purge 删除key的缓存，不修改数据源
+// layer为空时自底向上删除各级缓存，否则仅删除指定适配器中的缓存，本地缓存的删除通过其Syncer广播至其他实例
+func (c *MultiCache[K, V]) Purge(ctx context.Context, key string, layer string) error {
+	k, err := utils.ParseKey[K](key)
+	if err != nil {
+		return err
+	}
+	if layer == "" {
+		return c.evict(ctx, adaptor.Keys[K]{k})
+	}
+	adap, err := findLayer(c.adaptors, layer)
+	if err != nil {
+		return err
+	}
+	err = adap.Del(ctx, adaptor.Keys[K]{k})
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "adaptor", layer, "key", key, "event", adaptor.LogEventEvict)
+	}
+	return err
+}
+
+// Scan 在指定适配器中按前缀扫描key，适配器需实现adaptor.Scanner
+func (c *MultiCache[K, V]) Scan(ctx context.Context, layer string, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	adap, err := findLayer(c.adaptors, layer)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scanLayer(ctx, adap, prefix, cursor, count)
+}
+
+// InvalidateTag 自底向上删除各级缓存中带有tag标签的数据，不修改数据源
+// 对象需实现adaptor.Tagged接口，未实现adaptor.Invalidator的适配器跳过，本地缓存的删除通过其Syncer广播至其他实例
+func (c *MultiCache[K, V]) InvalidateTag(ctx context.Context, tag string) error {
+	return invalidateLayers(c.name, c.adaptors, "tag", tag, func(inv adaptor.Invalidator) (int, error) {
+		return inv.InvalidateTag(ctx, tag)
+	})
+}
+
+// InvalidatePrefix 自底向上删除各级缓存中key以prefix开头的数据，不修改数据源
+// 未实现adaptor.Invalidator的适配器跳过，本地缓存的删除通过其Syncer广播至其他实例
+func (c *MultiCache[K, V]) InvalidatePrefix(ctx context.Context, prefix string) error {
+	return invalidateLayers(c.name, c.adaptors, "prefix", prefix, func(inv adaptor.Invalidator) (int, error) {
+		return inv.InvalidatePrefix(ctx, prefix)
+	})
+}
+
+// Flush 代数加一，清空整个缓存场景，不修改数据源
+// 配置了同一代数的适配器中旧代数的数据立即无法访问并随过期时间淘汰，其他实例通过代数的Syncer或定期刷新感知
+func (c *MultiCache[K, V]) Flush(ctx context.Context) error {
+	return flush(ctx, c.name, c.generation)
+}
+
+// layerNames 适配器名称列表
+func layerNames[A named](adaptors []A) []string {
+	names := make([]string, 0, len(adaptors))
+	for _, adap := range adaptors {
+		names = append(names, adap.Name())
+	}
+	return names
+}
+
+// findLayer 按名称查找适配器
+func findLayer[A named](adaptors []A, layer string) (A, error) {
+	for _, adap := range adaptors {
+		if adap.Name() == layer {
+			return adap, nil
+		}
+	}
+	var zero A
+	return zero, fmt.Errorf("%w: %s", adaptor.ErrAdaptorNotFound, layer)
+}
+
+// inspectLayers 依次查询key在各级适配器中的状态，单个适配器查询失败不影响其余适配器
+func inspectLayers[K comparable, A named](ctx context.Context, adaptors []A, key K) []adaptor.EntryInfo {
+	infos := make([]adaptor.EntryInfo, 0, len(adaptors))
+	for _, adap := range adaptors {
+		inspector, ok := any(adap).(adaptor.Inspector[K])
+		if !ok {
+			infos = append(infos, adaptor.EntryInfo{Adaptor: adap.Name()})
+			continue
+		}
+		info, err := inspector.Inspect(ctx, key)
+		info.Adaptor = adap.Name()
+		info.Supported = true
+		info.Err = err
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// scanLayer 在适配器中按前缀扫描key
+func scanLayer[A named](ctx context.Context, adap A, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	scanner, ok := any(adap).(adaptor.Scanner)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: scan %s", adaptor.ErrNotSupported, adap.Name())
+	}
+	return scanner.Scan(ctx, prefix, cursor, count)
+}
+
+// invalidateLayers 自底向上在实现了adaptor.Invalidator的适配器中批量删除数据，单个适配器失败不影响其余适配器，返回第一个错误
+func invalidateLayers[A named](name string, adaptors []A, field string, value string, fn func(adaptor.Invalidator) (int, error)) error {
+	var firstErr error
+	for i := len(adaptors) - 1; i >= 0; i-- {
+		inv, ok := any(adaptors[i]).(adaptor.Invalidator)
+		if !ok {
+			continue
+		}
+		n, err := fn(inv)
+		if err != nil {
+			logger.Error(err.Error(), "solution", name, "adaptor", adaptors[i].Name(), field, value, "count", n, "event", adaptor.LogEventInvalidate)
+			firstErr = utils.IfExpr(firstErr == nil, err, firstErr)
+			continue
+		}
+		logger.Info("cache invalidated", "solution", name, "adaptor", adaptors[i].Name(), field, value, "count", n, "event", adaptor.LogEventInvalidate)
+	}
+	return firstErr
+}
+
+// flush 代数加一，未配置代数时返回adaptor.ErrNotSupported
+func flush(ctx context.Context, name string, g *generation.Generation) error {
+	if g == nil {
+		return fmt.Errorf("%w: flush %s without generation", adaptor.ErrNotSupported, name)
+	}
+	n, err := g.Bump(ctx)
+	if err != nil {
+		logger.Error(err.Error(), "solution", name, "generation", g.Key(), "event", adaptor.LogEventGeneration)
+		return err
+	}
+	logger.Info("cache flushed", "solution", name, "generation", n, "event", adaptor.LogEventGeneration)
+	return nil
+}
@@ -6,6 +6,9 @@ const (
 	Hit MetaEvent = iota + 1 // Hit
 	Miss
 	Set
+	Stale
+	Reject
+	Hot // 热点key
 )
 
 // QueryResultTypeString 返回查询结果类型的字符串表示
@@ -17,6 +20,12 @@ func MetaEventString(t MetaEvent) string {
 		return "Miss"
 	case Set:
 		return "Set"
+	case Stale:
+		return "Stale"
+	case Reject:
+		return "Reject"
+	case Hot:
+		return "Hot"
 	default:
 		return "Unknown"
 	}
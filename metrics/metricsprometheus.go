@@ -62,3 +62,12 @@ func (m *MetricsPrometheus) AddMeta(ctx context.Context, meta Meta) error {
 func (m *MetricsPrometheus) Summary(ctx context.Context) error {
 	return nil
 }
+
+// Close 推送剩余的指标数据并等待推送协程退出
+func (m *MetricsPrometheus) Close(ctx context.Context) error {
+	err := m.promCounter.Close(ctx)
+	if histErr := m.promHistogram.Close(ctx); err == nil {
+		err = histErr
+	}
+	return err
+}
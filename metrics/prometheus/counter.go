@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,11 +17,14 @@ type Counter struct {
 	cnt  prometheus.Counter
 	ch   chan []Label
 	job  string
-	run  bool
+	// 停止数据消费协程
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewCounter 创建计数器
-// 每创建一个计数器附带创建一个协程消费数据，当不再使用时需要调用Stop方法销毁协程
+// 每创建一个计数器附带创建一个协程消费数据，当不再使用时需要调用Close或Stop方法销毁协程
 func NewCounter(namespace string, name string, job string, fns ...PrometheusClientOptionsHandle) *Counter {
 	opts := DefaultPrometheusClientOptions()
 	for _, fn := range fns {
@@ -33,9 +37,10 @@ func NewCounter(namespace string, name string, job string, fns ...PrometheusClie
 			Name:      name,
 			Help:      namespace + "." + name,
 		}),
-		ch:  make(chan []Label, opts.CacheSize),
-		job: job,
-		run: true,
+		ch:   make(chan []Label, opts.CacheSize),
+		job:  job,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
 	}
 	go counter.pusher()
 	return counter
@@ -43,6 +48,11 @@ func NewCounter(namespace string, name string, job string, fns ...PrometheusClie
 
 // Incr 计数器递增
 func (c *Counter) Incr(labels ...Label) error {
+	select {
+	case <-c.stop:
+		return Error_Closed
+	default:
+	}
 	select {
 	case c.ch <- labels:
 	default:
@@ -51,49 +61,64 @@ func (c *Counter) Incr(labels ...Label) error {
 	return nil
 }
 
-// Stop 销毁数据消费协程
+// Stop 通知数据消费协程推送剩余数据后退出，不等待推送完成
 func (c *Counter) Stop() {
-	c.run = false
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// Close 推送剩余数据并等待数据消费协程退出，ctx到期时返回ctx.Err()
+func (c *Counter) Close(ctx context.Context) error {
+	c.Stop()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// pusher 指标数据推送协程
+// pusher 指标数据推送协程，停止后推送通道中的剩余数据再退出
 func (c *Counter) pusher() {
-	defer func() {
-		if err := recover(); err != nil {
-			if c.opts.PanicErrorHandle != nil {
-				c.opts.PanicErrorHandle(errors.New(fmt.Sprint(err)))
-			}
-			go c.pusher() // 重新创建指标数据推送协程
-		}
-	}()
+	defer close(c.done)
 	for {
-		var labels []Label
-		var ok bool
 		select {
-		case labels, ok = <-c.ch:
-		default:
-		}
-		if !ok {
-			time.Sleep(c.opts.PusherWaitingTimeout)
-			if !c.run {
-				return
+		case labels := <-c.ch:
+			c.push(labels)
+		case <-c.stop:
+			for {
+				select {
+				case labels := <-c.ch:
+					c.push(labels)
+				default:
+					return
+				}
 			}
-			continue
-		}
-		// 计数器递增
-		c.cnt.Inc()
-		// 创建pusher
-		pusher := push.New(c.opts.GateWayHost, c.job).Collector(c.cnt)
-		// 附加标签
-		for _, v := range labels {
-			pusher.Grouping(v.Name, v.Value)
 		}
-		// 推送
-		ctx, cfn := context.WithTimeout(context.Background(), time.Second*3)
-		err := pusher.PushContext(ctx)
-		if err != nil && c.opts.PushErrorHandle != nil {
-			c.opts.PushErrorHandle(err)
+	}
+}
+
+// push 推送单条指标数据
+func (c *Counter) push(labels []Label) {
+	defer func() {
+		if err := recover(); err != nil && c.opts.PanicErrorHandle != nil {
+			c.opts.PanicErrorHandle(errors.New(fmt.Sprint(err)))
 		}
-		cfn() // 调用取消函数，防止内存泄露
+	}()
+	// 计数器递增
+	c.cnt.Inc()
+	// 创建pusher
+	pusher := push.New(c.opts.GateWayHost, c.job).Collector(c.cnt)
+	// 附加标签
+	for _, v := range labels {
+		pusher.Grouping(v.Name, v.Value)
+	}
+	// 推送
+	ctx, cfn := context.WithTimeout(context.Background(), time.Second*3)
+	defer cfn() // 调用取消函数，防止内存泄露
+	err := pusher.PushContext(ctx)
+	if err != nil && c.opts.PushErrorHandle != nil {
+		c.opts.PushErrorHandle(err)
 	}
 }
@@ -1,8 +1,13 @@
 package prometheus
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -61,3 +66,30 @@ func TestCounter(t *testing.T) {
 	time.Sleep(time.Minute * 1)
 
 }
+
+func TestCounterClose(t *testing.T) {
+
+	var pushes int32
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pushes, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	counter := NewCounter("multicache", "cache_close", "multicache_test_solution", WithGatewayHost(gateway.URL))
+	for i := 0; i < 10; i++ {
+		counter.Incr(Label{Name: "event", Value: "hit"})
+	}
+	// 关闭时推送剩余数据
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := counter.Close(ctx); err != nil {
+		t.Fatal("Close Error", err)
+	}
+	if n := atomic.LoadInt32(&pushes); n != 10 {
+		t.Errorf("pushes = %d, want 10", n)
+	}
+	if err := counter.Incr(); !errors.Is(err, Error_Closed) {
+		t.Errorf("err = %v, want Error_Closed", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -22,11 +23,14 @@ type Histogram struct {
 	hist prometheus.Histogram
 	ch   chan HistogramMessage
 	job  string
-	run  bool
+	// 停止数据消费协程
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewHistogram 创建直方图指标
-// 每创建一个直方图指标附带创建一个协程消费数据，当不再使用时需要调用Stop方法销毁协程
+// 每创建一个直方图指标附带创建一个协程消费数据，当不再使用时需要调用Close或Stop方法销毁协程
 // 建议创建的指标推送器采用单例，避免协程泄露
 func NewHistogram(namespace string, name string, job string, bucket []float64, fns ...PrometheusClientOptionsHandle) *Histogram {
 	opts := DefaultPrometheusClientOptions()
@@ -42,9 +46,10 @@ func NewHistogram(namespace string, name string, job string, bucket []float64, f
 			Help:      namespace + "." + name,
 			Buckets:   bucket,
 		}),
-		ch:  make(chan HistogramMessage, opts.CacheSize),
-		job: job,
-		run: true,
+		ch:   make(chan HistogramMessage, opts.CacheSize),
+		job:  job,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
 	}
 	go h.pusher()
 	return h
@@ -52,6 +57,11 @@ func NewHistogram(namespace string, name string, job string, bucket []float64, f
 
 // Observe 记录值
 func (h *Histogram) Observe(val float64, labels ...Label) error {
+	select {
+	case <-h.stop:
+		return Error_Closed
+	default:
+	}
 	select {
 	case h.ch <- HistogramMessage{
 		Value:  val,
@@ -63,49 +73,64 @@ func (h *Histogram) Observe(val float64, labels ...Label) error {
 	return nil
 }
 
-// Stop 销毁数据消费协程
+// Stop 通知数据消费协程推送剩余数据后退出，不等待推送完成
 func (h *Histogram) Stop() {
-	h.run = false
+	h.stopOnce.Do(func() {
+		close(h.stop)
+	})
+}
+
+// Close 推送剩余数据并等待数据消费协程退出，ctx到期时返回ctx.Err()
+func (h *Histogram) Close(ctx context.Context) error {
+	h.Stop()
+	select {
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// pusher 指标数据推送协程
+// pusher 指标数据推送协程，停止后推送通道中的剩余数据再退出
 func (h *Histogram) pusher() {
-	defer func() {
-		if err := recover(); err != nil {
-			if h.opts.PanicErrorHandle != nil {
-				h.opts.PanicErrorHandle(errors.New(fmt.Sprint(err)))
-			}
-			go h.pusher() // 重新创建指标数据推送协程
-		}
-	}()
+	defer close(h.done)
 	for {
-		var histMsg HistogramMessage
-		var ok bool
 		select {
-		case histMsg, ok = <-h.ch:
-		default:
-		}
-		if !ok {
-			time.Sleep(h.opts.PusherWaitingTimeout * time.Millisecond)
-			if !h.run { // 停止消费协程
-				return
+		case histMsg := <-h.ch:
+			h.push(histMsg)
+		case <-h.stop:
+			for {
+				select {
+				case histMsg := <-h.ch:
+					h.push(histMsg)
+				default:
+					return
+				}
 			}
-			continue
-		}
-		// 设置值
-		h.hist.Observe(histMsg.Value)
-		// 创建pusher
-		pusher := push.New(h.opts.GateWayHost, h.job).Collector(h.hist)
-		// 附加标签
-		for _, v := range histMsg.Labels {
-			pusher.Grouping(v.Name, v.Value)
 		}
-		// 推送
-		ctx, cfn := context.WithTimeout(context.Background(), time.Second*3)
-		err := pusher.PushContext(ctx)
-		if err != nil && h.opts.PushErrorHandle != nil {
-			h.opts.PushErrorHandle(err)
+	}
+}
+
+// push 推送单条指标数据
+func (h *Histogram) push(histMsg HistogramMessage) {
+	defer func() {
+		if err := recover(); err != nil && h.opts.PanicErrorHandle != nil {
+			h.opts.PanicErrorHandle(errors.New(fmt.Sprint(err)))
 		}
-		cfn() // 调用取消函数，防止内存泄露
+	}()
+	// 设置值
+	h.hist.Observe(histMsg.Value)
+	// 创建pusher
+	pusher := push.New(h.opts.GateWayHost, h.job).Collector(h.hist)
+	// 附加标签
+	for _, v := range histMsg.Labels {
+		pusher.Grouping(v.Name, v.Value)
+	}
+	// 推送
+	ctx, cfn := context.WithTimeout(context.Background(), time.Second*3)
+	defer cfn() // 调用取消函数，防止内存泄露
+	err := pusher.PushContext(ctx)
+	if err != nil && h.opts.PushErrorHandle != nil {
+		h.opts.PushErrorHandle(err)
 	}
 }
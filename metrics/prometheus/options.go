@@ -12,6 +12,7 @@ import (
 var (
 	Error_ChannelFull = errors.New("channel is full,this event will be drop")
 	Error_ClientNil   = errors.New("client initialize failed")
+	Error_Closed      = errors.New("pusher is closed,this event will be drop")
 )
 
 const (
@@ -43,6 +44,7 @@ type PrometheusClientOptions struct {
 	PromReadHost         string
 	PromHttpApiQueryHost string
 	// 当Channel中元素空时，pusher协程等待时间
+	// Deprecated: pusher协程阻塞等待数据，该配置不再生效
 	PusherWaitingTimeout time.Duration
 }
 
@@ -100,6 +102,7 @@ func WithCacheSize(size int) PrometheusClientOptionsHandle {
 }
 
 // WithPusherWaitingTimeout 配置消息推送线程空数据等待时间
+// Deprecated: pusher协程阻塞等待数据，该配置不再生效
 func WithPusherWaitingTimeout(ts time.Duration) PrometheusClientOptionsHandle {
 	return func(opts *PrometheusClientOptions) {
 		opts.PusherWaitingTimeout = ts
@@ -2,8 +2,11 @@ package multicache
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/utils"
@@ -13,24 +16,47 @@ type MultiCache[K comparable, V adaptor.Metadata] struct {
 	name     string
 	adaptors []adaptor.MultiAdaptor[K, V]
 	metric   metrics.Metrics
+	// 数据源写入器
+	writer *writer[K, V]
+	// 延迟双删
+	deleter *doubleDeleter[K]
+	// 热点key探测
+	hotKey *hotkey.Detector
+	// 缓存代数
+	generation *generation.Generation
+	// 自适应过期时间
+	adaptiveTTL *hotkey.AdaptiveTTL
 }
 
 // NewMultiCache 创建一个新的MultiCache对象
 func NewMultiCache[K comparable, V adaptor.Metadata](name string, adaptors ...adaptor.MultiAdaptor[K, V]) *MultiCache[K, V] {
-	return &MultiCache[K, V]{
-		name:     name,
-		adaptors: adaptors,
-		metric:   metrics.DefaultMetrics(),
-	}
+	return NewMultiCacheWithOption(name, adaptors)
 }
 
 // NewMultiCacheWithMetric 创建一个新的MultiCache对象，包含自定义指标计数器
 func NewMultiCacheWithMetric[K comparable, V adaptor.Metadata](name string, metric metrics.Metrics, adaptors ...adaptor.MultiAdaptor[K, V]) *MultiCache[K, V] {
-	return &MultiCache[K, V]{
-		name:     name,
-		adaptors: adaptors,
-		metric:   metric,
+	return NewMultiCacheWithOption(name, adaptors, WithMetric[K, V](metric))
+}
+
+// NewMultiCacheWithOption 创建一个新的MultiCache对象，支持指标计数器、写策略等自定义配置
+func NewMultiCacheWithOption[K comparable, V adaptor.Metadata](name string, adaptors []adaptor.MultiAdaptor[K, V], fns ...CacheOptionFunc[K, V]) *MultiCache[K, V] {
+	opts := DefaultCacheOption[K, V]()
+	for _, fn := range fns {
+		fn(&opts)
 	}
+	cacheInst := &MultiCache[K, V]{
+		name:        name,
+		adaptors:    adaptors,
+		metric:      opts.Metric,
+		writer:      newWriter(name, opts),
+		hotKey:      opts.HotKey,
+		generation:  opts.Generation,
+		adaptiveTTL: opts.AdaptiveTTL,
+	}
+	cacheInst.deleter = newDoubleDeleter(opts.DoubleDeleteDelay, func(ctx context.Context, keys adaptor.Keys[K]) error {
+		return cacheInst.evict(ctx, keys)
+	})
+	return cacheInst
 }
 
 // Get 读取对象
@@ -40,12 +66,28 @@ func (c *MultiCache[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], vals a
 	ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
 	c.metric.Start(ctx, c.name)
 
+	if c.hotKey != nil {
+		for _, key := range keys {
+			c.hotKey.Observe(ctx, fmt.Sprint(key))
+		}
+	}
+	if c.adaptiveTTL != nil {
+		for _, key := range keys {
+			c.adaptiveTTL.ObserveRead(fmt.Sprint(key))
+		}
+	}
+
 	tmpKeys := keys
 	for _, adap := range c.adaptors {
 		_, err := adap.Get(ctx, tmpKeys, vals, fn)
 		if err != nil {
 			// 错误日志
 			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", tmpKeys, "event", adaptor.LogEventGet)
+			// 调用方已取消或超时
+			if ctx.Err() != nil {
+				c.metric.Summary(ctx)
+				return ctx.Err()
+			}
 		}
 
 		if len(vals) == len(keys) {
@@ -85,16 +127,46 @@ func (c *MultiCache[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], vals a
 }
 
 // Set 向缓存中写入对象
+// 开启写策略时数据源与各级缓存的写入顺序及失败语义见WritePolicy
 func (c *MultiCache[K, V]) Set(ctx context.Context, vals adaptor.ValueCol[V]) error {
 
 	ctx = context.WithValue(ctx, metrics.MetricsTraceKey, utils.UUID())
 	ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
 	c.metric.Start(ctx, c.name)
 
+	if c.adaptiveTTL != nil {
+		for _, val := range vals {
+			c.adaptiveTTL.ObserveWrite(val.Key())
+		}
+	}
+
+	var err error
+	switch c.writer.policy {
+	case WritePolicyThrough, WritePolicyInvalidate:
+		err = c.writer.write(ctx, vals)
+	case WritePolicyBehind:
+		err = c.writer.enqueueSet(vals)
+	}
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "value", vals, "event", adaptor.LogEventWrite)
+		c.metric.Summary(ctx)
+		return err
+	}
+
+	if c.writer.policy == WritePolicyInvalidate {
+		err = c.evictValues(ctx, vals)
+		c.metric.Summary(ctx)
+		return err
+	}
+
 	for _, adap := range c.adaptors {
 		err := adap.Set(ctx, vals)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "value", vals, "event", adaptor.LogEventSet)
+			// 数据源已接受写入，删除各级缓存中可能不一致的数据
+			if c.writer.policy != WritePolicyNone {
+				c.evictValues(ctx, vals)
+			}
 			return err
 		}
 	}
@@ -103,13 +175,85 @@ func (c *MultiCache[K, V]) Set(ctx context.Context, vals adaptor.ValueCol[V]) er
 }
 
 // Del 删除缓存对象
+// 开启写策略时先删除数据源(异步写回模式下进入队列)，再自底向上删除各级缓存
+// 开启延迟双删时，经过DoubleDeleteDelay后再次删除各级缓存
 func (c *MultiCache[K, V]) Del(ctx context.Context, keys adaptor.Keys[K]) error {
-	for _, adap := range c.adaptors {
-		err := adap.Del(ctx, keys)
+	if c.adaptiveTTL != nil {
+		for _, key := range keys {
+			c.adaptiveTTL.ObserveWrite(fmt.Sprint(key))
+		}
+	}
+
+	var err error
+	switch c.writer.policy {
+	case WritePolicyBehind:
+		err = c.writer.enqueueDel(keys)
+	case WritePolicyThrough, WritePolicyInvalidate:
+		err = c.writer.delete(ctx, keys)
+	}
+	if err != nil {
+		logger.Error(err.Error(), "solution", c.name, "key", keys, "event", adaptor.LogEventWrite)
+		return err
+	}
+
+	if c.writer.policy == WritePolicyNone {
+		for _, adap := range c.adaptors {
+			err = adap.Del(ctx, keys)
+			if err != nil {
+				logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", keys, "event", adaptor.LogEventDel)
+				break
+			}
+		}
+	} else {
+		err = c.evict(ctx, keys)
+	}
+
+	c.deleter.schedule(keys)
+	return err
+}
+
+// SyncWrites 立即将异步写回队列中的操作写入数据源并等待完成
+// 返回本次刷新中重试耗尽的第一个错误
+func (c *MultiCache[K, V]) SyncWrites(ctx context.Context) error {
+	return c.writer.sync(ctx)
+}
+
+// PendingDeletes 待执行及执行中的延迟双删key数量
+func (c *MultiCache[K, V]) PendingDeletes() int {
+	return c.deleter.count()
+}
+
+// DrainDeletes 立即执行所有待执行的延迟双删并等待完成，用于服务退出前
+func (c *MultiCache[K, V]) DrainDeletes(ctx context.Context) error {
+	return c.deleter.drain(ctx)
+}
+
+// evict 自底向上删除各级缓存中的key，避免上层缓存从尚未删除的下层缓存回填旧数据
+// 单个适配器删除失败不影响其余适配器，返回第一个错误
+func (c *MultiCache[K, V]) evict(ctx context.Context, keys adaptor.Keys[K]) error {
+	var firstErr error
+	for i := len(c.adaptors) - 1; i >= 0; i-- {
+		err := c.adaptors[i].Del(ctx, keys)
 		if err != nil {
-			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", keys, "event", adaptor.LogEventDel)
+			logger.Error(err.Error(), "solution", c.name, "adaptor", c.adaptors[i].Name(), "key", keys, "event", adaptor.LogEventEvict)
+			firstErr = utils.IfExpr(firstErr == nil, err, firstErr)
+		}
+	}
+	return firstErr
+}
+
+// evictValues 删除对象对应的各级缓存，并计划延迟双删
+func (c *MultiCache[K, V]) evictValues(ctx context.Context, vals adaptor.ValueCol[V]) error {
+	keys := make(adaptor.Keys[K], 0, len(vals))
+	for _, val := range vals {
+		key, err := utils.ParseKey[K](val.Key())
+		if err != nil {
+			logger.Error(err.Error(), "solution", c.name, "value", val, "event", adaptor.LogEventEvict)
 			return err
 		}
+		keys = append(keys, key)
 	}
-	return nil
+	err := c.evict(ctx, keys)
+	c.deleter.schedule(keys)
+	return err
 }
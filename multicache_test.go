@@ -3,16 +3,22 @@ package multicache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/rumis/multicache/adaptor"
 	"github.com/rumis/multicache/datasource"
 	"github.com/rumis/multicache/local"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/remote"
 	"github.com/rumis/multicache/tests"
+	"github.com/rumis/multicache/utils"
 )
 
 func TestMultiCache(t *testing.T) {
@@ -85,6 +91,355 @@ func TestMultiCacheSet(t *testing.T) {
 
 }
 
+func TestMultiCacheBatch(t *testing.T) {
+	testMultiCacheBatch(t, tests.NewRedisClient())
+}
+
+func TestMultiCacheCluster(t *testing.T) {
+	testMultiCacheBatch(t, tests.NewRedisClusterClient())
+}
+
+func testMultiCacheBatch(t *testing.T, client redis.UniversalClient) {
+
+	loadKeys := 0
+	testRemoteMulti := remote.NewRedisMultiAdaptor[string, *tests.Student](client, nil)
+	testDataSourceMulti := datasource.NewDataSourceMultiAdaptor[string, *tests.Student](testRemoteMulti, func(keys adaptor.Keys[string]) (adaptor.Values[string, *tests.Student], error) {
+		loadKeys += len(keys)
+		vals := make(adaptor.Values[string, *tests.Student], 0)
+		for _, key := range keys {
+			vals[key] = &tests.Student{
+				Name: key,
+				Age:  18,
+			}
+		}
+		return vals, nil
+	})
+
+	multiCacheInst := NewMultiCache[string, *tests.Student]("multicache_batch_test", testRemoteMulti, testDataSourceMulti)
+
+	keys := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		keys = append(keys, "s_"+strconv.Itoa(i))
+	}
+	newStudent := func() *tests.Student {
+		return &tests.Student{}
+	}
+
+	// 首次获取数据，全部穿透至数据源
+	s := make(map[string]*tests.Student)
+	err := multiCacheInst.Get(context.Background(), keys, s, newStudent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != len(keys) || loadKeys != len(keys) {
+		t.Fatalf("got %d values, loaded %d keys", len(s), loadKeys)
+	}
+
+	// 再次获取，全部命中分布式缓存
+	s1 := make(map[string]*tests.Student)
+	err = multiCacheInst.Get(context.Background(), keys, s1, newStudent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s1) != len(keys) || loadKeys != len(keys) {
+		t.Fatalf("got %d values, loaded %d keys", len(s1), loadKeys)
+	}
+
+	// 删除部分数据后再次获取
+	err = multiCacheInst.Del(context.Background(), keys[:100])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2 := make(map[string]*tests.Student)
+	err = multiCacheInst.Get(context.Background(), keys, s2, newStudent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s2) != len(keys) || loadKeys != len(keys)+100 {
+		t.Fatalf("got %d values, loaded %d keys", len(s2), loadKeys)
+	}
+}
+
+func TestMultiCacheMissingKeys(t *testing.T) {
+
+	loadKeys := 0
+	testRemoteMulti := MultiRemoteCacheTest(nil)
+	testDataSourceMulti := datasource.NewDataSourceMultiAdaptor[string, *tests.Student](testRemoteMulti, func(keys adaptor.Keys[string]) (adaptor.Values[string, *tests.Student], error) {
+		loadKeys += len(keys)
+		vals := make(adaptor.Values[string, *tests.Student], 0)
+		for _, key := range keys {
+			// 数据源中仅存在张三
+			if key == "张三" {
+				vals[key] = &tests.Student{
+					Name: key,
+					Age:  18,
+				}
+			}
+		}
+		return vals, nil
+	})
+
+	multiCacheInst := NewMultiCache[string, *tests.Student]("multicache_missing_test", testRemoteMulti, testDataSourceMulti)
+
+	for i := 0; i < 2; i++ {
+		s := make(map[string]*tests.Student)
+		err := multiCacheInst.Get(context.Background(), []string{"张三", "李四", "王五"}, s, func() *tests.Student {
+			return &tests.Student{}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s) != 1 || s["张三"] == nil {
+			t.Errorf("unexpected result %v", s)
+		}
+	}
+
+	// 不存在的key已缓存零值，第二次查询不再穿透至数据源
+	if loadKeys != 3 {
+		t.Errorf("loaded %d keys, want 3", loadKeys)
+	}
+}
+
+func TestMultiCacheSingleflight(t *testing.T) {
+
+	var loadKeys int32
+	testRemoteMulti := MultiRemoteCacheTest(nil)
+	testDataSourceMulti := datasource.NewDataSourceMultiAdaptor[string, *tests.Student](testRemoteMulti, func(keys adaptor.Keys[string]) (adaptor.Values[string, *tests.Student], error) {
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&loadKeys, int32(len(keys)))
+		vals := make(adaptor.Values[string, *tests.Student], 0)
+		for _, key := range keys {
+			vals[key] = &tests.Student{
+				Name: key,
+				Age:  18,
+			}
+		}
+		return vals, nil
+	})
+
+	multiCacheInst := NewMultiCache[string, *tests.Student]("multicache_singleflight_test", testRemoteMulti, testDataSourceMulti)
+
+	// 并发请求的key两两重叠
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			keys := []string{"s_" + strconv.Itoa(idx), "s_" + strconv.Itoa(idx+1)}
+			s := make(map[string]*tests.Student)
+			err := multiCacheInst.Get(context.Background(), keys, s, func() *tests.Student {
+				return &tests.Student{}
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			if len(s) != 2 {
+				t.Errorf("unexpected result %v", s)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// 每个key仅加载一次
+	if n := atomic.LoadInt32(&loadKeys); n != 6 {
+		t.Errorf("loaded %d keys, want 6", n)
+	}
+}
+
+func TestMultiCacheContext(t *testing.T) {
+
+	testRemoteMulti := MultiRemoteCacheTest(nil)
+	testDataSourceMulti := datasource.NewDataSourceMultiAdaptorWithContext[string, *tests.Student](testRemoteMulti, func(ctx context.Context, keys adaptor.Keys[string]) (adaptor.Values[string, *tests.Student], error) {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return make(adaptor.Values[string, *tests.Student]), nil
+	})
+
+	multiCacheInst := NewMultiCache[string, *tests.Student]("multicache_context_test", testRemoteMulti, testDataSourceMulti)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	s := make(map[string]*tests.Student)
+	err := multiCacheInst.Get(ctx, []string{"张三", "李四"}, s, func() *tests.Student {
+		return &tests.Student{}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+	if time.Since(startTime) > 500*time.Millisecond {
+		t.Error("Get should return when the caller gives up")
+	}
+}
+
+func TestMultiCacheWriteBehind(t *testing.T) {
+
+	var mu sync.Mutex
+	db := make(map[string]*tests.Student)
+	var failures int32
+	writeFn := func(ctx context.Context, vals adaptor.ValueCol[*tests.Student]) error {
+		// 首次写入失败，由重试写入
+		if atomic.AddInt32(&failures, 1) == 1 {
+			return errors.New("db unavailable")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, val := range vals {
+			db[val.Key()] = val
+		}
+		return nil
+	}
+	deleteFn := func(ctx context.Context, keys adaptor.Keys[string]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range keys {
+			delete(db, key)
+		}
+		return nil
+	}
+
+	testLocalMulti := local.NewMultiFreeCache[string, *tests.Student](local.FreeCacheClient(), nil, local.WithPrefix("multicache_write_behind_"))
+	multiCacheInst := NewMultiCacheWithOption("multicache_write_behind_test", []adaptor.MultiAdaptor[string, *tests.Student]{testLocalMulti},
+		WithWriteBehind(writeFn, deleteFn),
+		WithWriteBehindOption[string, *tests.Student](WriteBehindOption{
+			BatchSize:     2,
+			FlushInterval: 50 * time.Millisecond,
+			QueueSize:     100,
+			MaxRetry:      2,
+			RetryInterval: 10 * time.Millisecond,
+		}))
+
+	err := multiCacheInst.Set(context.Background(), []*tests.Student{
+		{Name: "张三", Age: 18},
+		{Name: "李四", Age: 19},
+		{Name: "王五", Age: 20},
+	})
+	if err != nil {
+		t.Fatal("Set Error", err)
+	}
+	err = multiCacheInst.Del(context.Background(), adaptor.Keys[string]{"王五"})
+	if err != nil {
+		t.Fatal("Del Error", err)
+	}
+
+	// 后台协程按刷新间隔写入数据源
+	time.Sleep(300 * time.Millisecond)
+	multiCacheInst.SyncWrites(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(db) != 2 || db["张三"] == nil || db["李四"] == nil {
+		t.Errorf("db = %v, want 张三 and 李四", db)
+	}
+}
+
+func TestMultiCacheVersioned(t *testing.T) {
+
+	testRemoteMulti := remote.NewRedisMultiAdaptor[string, *tests.VersionedStudent](tests.NewRedisClusterClient(), nil)
+	multiCacheInst := NewMultiCache[string, *tests.VersionedStudent]("multicache_versioned_test", testRemoteMulti)
+	newStudent := func(name string, age int, version int64) *tests.VersionedStudent {
+		return &tests.VersionedStudent{Student: tests.Student{Name: name, Age: age, Time: version}}
+	}
+
+	err := multiCacheInst.Set(context.Background(), adaptor.ValueCol[*tests.VersionedStudent]{newStudent("张三", 20, 2), newStudent("李四", 20, 2)})
+	if err != nil {
+		t.Fatal("Set Error", err)
+	}
+	// 张三的旧版本数据被丢弃，李四的新版本数据正常写入
+	err = multiCacheInst.Set(context.Background(), adaptor.ValueCol[*tests.VersionedStudent]{newStudent("张三", 18, 1), newStudent("李四", 21, 3)})
+	if err != nil {
+		t.Fatal("Set Error", err)
+	}
+
+	vals := make(adaptor.Values[string, *tests.VersionedStudent])
+	err = multiCacheInst.Get(context.Background(), adaptor.Keys[string]{"张三", "李四"}, vals, func() *tests.VersionedStudent {
+		return &tests.VersionedStudent{}
+	})
+	if err != nil {
+		t.Fatal("Get Error", err)
+	}
+	if vals["张三"] == nil || vals["张三"].Age != 20 || vals["李四"] == nil || vals["李四"].Age != 21 {
+		t.Errorf("vals = %v, want 张三=20 李四=21", vals)
+	}
+}
+
+func TestMultiCacheObjectStore(t *testing.T) {
+
+	// 单值及批量缓存共用同一对象存储
+	store := local.NewObjectStore(local.WithImmutable[*tests.Student]())
+	testLocal := local.NewTypedLocalCache[string, *tests.Student](store, nil)
+	testLocalMulti := local.NewTypedMultiLocalCache[string, *tests.Student](store, nil)
+
+	s := &tests.Student{Name: "张三", Age: 18}
+	if err := NewCache[string, *tests.Student]("cache_object_test", testLocal).Set(context.Background(), s); err != nil {
+		t.Fatal("Set Error", err)
+	}
+
+	vals := make(adaptor.Values[string, *tests.Student])
+	err := NewMultiCache[string, *tests.Student]("multicache_object_test", testLocalMulti).Get(context.Background(), adaptor.Keys[string]{"张三"}, vals, func() *tests.Student {
+		return &tests.Student{}
+	})
+	if err != nil {
+		t.Fatal("Get Error", err)
+	}
+	// 不可变对象命中时直接返回存储的对象，无需反序列化
+	if vals["张三"] != s {
+		t.Error("immutable object should be returned without copy")
+	}
+}
+
+func TestMultiCacheWarmup(t *testing.T) {
+
+	testLocal := local.NewMultiFreeCache[string, *tests.Student](nil, nil, local.WithPrefix("multicache_warmup_"))
+	testRemote := remote.NewRedisMultiAdaptor[string, *tests.Student](tests.NewRedisClient(), testLocal)
+	var loads int32
+	testDataSource := datasource.NewDataSourceMultiAdaptor[string, *tests.Student](testRemote, func(keys adaptor.Keys[string]) (adaptor.Values[string, *tests.Student], error) {
+		atomic.AddInt32(&loads, int32(len(keys)))
+		vals := make(adaptor.Values[string, *tests.Student])
+		for _, key := range keys {
+			vals[key] = &tests.Student{Name: key, Age: 18}
+		}
+		return vals, nil
+	})
+	cacheInst := NewMultiCache[string, *tests.Student]("multicache_warmup_test", testLocal, testRemote, testDataSource)
+	newStudent := func() *tests.Student {
+		return &tests.Student{}
+	}
+
+	// 分页枚举key，每页10个，共3页
+	pager := KeysFromPager(func(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+		keys := make([]string, 0, 10)
+		for i := 0; i < 10; i++ {
+			keys = append(keys, fmt.Sprint("student_", int(cursor)*10+i))
+		}
+		return keys, utils.IfExpr(cursor < 2, cursor+1, 0), nil
+	})
+	p, err := cacheInst.Warmup(context.Background(), pager, newStudent, WithWarmupCacheOnly(true))
+	if err != nil || p.Total != 30 || p.Missing != 30 || atomic.LoadInt32(&loads) != 0 {
+		t.Fatalf("cache only warmup = %+v, %v", p, err)
+	}
+	p, err = cacheInst.Warmup(context.Background(), pager, newStudent, WithWarmupBatchSize(8), WithWarmupConcurrency(2))
+	if err != nil || p.Total != 30 || p.Loaded != 30 || atomic.LoadInt32(&loads) != 30 {
+		t.Fatalf("warmup = %+v, %v", p, err)
+	}
+
+	vals := make(adaptor.Values[string, *tests.Student])
+	// 数据源加载的数据回写至分布式缓存
+	NewMultiCache[string, *tests.Student]("multicache_warmup_remote", testRemote).Get(context.Background(), adaptor.Keys[string]{"student_0", "student_29"}, vals, newStudent)
+	if len(vals) != 2 {
+		t.Errorf("remote cache not warmed, vals = %v", vals)
+	}
+	// 再次仅从缓存预热时全部命中
+	p, err = cacheInst.Warmup(context.Background(), pager, newStudent, WithWarmupCacheOnly(true))
+	if err != nil || p.Loaded != 30 || atomic.LoadInt32(&loads) != 30 {
+		t.Errorf("cache only warmup = %+v, %v", p, err)
+	}
+}
+
 func MultiLocalCacheTest() adaptor.MultiAdaptor[string, *tests.Student] {
 	return local.NewMultiFreeCache[string, *tests.Student](local.FreeCacheClient(), nil)
 }
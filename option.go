@@ -0,0 +1,246 @@
+package multicache
+
+import (
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/datasource"
+	"github.com/rumis/multicache/generation"
+	"github.com/rumis/multicache/hotkey"
+	"github.com/rumis/multicache/metrics"
+)
+
+// WritePolicy 写策略，决定Set/Del时数据源与各级缓存的写入顺序及失败语义
+type WritePolicy int
+
+const (
+	// WritePolicyNone 仅按顺序写入各级缓存，遇到错误立即返回(默认)
+	WritePolicyNone WritePolicy = iota
+	// WritePolicyThrough 同步写穿：先写数据源，成功后再写各级缓存
+	// 数据源写入失败时直接返回错误且不修改缓存；缓存写入失败时自底向上删除该key并返回错误
+	WritePolicyThrough
+	// WritePolicyBehind 异步写回：数据源写入先进入队列，入队成功后再写各级缓存，队列由后台协程批量刷新并按配置重试
+	// 同一key在队列中仅保留最后一次操作；队列已满时返回ErrWriteQueueFull且不修改缓存
+	WritePolicyBehind
+	// WritePolicyInvalidate 旁路缓存：先写数据源，成功后自底向上删除各级缓存，由后续读取重新加载
+	WritePolicyInvalidate
+)
+
+// String 写策略名称
+func (p WritePolicy) String() string {
+	switch p {
+	case WritePolicyThrough:
+		return "write_through"
+	case WritePolicyBehind:
+		return "write_behind"
+	case WritePolicyInvalidate:
+		return "invalidate"
+	}
+	return "none"
+}
+
+// CacheOption 缓存配置选项
+type CacheOption[K comparable, V adaptor.Metadata] struct {
+	Metric      metrics.Metrics
+	WritePolicy WritePolicy
+	WriteFunc   datasource.DataSourceWriteFunc[V]
+	DeleteFunc  datasource.DataSourceDeleteFunc[K]
+	WriteBehind WriteBehindOption
+	// 异步写回重试耗尽后的回调，为空时仅记录错误日志
+	OnWriteError WriteErrorFunc[K, V]
+	// 延迟双删的间隔，零值表示不启用
+	DoubleDeleteDelay time.Duration
+	// 热点key探测器，为空表示不启用
+	HotKey *hotkey.Detector
+	// 缓存代数，为空时不支持Flush
+	Generation *generation.Generation
+	// 自适应过期时间，为空表示不启用
+	AdaptiveTTL *hotkey.AdaptiveTTL
+}
+
+// WriteBehindOption 异步写回配置选项
+type WriteBehindOption struct {
+	// 单批写入数据源的最大对象数量，队列积压达到该数量时立即刷新
+	BatchSize int
+	// 队列刷新间隔
+	FlushInterval time.Duration
+	// 队列最大积压key数量
+	QueueSize int
+	// 写入失败后的最大重试次数
+	MaxRetry int
+	// 重试间隔，第n次重试等待n倍间隔
+	RetryInterval time.Duration
+	// 单次写入数据源的超时时间
+	WriteTimeout time.Duration
+}
+
+// WriteErrorFunc 异步写回重试耗尽后的回调，vals为写入失败的对象，keys为删除失败的key，二者仅有一个非空
+// 此时各级缓存中仍是未持久化的数据，调用方可在回调中删除缓存或告警
+type WriteErrorFunc[K comparable, V adaptor.Metadata] func(vals adaptor.ValueCol[V], keys adaptor.Keys[K], err error)
+
+// CacheOptionFunc 缓存配置函数
+type CacheOptionFunc[K comparable, V adaptor.Metadata] func(*CacheOption[K, V])
+
+// DefaultCacheOption 默认缓存配置
+func DefaultCacheOption[K comparable, V adaptor.Metadata]() CacheOption[K, V] {
+	return CacheOption[K, V]{
+		Metric:      metrics.DefaultMetrics(),
+		WritePolicy: WritePolicyNone,
+		WriteBehind: WriteBehindOption{
+			BatchSize:     100,
+			FlushInterval: time.Second,
+			QueueSize:     10000,
+			MaxRetry:      3,
+			RetryInterval: 100 * time.Millisecond,
+			WriteTimeout:  5 * time.Second,
+		},
+	}
+}
+
+// WithMetric 设置指标计数器
+func WithMetric[K comparable, V adaptor.Metadata](metric metrics.Metrics) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.Metric = metric
+	}
+}
+
+// WithWriteThrough 开启同步写穿，writeFn不可为空(为空时记录错误日志并回退为仅写缓存)，deleteFn为空时Del仅删除缓存
+func WithWriteThrough[K comparable, V adaptor.Metadata](writeFn datasource.DataSourceWriteFunc[V], deleteFn datasource.DataSourceDeleteFunc[K]) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.WritePolicy = WritePolicyThrough
+		option.WriteFunc = writeFn
+		option.DeleteFunc = deleteFn
+	}
+}
+
+// WithWriteBehind 开启异步写回，writeFn不可为空(为空时记录错误日志并回退为仅写缓存)，deleteFn为空时Del仅删除缓存
+func WithWriteBehind[K comparable, V adaptor.Metadata](writeFn datasource.DataSourceWriteFunc[V], deleteFn datasource.DataSourceDeleteFunc[K]) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.WritePolicy = WritePolicyBehind
+		option.WriteFunc = writeFn
+		option.DeleteFunc = deleteFn
+	}
+}
+
+// WithInvalidateOnWrite 开启旁路缓存模式，写数据源后删除缓存，writeFn不可为空(为空时记录错误日志并回退为仅写缓存)，deleteFn为空时Del仅删除缓存
+func WithInvalidateOnWrite[K comparable, V adaptor.Metadata](writeFn datasource.DataSourceWriteFunc[V], deleteFn datasource.DataSourceDeleteFunc[K]) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.WritePolicy = WritePolicyInvalidate
+		option.WriteFunc = writeFn
+		option.DeleteFunc = deleteFn
+	}
+}
+
+// WithWriteBehindOption 设置异步写回的批量、刷新间隔、队列长度及重试参数
+func WithWriteBehindOption[K comparable, V adaptor.Metadata](opt WriteBehindOption) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.WriteBehind = opt
+	}
+}
+
+// WithWriteError 设置异步写回重试耗尽后的回调
+func WithWriteError[K comparable, V adaptor.Metadata](fn WriteErrorFunc[K, V]) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.OnWriteError = fn
+	}
+}
+
+// WithDoubleDelete 开启延迟双删，Del及旁路缓存模式下的Set删除缓存后，经过delay再次删除各级缓存
+func WithDoubleDelete[K comparable, V adaptor.Metadata](delay time.Duration) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.DoubleDeleteDelay = delay
+	}
+}
+
+// WithHotKey 开启热点key探测，Get读取的key均记录至探测器
+// 本地缓存配置同一探测器后，热点key即使配置了SkipGet也从本地缓存读取
+func WithHotKey[K comparable, V adaptor.Metadata](detector *hotkey.Detector) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.HotKey = detector
+	}
+}
+
+// WithGeneration 设置缓存代数，Flush时代数加一
+// 各级缓存适配器需通过其WithGeneration配置同一实例
+func WithGeneration[K comparable, V adaptor.Metadata](g *generation.Generation) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.Generation = g
+	}
+}
+
+// WithAdaptiveTTL 开启自适应过期时间，Get读取的key记录为读取，Set写入及Del删除的key记录为更新
+// 各级缓存适配器需通过其WithTTLPolicy(a.Policy())应用，key按fmt.Sprint格式化，需与对象Key()一致
+func WithAdaptiveTTL[K comparable, V adaptor.Metadata](a *hotkey.AdaptiveTTL) CacheOptionFunc[K, V] {
+	return func(option *CacheOption[K, V]) {
+		option.AdaptiveTTL = a
+	}
+}
+
+// WarmupOption 缓存预热配置选项
+type WarmupOption struct {
+	// 并发加载的协程数量
+	Concurrency int
+	// 单批加载的key数量，MultiCache按批调用Get
+	BatchSize int
+	// 每秒最多加载的key数量，零值表示不限速
+	Rate int
+	// 仅从缓存预热，查询至数据源适配器(adaptor.Source)时停止，不访问数据源
+	CacheOnly bool
+	// 每完成一批key后回调当前进度，多个协程的回调串行执行
+	OnProgress func(p WarmupProgress)
+	// 单个key加载失败时的回调
+	OnError func(key string, err error)
+}
+
+// WarmupOptionFunc 缓存预热配置函数
+type WarmupOptionFunc func(*WarmupOption)
+
+// DefaultWarmupOption 默认缓存预热配置
+func DefaultWarmupOption() WarmupOption {
+	return WarmupOption{
+		Concurrency: 8,
+		BatchSize:   100,
+	}
+}
+
+// WithWarmupConcurrency 设置预热并发数
+func WithWarmupConcurrency(n int) WarmupOptionFunc {
+	return func(option *WarmupOption) {
+		option.Concurrency = n
+	}
+}
+
+// WithWarmupBatchSize 设置预热单批key数量
+func WithWarmupBatchSize(n int) WarmupOptionFunc {
+	return func(option *WarmupOption) {
+		option.BatchSize = n
+	}
+}
+
+// WithWarmupRate 设置每秒最多预热的key数量
+func WithWarmupRate(rate int) WarmupOptionFunc {
+	return func(option *WarmupOption) {
+		option.Rate = rate
+	}
+}
+
+// WithWarmupCacheOnly 设置仅从缓存预热，不访问数据源
+func WithWarmupCacheOnly(cacheOnly bool) WarmupOptionFunc {
+	return func(option *WarmupOption) {
+		option.CacheOnly = cacheOnly
+	}
+}
+
+// WithWarmupProgress 设置预热进度回调
+func WithWarmupProgress(fn func(p WarmupProgress)) WarmupOptionFunc {
+	return func(option *WarmupOption) {
+		option.OnProgress = fn
+	}
+}
+
+// WithWarmupError 设置预热失败回调
+func WithWarmupError(fn func(key string, err error)) WarmupOptionFunc {
+	return func(option *WarmupOption) {
+		option.OnError = fn
+	}
+}
@@ -0,0 +1,127 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/adaptor"
+)
+
+// 类型检测
+var _ adaptor.Inspector[string] = (*RedisAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Scanner = (*RedisAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Inspector[string] = (*RedisMultiAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Scanner = (*RedisMultiAdaptor[string, adaptor.Metadata])(nil)
+
+// Inspect 查询key的剩余过期时间及数据大小
+func (c *RedisAdaptor[K, V]) Inspect(ctx context.Context, key K) (adaptor.EntryInfo, error) {
+	return inspect(ctx, c.rClient, c.Name(), c.key(key))
+}
+
+// Scan 按前缀扫描当前代数的key
+func (c *RedisAdaptor[K, V]) Scan(ctx context.Context, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	return scan(ctx, c.rClient, c.key1(""), prefix, cursor, count)
+}
+
+// Inspect 查询key的剩余过期时间及数据大小
+func (c *RedisMultiAdaptor[K, V]) Inspect(ctx context.Context, key K) (adaptor.EntryInfo, error) {
+	return inspect(ctx, c.rClient, c.Name(), c.key(key))
+}
+
+// Scan 按前缀扫描当前代数的key
+func (c *RedisMultiAdaptor[K, V]) Scan(ctx context.Context, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	return scan(ctx, c.rClient, c.key1(""), prefix, cursor, count)
+}
+
+// inspect 读取key的数据及剩余过期时间
+func inspect(ctx context.Context, client redis.UniversalClient, name string, key string) (adaptor.EntryInfo, error) {
+	info := adaptor.EntryInfo{Adaptor: name, Supported: true}
+	var getCmd *redis.StringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, key)
+		ttlCmd = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return info, err
+	}
+	buf, err := getCmd.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return info, nil
+	}
+	if err != nil {
+		return info, err
+	}
+	info.Found = true
+	info.Size = int64(len(payload(buf)))
+	// 未设置过期时间时PTTL返回负数
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		info.TTL = ttl
+	}
+	return info, nil
+}
+
+// scan 扫描以keyPrefix+prefix开头的key，返回去除keyPrefix后的key
+// 集群模式下游标无法跨节点延续，依次扫描所有主节点至获取count个key，并返回游标0
+func scan(ctx context.Context, client redis.UniversalClient, keyPrefix string, prefix string, cursor uint64, count int64) ([]string, uint64, error) {
+	if count <= 0 {
+		count = 100
+	}
+	match := escapeGlob(keyPrefix+prefix) + "*"
+
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		keys, next, err := client.Scan(ctx, cursor, match, count).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		return trimPrefix(keys, keyPrefix), next, nil
+	}
+
+	var mu sync.Mutex
+	keys := make([]string, 0, count)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		iter := node.Scan(ctx, 0, match, count).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			full := int64(len(keys)) >= count
+			if !full {
+				keys = append(keys, iter.Val())
+			}
+			mu.Unlock()
+			if full {
+				return nil
+			}
+		}
+		return iter.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return trimPrefix(keys, keyPrefix), 0, nil
+}
+
+// trimPrefix 去除key的适配器前缀
+func trimPrefix(keys []string, keyPrefix string) []string {
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, keyPrefix)
+	}
+	return keys
+}
+
+// escapeGlob 转义SCAN匹配模式中的特殊字符
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
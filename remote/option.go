@@ -1,6 +1,11 @@
 package remote
 
-import "time"
+import (
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
+)
 
 type RemoteCacheOption struct {
 	Prefix       string
@@ -10,6 +15,14 @@ type RemoteCacheOption struct {
 	Name         string
 	SolutionName string
 	TTLZero      time.Duration
+	// 软过期后仍可继续提供服务的时长，零值表示不启用
+	// 数据在TTL后软过期，在TTL+StaleTTL后硬过期
+	// 仅RedisAdaptor支持，RedisMultiAdaptor忽略
+	StaleTTL time.Duration
+	// 缓存代数，拼接至key前缀之后
+	Generation *generation.Generation
+	// 过期时间策略，为空时仅使用TTL及对象的Expiring接口
+	TTLPolicy adaptor.TTLPolicy
 }
 
 // RemoteCacheOptionFunc 分布式缓存配置函数
@@ -74,3 +87,27 @@ func WithTTLZero(ttl time.Duration) RemoteCacheOptionFunc {
 		option.TTLZero = ttl
 	}
 }
+
+// WithStaleTTL 设置软过期后仍可继续提供服务的时长
+// 仅单值适配器RedisAdaptor支持软过期，批量适配器RedisMultiAdaptor忽略该选项，MultiCache不支持软过期
+func WithStaleTTL(ttl time.Duration) RemoteCacheOptionFunc {
+	return func(option *RemoteCacheOption) {
+		option.StaleTTL = ttl
+	}
+}
+
+// WithGeneration 设置缓存代数，代数拼接至key前缀之后，代数变更后旧代数的数据无法访问并随过期时间淘汰
+// 需与同一缓存场景的其他适配器及缓存场景的WithGeneration使用同一实例
+func WithGeneration(g *generation.Generation) RemoteCacheOptionFunc {
+	return func(option *RemoteCacheOption) {
+		option.Generation = g
+	}
+}
+
+// WithTTLPolicy 设置过期时间策略，策略以TTL或对象Expiring接口返回的过期时间为基础计算最终过期时间
+// 零值对象仍使用TTLZero，需与本地缓存适配器使用相同的策略以保证各层过期时间一致
+func WithTTLPolicy(policy adaptor.TTLPolicy) RemoteCacheOptionFunc {
+	return func(option *RemoteCacheOption) {
+		option.TTLPolicy = policy
+	}
+}
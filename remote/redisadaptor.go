@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/utils"
@@ -18,7 +19,7 @@ var _ adaptor.Adaptor[string, adaptor.Metadata] = (*RedisAdaptor[string, adaptor
 
 // RedisAdaptor 基于Redis的分布式缓存适配实现
 type RedisAdaptor[K comparable, V adaptor.Metadata] struct {
-	rClient      *redis.Client
+	rClient      redis.UniversalClient
 	prefix       string
 	ttl          time.Duration
 	threshold    int
@@ -26,10 +27,18 @@ type RedisAdaptor[K comparable, V adaptor.Metadata] struct {
 	name         string
 	solutionName string
 	ttlZero      time.Duration
+	// 软过期后仍可继续提供服务的时长
+	staleTTL time.Duration
+	// 对象是否实现了Versioned接口
+	versioned bool
+	// 缓存代数，拼接至key前缀之后
+	generation *generation.Generation
+	// 过期时间策略
+	ttlPolicy adaptor.TTLPolicy
 }
 
 // NewRedisAdaptor 创建一个新的RedisAdaptor对象
-func NewRedisAdaptor[K comparable, V adaptor.Metadata](client *redis.Client, preAdaptor adaptor.Adaptor[K, V], fns ...RemoteCacheOptionFunc) *RedisAdaptor[K, V] {
+func NewRedisAdaptor[K comparable, V adaptor.Metadata](client redis.UniversalClient, preAdaptor adaptor.Adaptor[K, V], fns ...RemoteCacheOptionFunc) *RedisAdaptor[K, V] {
 	// 默认+自定义配置
 	opts := DefaultRemoteCacheOption()
 	for _, fn := range fns {
@@ -44,6 +53,10 @@ func NewRedisAdaptor[K comparable, V adaptor.Metadata](client *redis.Client, pre
 		solutionName: opts.SolutionName,
 		preAdaptor:   preAdaptor,
 		ttlZero:      opts.TTLZero,
+		staleTTL:     opts.StaleTTL,
+		versioned:    adaptor.IsVersioned[V](),
+		generation:   opts.Generation,
+		ttlPolicy:    opts.TTLPolicy,
 	}
 }
 
@@ -62,7 +75,7 @@ func (c *RedisAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool, err
 		Type:        metrics.Miss,
 	}
 
-	buf, err := c.rClient.Get(ctx, c.key(key)).Bytes()
+	buf, left, err := c.get(ctx, c.key(key))
 	if errors.Is(err, redis.Nil) {
 		// key不存在
 		metric.AddMeta(ctx, missMeta)
@@ -73,12 +86,26 @@ func (c *RedisAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool, err
 		return false, err
 	}
 	// 反序列化对象
+	if c.versioned {
+		buf = payload(buf)
+	}
 	err = value.Decode(buf)
 	if err != nil {
 		metric.AddMeta(ctx, missMeta)
 		return false, err
 	}
 
+	// 数据已软过期，不回写上层缓存，由调用方后台刷新
+	if c.stale(left) && !value.Zero() {
+		metric.AddMeta(ctx, metrics.Meta{
+			AdaptorName: c.Name(),
+			Key:         fmt.Sprint(key),
+			Type:        metrics.Stale,
+			TrackTime:   time.Since(startTime).Milliseconds(),
+		})
+		return true, adaptor.ErrStale
+	}
+
 	metric.AddMeta(ctx, metrics.Meta{
 		AdaptorName: c.Name(),
 		Key:         fmt.Sprint(key),
@@ -88,7 +115,7 @@ func (c *RedisAdaptor[K, V]) Get(ctx context.Context, key K, value V) (bool, err
 
 	// 数据回写
 	if c.preAdaptor != nil {
-		err := c.preAdaptor.Set(ctx, value)
+		err := c.preAdaptor.Set(adaptor.WithRefill(ctx), value)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", value, "event", adaptor.LogEventRefill)
 		}
@@ -103,6 +130,7 @@ func (c *RedisAdaptor[K, V]) Set(ctx context.Context, value V) error {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
 	startTime := time.Now()
 	ttl := c.ttl + time.Second*time.Duration(utils.SafeRand().Intn(c.threshold))
+	ttl = adaptor.ResolveTTL(value, ttl, c.ttlPolicy) + c.staleTTL // 对象及策略TTL
 	ttl = utils.IfExpr(value.Zero(), c.ttlZero, ttl)
 
 	valBuf, err := value.Value()
@@ -110,7 +138,19 @@ func (c *RedisAdaptor[K, V]) Set(ctx context.Context, value V) error {
 		return err
 	}
 
-	err = c.rClient.SetEX(ctx, c.key1(value.Key()), utils.String(valBuf), ttl).Err()
+	// 数据及标签通过同一pipeline写入
+	err = pipelinedScripts(ctx, c.rClient, func(pipe redis.Pipeliner) error {
+		if c.versioned {
+			// 缓存中已有更新版本的数据时放弃写入
+			args := versionedSetArgs(valBuf, any(value).(adaptor.Versioned).Version(), ttl.Milliseconds())
+			versionedSetScript.EvalSha(ctx, pipe, []string{c.key1(value.Key())}, args...)
+		} else {
+			pipe.SetEX(ctx, c.key1(value.Key()), utils.String(valBuf), ttl)
+		}
+		// 对象实现Tagged接口时记录标签与key的关联，版本号较旧未写入时同样记录，不影响按标签删除
+		addTags(ctx, pipe, c.prefix+tagKeyPrefix, c.key1(value.Key()), adaptor.TagsOf(value), c.tagTTL(ttl))
+		return nil
+	})
 
 	metric.AddMeta(ctx, metrics.Meta{
 		AdaptorName: c.Name(),
@@ -128,6 +168,37 @@ func (c *RedisAdaptor[K, V]) Del(ctx context.Context, key K) error {
 	return err
 }
 
+// get 读取数据，启用软过期时同时读取剩余过期时间
+func (c *RedisAdaptor[K, V]) get(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	if c.staleTTL <= 0 {
+		buf, err := c.rClient.Get(ctx, key).Bytes()
+		return buf, 0, err
+	}
+	var getCmd *redis.StringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := c.rClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, key)
+		ttlCmd = pipe.PTTL(ctx, key)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, err
+	}
+	buf, err := getCmd.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	return buf, ttlCmd.Val(), nil
+}
+
+// stale 判断数据是否已软过期
+func (c *RedisAdaptor[K, V]) stale(left time.Duration) bool {
+	if c.staleTTL <= 0 || left <= 0 {
+		return false
+	}
+	return left < c.staleTTL
+}
+
 // key 生成缓存key
 func (c *RedisAdaptor[K, V]) key(key K) string {
 	return c.key1(fmt.Sprint(key))
@@ -135,5 +206,5 @@ func (c *RedisAdaptor[K, V]) key(key K) string {
 
 // key1 生成缓存key
 func (c *RedisAdaptor[K, V]) key1(key string) string {
-	return c.prefix + key
+	return c.prefix + c.generation.Prefix() + key
 }
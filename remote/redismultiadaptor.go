@@ -2,12 +2,12 @@ package remote
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/generation"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/metrics"
 	"github.com/rumis/multicache/utils"
@@ -18,7 +18,7 @@ var _ adaptor.MultiAdaptor[string, adaptor.Metadata] = (*RedisMultiAdaptor[strin
 
 // RedisMultiAdaptor 基于Redis的分布式多值缓存适配实现
 type RedisMultiAdaptor[K comparable, V adaptor.Metadata] struct {
-	rClient      *redis.Client
+	rClient      redis.UniversalClient
 	prefix       string
 	ttl          time.Duration
 	threshold    int
@@ -27,10 +27,16 @@ type RedisMultiAdaptor[K comparable, V adaptor.Metadata] struct {
 	name         string
 	solutionName string
 	ttlZero      time.Duration
+	// 对象是否实现了Versioned接口
+	versioned bool
+	// 缓存代数，拼接至key前缀之后
+	generation *generation.Generation
+	// 过期时间策略
+	ttlPolicy adaptor.TTLPolicy
 }
 
 // NewRedisMultiAdaptor 基于Redis的多值缓存对象
-func NewRedisMultiAdaptor[K comparable, V adaptor.Metadata](client *redis.Client, preAdaptor adaptor.MultiAdaptor[K, V], fns ...RemoteCacheOptionFunc) *RedisMultiAdaptor[K, V] {
+func NewRedisMultiAdaptor[K comparable, V adaptor.Metadata](client redis.UniversalClient, preAdaptor adaptor.MultiAdaptor[K, V], fns ...RemoteCacheOptionFunc) *RedisMultiAdaptor[K, V] {
 	// 默认+自定义配置
 	opts := DefaultRemoteCacheOption()
 	for _, fn := range fns {
@@ -46,6 +52,9 @@ func NewRedisMultiAdaptor[K comparable, V adaptor.Metadata](client *redis.Client
 		name:         opts.Name,
 		solutionName: opts.SolutionName,
 		ttlZero:      opts.TTLZero,
+		versioned:    adaptor.IsVersioned[V](),
+		generation:   opts.Generation,
+		ttlPolicy:    opts.TTLPolicy,
 	}
 }
 
@@ -55,33 +64,51 @@ func (c *RedisMultiAdaptor[K, V]) Name() string {
 }
 
 // Get 读取对象
-// 暂不支持pipeline操作(集群部署)
+// 通过MGET批量读取，集群模式下按槽位分组，每个节点一次pipeline
 func (c *RedisMultiAdaptor[K, V]) Get(ctx context.Context, keys adaptor.Keys[K], vals adaptor.Values[K, V], fn adaptor.NewValueFunc[V]) (adaptor.Keys[K], error) {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
+	startTime := time.Now()
 	hasKeys := make(adaptor.Keys[K], 0)
 	hasValues := make(adaptor.ValueCol[V], 0)
-	for _, key := range keys {
-		startTime := time.Now()
+	if len(keys) == 0 {
+		return hasKeys, nil
+	}
+
+	cacheKeys := make([]string, len(keys))
+	for i, key := range keys {
+		cacheKeys[i] = c.key(key)
+	}
+	results, err := c.mget(ctx, cacheKeys)
+	if err != nil {
+		for _, key := range keys {
+			metric.AddMeta(ctx, metrics.Meta{
+				AdaptorName: c.Name(),
+				Key:         fmt.Sprint(key),
+				Type:        metrics.Miss,
+			})
+		}
+		return hasKeys, err
+	}
+
+	for i, key := range keys {
 		missMeta := metrics.Meta{
 			AdaptorName: c.Name(),
 			Key:         fmt.Sprint(key),
 			Type:        metrics.Miss,
 		}
-
-		buf, err := c.rClient.Get(ctx, c.key(key)).Bytes()
-		if errors.Is(err, redis.Nil) {
+		buf, ok := results[i].(string)
+		if !ok {
 			// key不存在
 			metric.AddMeta(ctx, missMeta)
 			continue
 		}
-		if err != nil {
-			metric.AddMeta(ctx, missMeta)
-			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "key", key, "event", adaptor.LogEventGet)
-			continue
-		}
 		// 反序列化对象
+		valBuf := utils.Bytes(buf)
+		if c.versioned {
+			valBuf = payload(valBuf)
+		}
 		val := fn()
-		err = val.Decode(buf)
+		err = val.Decode(valBuf)
 		if err != nil {
 			metric.AddMeta(ctx, missMeta)
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "key", key, "event", adaptor.LogEventGet)
@@ -103,7 +130,7 @@ func (c *RedisMultiAdaptor[K, V]) Get(ctx context.Context, keys adaptor.Keys[K],
 
 	// 数据回写
 	if c.preAdaptor != nil && len(hasValues) > 0 {
-		err := c.preAdaptor.Set(ctx, hasValues)
+		err := c.preAdaptor.Set(adaptor.WithRefill(ctx), hasValues)
 		if err != nil {
 			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", hasValues, "event", adaptor.LogEventRefill)
 		}
@@ -113,45 +140,99 @@ func (c *RedisMultiAdaptor[K, V]) Get(ctx context.Context, keys adaptor.Keys[K],
 }
 
 // Set 写入对象
+// 通过pipeline批量写入，集群模式下由客户端按节点拆分pipeline
 func (c *RedisMultiAdaptor[K, V]) Set(ctx context.Context, vals adaptor.ValueCol[V]) error {
 	metric := ctx.Value(metrics.MetricsClient).(metrics.Metrics)
-	for _, val := range vals {
-		startTime := time.Now()
-		// 序列化对象
-		key := val.Key()
-		buf, err := val.Value()
-		if err != nil {
-			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", val, "event", adaptor.LogEventSet)
-			continue
-		}
+	startTime := time.Now()
+	if len(vals) == 0 {
+		return nil
+	}
 
-		ttl := c.ttl + time.Second*time.Duration(utils.SafeRand().Intn(c.threshold))
-		ttl = utils.IfExpr(val.Zero(), c.ttlZero, ttl)
+	var setVals adaptor.ValueCol[V]
+	var cmds []redis.Cmder
+	// 脚本未加载时加载后重新执行整个pipeline
+	pipelinedScripts(ctx, c.rClient, func(pipe redis.Pipeliner) error {
+		setVals = make(adaptor.ValueCol[V], 0, len(vals))
+		cmds = make([]redis.Cmder, 0, len(vals))
+		for _, val := range vals {
+			// 序列化对象
+			buf, err := val.Value()
+			if err != nil {
+				logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", val, "event", adaptor.LogEventSet)
+				continue
+			}
+
+			ttl := c.ttl + time.Second*time.Duration(utils.SafeRand().Intn(c.threshold))
+			ttl = adaptor.ResolveTTL(val, ttl, c.ttlPolicy)
+			ttl = utils.IfExpr(val.Zero(), c.ttlZero, ttl)
+
+			if c.versioned {
+				// 缓存中已有更新版本的数据时放弃写入
+				args := versionedSetArgs(buf, any(val).(adaptor.Versioned).Version(), ttl.Milliseconds())
+				cmds = append(cmds, versionedSetScript.EvalSha(ctx, pipe, []string{c.key1(val.Key())}, args...))
+			} else {
+				cmds = append(cmds, pipe.Set(ctx, c.key1(val.Key()), buf, ttl))
+			}
+			setVals = append(setVals, val)
+			// 对象实现Tagged接口时记录标签与key的关联，版本号较旧未写入时同样记录，不影响按标签删除
+			addTags(ctx, pipe, c.prefix+tagKeyPrefix, c.key1(val.Key()), adaptor.TagsOf(val), c.tagTTL(ttl))
+		}
+		return nil
+	})
 
-		if err := c.rClient.Set(ctx, c.key1(key), buf, ttl).Err(); err != nil {
-			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", val, "event", adaptor.LogEventSet)
+	for i, cmd := range cmds {
+		if err := cmd.Err(); err != nil {
+			logger.Error(err.Error(), "solution", c.solutionName, "adaptor", c.Name(), "value", setVals[i], "event", adaptor.LogEventSet)
 			continue
 		}
-
 		metric.AddMeta(ctx, metrics.Meta{
 			AdaptorName: c.Name(),
-			Key:         fmt.Sprint(key),
+			Key:         setVals[i].Key(),
 			Type:        metrics.Set,
 			TrackTime:   time.Since(startTime).Milliseconds(),
 		})
-
 	}
 	return nil
 }
 
 // Del 删除对象
+// 通过UNLINK批量删除，集群模式下按槽位分组
 func (c *RedisMultiAdaptor[K, V]) Del(ctx context.Context, keys adaptor.Keys[K]) error {
-	for _, key := range keys {
-		if err := c.rClient.Del(ctx, c.key(key)).Err(); err != nil {
-			return err
+	if len(keys) == 0 {
+		return nil
+	}
+	cacheKeys := make([]string, len(keys))
+	for i, key := range keys {
+		cacheKeys[i] = c.key(key)
+	}
+	_, err := unlinkKeys(ctx, c.rClient, cacheKeys)
+	return err
+}
+
+// mget 批量读取，返回值与keys一一对应，不存在的key对应nil
+func (c *RedisMultiAdaptor[K, V]) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	groups := groupKeys(c.rClient, keys)
+	if len(groups) == 1 {
+		return c.rClient.MGet(ctx, keys...).Result()
+	}
+	cmds := make([]*redis.SliceCmd, len(groups))
+	_, err := c.rClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, group := range groups {
+			cmds[i] = pipe.MGet(ctx, pickKeys(keys, group)...)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	results := make([]interface{}, len(keys))
+	for i, group := range groups {
+		groupResults := cmds[i].Val()
+		for j, idx := range group {
+			results[idx] = groupResults[j]
+		}
+	}
+	return results, nil
 }
 
 // key 生成缓存key
@@ -161,5 +242,5 @@ func (c *RedisMultiAdaptor[K, V]) key(key K) string {
 
 // key1 生成缓存key
 func (c *RedisMultiAdaptor[K, V]) key1(key string) string {
-	return c.prefix + key
+	return c.prefix + c.generation.Prefix() + key
 }
@@ -0,0 +1,90 @@
+package remote
+
+import (
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slotCount Redis集群槽位数量
+const slotCount = 16384
+
+// isCluster 判断客户端是否为集群客户端
+func isCluster(client redis.Cmdable) bool {
+	_, ok := client.(*redis.ClusterClient)
+	return ok
+}
+
+// isRing 判断客户端是否为分片客户端
+func isRing(client redis.Cmdable) bool {
+	_, ok := client.(*redis.Ring)
+	return ok
+}
+
+// groupKeys 对key进行分组，返回每组key在原集合中的下标
+// 集群模式下按槽位分组，保证同一组key位于同一节点；分片模式下无法获知key所在分片，每个key单独一组；
+// 其他模式下所有key为一组
+func groupKeys(client redis.Cmdable, keys []string) [][]int {
+	if isRing(client) {
+		groups := make([][]int, len(keys))
+		for i := range keys {
+			groups[i] = []int{i}
+		}
+		return groups
+	}
+	if !isCluster(client) {
+		group := make([]int, len(keys))
+		for i := range keys {
+			group[i] = i
+		}
+		return [][]int{group}
+	}
+	slots := make(map[int]int)
+	groups := make([][]int, 0)
+	for i, key := range keys {
+		slot := keySlot(key)
+		idx, ok := slots[slot]
+		if !ok {
+			idx = len(groups)
+			slots[slot] = idx
+			groups = append(groups, make([]int, 0))
+		}
+		groups[idx] = append(groups[idx], i)
+	}
+	return groups
+}
+
+// pickKeys 按下标选取key
+func pickKeys(keys []string, group []int) []string {
+	picked := make([]string, len(group))
+	for i, idx := range group {
+		picked[i] = keys[idx]
+	}
+	return picked
+}
+
+// keySlot 计算key所在的槽位，支持{hashtag}
+func keySlot(key string) int {
+	if s := strings.IndexByte(key, '{'); s > -1 {
+		if e := strings.IndexByte(key[s+1:], '}'); e > 0 {
+			key = key[s+1 : s+e+1]
+		}
+	}
+	return int(crc16(key) % slotCount)
+}
+
+// crc16 CRC16-XMODEM校验，与Redis集群槽位算法一致
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,179 @@
+package remote
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/adaptor"
+)
+
+// 类型检测
+var _ adaptor.Invalidator = (*RedisAdaptor[string, adaptor.Metadata])(nil)
+var _ adaptor.Invalidator = (*RedisMultiAdaptor[string, adaptor.Metadata])(nil)
+
+// tagKeyPrefix 标签集合key的前缀，标签集合key为适配器key前缀+tagKeyPrefix+标签
+const tagKeyPrefix = "__tag:"
+
+// invalidateBatch 批量删除时单批key数量
+const invalidateBatch = 500
+
+// InvalidateTag 删除带有tag标签的数据及标签集合
+func (c *RedisAdaptor[K, V]) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	return invalidateTag(ctx, c.rClient, c.prefix+tagKeyPrefix+tag)
+}
+
+// InvalidatePrefix 删除key以prefix开头的数据
+func (c *RedisAdaptor[K, V]) InvalidatePrefix(ctx context.Context, prefix string) (int, error) {
+	return invalidatePrefix(ctx, c.rClient, c.key1(prefix))
+}
+
+// tagTTL 标签集合的过期时间，不小于数据的最长过期时间，dataTTL为本次写入数据的过期时间(过期时间策略可能延长过期时间)
+func (c *RedisAdaptor[K, V]) tagTTL(dataTTL time.Duration) time.Duration {
+	ttl := c.ttl + time.Second*time.Duration(c.threshold) + c.staleTTL
+	if c.ttlZero > ttl {
+		ttl = c.ttlZero
+	}
+	if dataTTL > ttl {
+		return dataTTL
+	}
+	return ttl
+}
+
+// InvalidateTag 删除带有tag标签的数据及标签集合
+func (c *RedisMultiAdaptor[K, V]) InvalidateTag(ctx context.Context, tag string) (int, error) {
+	return invalidateTag(ctx, c.rClient, c.prefix+tagKeyPrefix+tag)
+}
+
+// InvalidatePrefix 删除key以prefix开头的数据
+func (c *RedisMultiAdaptor[K, V]) InvalidatePrefix(ctx context.Context, prefix string) (int, error) {
+	return invalidatePrefix(ctx, c.rClient, c.key1(prefix))
+}
+
+// tagTTL 标签集合的过期时间，不小于数据的最长过期时间，dataTTL为本次写入数据的过期时间(过期时间策略可能延长过期时间)
+func (c *RedisMultiAdaptor[K, V]) tagTTL(dataTTL time.Duration) time.Duration {
+	ttl := c.ttl + time.Second*time.Duration(c.threshold)
+	if c.ttlZero > ttl {
+		ttl = c.ttlZero
+	}
+	if dataTTL > ttl {
+		return dataTTL
+	}
+	return ttl
+}
+
+// addTagSrc 将key加入标签集合，集合剩余过期时间小于ttl时延长至ttl，不缩短集合的过期时间
+// KEYS[1] 标签集合key ARGV[1] 数据key ARGV[2] 过期时间(毫秒)
+const addTagSrc = `
+redis.call('SADD', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`
+
+var addTagScript = redis.NewScript(addTagSrc)
+
+// addTags 将key加入各标签集合并延长标签集合的过期时间，通过EVALSHA执行，需配合pipelinedScripts加载脚本
+// 标签集合中的key可能已过期或被删除，按标签删除时忽略；数据的标签变更后旧标签集合中仍保留该key，按旧标签删除时一并删除
+func addTags(ctx context.Context, pipe redis.Pipeliner, tagPrefix string, key string, tags []string, ttl time.Duration) {
+	for _, tag := range tags {
+		addTagScript.EvalSha(ctx, pipe, []string{tagPrefix + tag}, key, ttl.Milliseconds())
+	}
+}
+
+// invalidateTag 分批删除标签集合中的key，并从标签集合中移除已删除的key，集合为空时由Redis自动删除
+// 仅移除扫描到的key，删除期间并发写入集合的key保留在集合中，不会丢失标签关联
+func invalidateTag(ctx context.Context, client redis.UniversalClient, tagKey string) (int, error) {
+	total := 0
+	var cursor uint64
+	for {
+		keys, next, err := client.SScan(ctx, tagKey, cursor, "", invalidateBatch).Result()
+		if err != nil {
+			return total, err
+		}
+		n, err := unlinkKeys(ctx, client, keys)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			members := make([]interface{}, len(keys))
+			for i, key := range keys {
+				members[i] = key
+			}
+			if err := client.SRem(ctx, tagKey, members...).Err(); err != nil {
+				return total, err
+			}
+		}
+		if next == 0 {
+			return total, nil
+		}
+		cursor = next
+	}
+}
+
+// invalidatePrefix 扫描并分批删除以keyPrefix开头的key，集群模式下依次扫描所有主节点
+func invalidatePrefix(ctx context.Context, client redis.UniversalClient, keyPrefix string) (int, error) {
+	match := escapeGlob(keyPrefix) + "*"
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scanUnlink(ctx, client, client, match)
+	}
+	var total int64
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		n, err := scanUnlink(ctx, node, client, match)
+		atomic.AddInt64(&total, int64(n))
+		return err
+	})
+	return int(total), err
+}
+
+// scanUnlink 在scanner中扫描匹配match的key，通过client分批删除
+func scanUnlink(ctx context.Context, scanner redis.Cmdable, client redis.Cmdable, match string) (int, error) {
+	total := 0
+	iter := scanner.Scan(ctx, 0, match, invalidateBatch).Iterator()
+	keys := make([]string, 0, invalidateBatch)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) < invalidateBatch {
+			continue
+		}
+		n, err := unlinkKeys(ctx, client, keys)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		keys = keys[:0]
+	}
+	if err := iter.Err(); err != nil {
+		return total, err
+	}
+	n, err := unlinkKeys(ctx, client, keys)
+	return total + n, err
+}
+
+// unlinkKeys 通过UNLINK批量删除，集群模式下按槽位分组，返回删除条数
+func unlinkKeys(ctx context.Context, client redis.Cmdable, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	groups := groupKeys(client, keys)
+	if len(groups) == 1 {
+		n, err := client.Unlink(ctx, keys...).Result()
+		return int(n), err
+	}
+	cmds := make([]*redis.IntCmd, len(groups))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, group := range groups {
+			cmds[i] = pipe.Unlink(ctx, pickKeys(keys, group)...)
+		}
+		return nil
+	})
+	total := 0
+	for _, cmd := range cmds {
+		total += int(cmd.Val())
+	}
+	return total, err
+}
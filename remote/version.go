@@ -0,0 +1,59 @@
+package remote
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/utils"
+)
+
+// versionedSetSrc 按版本号写入数据，缓存中已有更新版本的数据时放弃写入
+// 版本号以定长十进制存储于数据头部，按字符串比较即可得到数值比较结果
+// KEYS[1] 缓存key ARGV[1] 带版本号头部的数据 ARGV[2] 版本号 ARGV[3] 过期时间(毫秒)
+const versionedSetSrc = `
+local cur = redis.call('GET', KEYS[1])
+if cur and string.sub(cur, 1, 4) == 'ver:' and string.sub(cur, 5, 24) > ARGV[2] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+return 1
+`
+
+var versionedSetScript = redis.NewScript(versionedSetSrc)
+
+// versionedSetArgs 生成版本号写入脚本的参数
+func versionedSetArgs(buf []byte, version int64, ttl int64) []interface{} {
+	return []interface{}{utils.EncodeVersion(version, buf), utils.FormatVersion(version), ttl}
+}
+
+// pipelinedScripts 通过pipeline执行fn写入的命令，脚本未加载(NOSCRIPT)时加载脚本后重新执行整个pipeline
+// fn可能被执行两次，写入的命令需可重复执行
+func pipelinedScripts(ctx context.Context, client redis.UniversalClient, fn func(pipe redis.Pipeliner) error) error {
+	cmds, err := client.Pipelined(ctx, fn)
+	noScript := false
+	for _, cmd := range cmds {
+		noScript = noScript || isNoScript(cmd.Err())
+	}
+	if !noScript {
+		return err
+	}
+	for _, script := range []*redis.Script{versionedSetScript, addTagScript} {
+		if err := script.Load(ctx, client).Err(); err != nil {
+			return err
+		}
+	}
+	_, err = client.Pipelined(ctx, fn)
+	return err
+}
+
+// payload 去除数据的版本号头部
+func payload(buf []byte) []byte {
+	_, buf, _ = utils.DecodeVersion(buf)
+	return buf
+}
+
+// isNoScript 是否为脚本未加载错误
+func isNoScript(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
@@ -0,0 +1,221 @@
+package syncer
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// ErrInvalidBatch 批量事件格式错误或已损坏
+var ErrInvalidBatch = errors.New("invalid batch event")
+
+// MaxBatchBytes 批量事件消息体(解压后)的最大字节数，超过时视为损坏，避免压缩消息解压后无限膨胀
+const MaxBatchBytes = 32 * 1024 * 1024
+
+// batchMagic 批量事件首字节，与JSON编码的首字节'{'区分
+const batchMagic byte = 0xB7
+
+// batchFlagCompressed 消息体已压缩(DEFLATE)
+const batchFlagCompressed byte = 1
+
+// batchFlagTags 每条数据末尾附带标签
+const batchFlagTags byte = 2
+
+// 批量事件格式：
+//
+//	1字节batchMagic 1字节标志位 4字节大端CRC32(IEEE，覆盖编码后的消息体) 消息体
+//	消息体 uvarint(事件类型) string(ClientID) uvarint(Seq) uvarint(条数) 若干条数据
+//	单条数据 string(Key) bytes(Val) varint(TTL，纳秒) varint(Version) [uvarint(标签数) 若干string(标签)]
+//
+// 标志位含batchFlagTags时单条数据附带标签，任一数据带有标签时设置
+// string及bytes均为uvarint长度前缀，标志位含batchFlagCompressed时消息体为DEFLATE压缩后的数据
+
+// EntrySize 单条数据编码后的大致字节数(压缩前)，用于按大小拆分批量事件
+func EntrySize(key string, val []byte) int {
+	return len(key) + len(val) + 4*binary.MaxVarintLen64
+}
+
+// encodeBatch 编码批量事件
+func encodeBatch(e *CacheSyncEvent) ([]byte, error) {
+	var flags byte
+	for _, entry := range e.Entries {
+		if len(entry.Tags) > 0 {
+			flags |= batchFlagTags
+			break
+		}
+	}
+	body := make([]byte, 0, 64)
+	body = binary.AppendUvarint(body, uint64(e.EventType))
+	body = appendBytes(body, []byte(e.ClientID))
+	body = binary.AppendUvarint(body, e.Seq)
+	body = binary.AppendUvarint(body, uint64(len(e.Entries)))
+	for _, entry := range e.Entries {
+		body = appendBytes(body, []byte(entry.Key))
+		body = appendBytes(body, entry.Val)
+		body = binary.AppendVarint(body, int64(entry.TTL))
+		body = binary.AppendVarint(body, entry.Version)
+		if flags&batchFlagTags != 0 {
+			body = binary.AppendUvarint(body, uint64(len(entry.Tags)))
+			for _, tag := range entry.Tags {
+				body = appendBytes(body, []byte(tag))
+			}
+		}
+	}
+
+	if e.Compress {
+		var compressed bytes.Buffer
+		w, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		// 压缩无收益时保留原始数据
+		if compressed.Len() < len(body) {
+			body = compressed.Bytes()
+			flags |= batchFlagCompressed
+		}
+	}
+
+	buf := make([]byte, 6, 6+len(body))
+	buf[0] = batchMagic
+	buf[1] = flags
+	binary.BigEndian.PutUint32(buf[2:6], crc32.ChecksumIEEE(body))
+	return append(buf, body...), nil
+}
+
+// decodeBatch 解码批量事件，校验失败时不修改e
+func decodeBatch(buf []byte, e *CacheSyncEvent) error {
+	if len(buf) < 6 || buf[0] != batchMagic {
+		return ErrInvalidBatch
+	}
+	flags := buf[1]
+	body := buf[6:]
+	if binary.BigEndian.Uint32(buf[2:6]) != crc32.ChecksumIEEE(body) {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidBatch)
+	}
+	if flags&batchFlagCompressed != 0 {
+		r := flate.NewReader(bytes.NewReader(body))
+		defer r.Close()
+		// 多读取一个字节用于判断是否超过上限
+		raw, err := io.ReadAll(io.LimitReader(r, MaxBatchBytes+1))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+		}
+		if len(raw) > MaxBatchBytes {
+			return fmt.Errorf("%w: decompressed body exceeds %d bytes", ErrInvalidBatch, MaxBatchBytes)
+		}
+		body = raw
+	}
+
+	d := batchDecoder{buf: body}
+	event := CacheSyncEvent{
+		EventType: EventType(d.uvarint()),
+		ClientID:  string(d.bytes()),
+		Seq:       d.uvarint(),
+	}
+	count := d.uvarint()
+	// 每条数据至少4字节，避免按损坏的条数分配内存
+	if d.err == nil && count > uint64(len(d.buf))/4 {
+		return ErrInvalidBatch
+	}
+	event.Entries = make([]SyncEntry, 0, count)
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		entry := SyncEntry{
+			Key:     string(d.bytes()),
+			Val:     d.bytes(),
+			TTL:     time.Duration(d.varint()),
+			Version: d.varint(),
+		}
+		if flags&batchFlagTags != 0 {
+			entry.Tags = d.strings()
+		}
+		event.Entries = append(event.Entries, entry)
+	}
+	if d.err != nil {
+		return d.err
+	}
+	*e = event
+	return nil
+}
+
+// appendBytes 追加长度前缀的字节数组
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// batchDecoder 顺序读取消息体，出错后后续读取均返回零值
+type batchDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *batchDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = ErrInvalidBatch
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *batchDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = ErrInvalidBatch
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *batchDecoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = ErrInvalidBatch
+		return nil
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *batchDecoder) strings() []string {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	// 每个标签至少1字节，避免按损坏的标签数分配内存
+	if n > uint64(len(d.buf)) {
+		d.err = ErrInvalidBatch
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+	strs := make([]string, 0, n)
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		strs = append(strs, string(d.bytes()))
+	}
+	return strs
+}
@@ -0,0 +1,73 @@
+package syncer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBatchEvent(t *testing.T) {
+
+	entries := make([]SyncEntry, 0, 100)
+	for i := 0; i < 100; i++ {
+		entries = append(entries, SyncEntry{
+			Key:     fmt.Sprint("student_", i),
+			Val:     bytes.Repeat([]byte("张三"), 10),
+			TTL:     30 * time.Second,
+			Version: int64(i),
+		})
+	}
+	for _, compress := range []bool{false, true} {
+		e := &CacheSyncEvent{ClientID: "client", EventType: EventTypeBatchAdd, Seq: 7, Entries: entries, Compress: compress}
+		buf := e.Encode()
+		var decoded CacheSyncEvent
+		if err := decoded.Decode([]byte(buf)); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.ClientID != "client" || decoded.Seq != 7 || decoded.EventType != EventTypeBatchAdd || len(decoded.Entries) != 100 {
+			t.Fatalf("decoded = %+v", decoded)
+		}
+		if last := decoded.Entries[99]; last.Key != "student_99" || last.Version != 99 || last.TTL != 30*time.Second || !bytes.Equal(last.Val, entries[99].Val) {
+			t.Errorf("entry = %+v", last)
+		}
+		t.Logf("compress = %v, size = %d", compress, len(buf))
+
+		// 损坏的批量事件整体丢弃
+		corrupted := []byte(buf)
+		corrupted[len(corrupted)-1] ^= 0xff
+		decoded = CacheSyncEvent{}
+		if err := decoded.Decode(corrupted); !errors.Is(err, ErrInvalidBatch) || decoded.Entries != nil {
+			t.Errorf("err = %v, entries = %d", err, len(decoded.Entries))
+		}
+	}
+
+	// 带标签的批量事件
+	tagged := &CacheSyncEvent{EventType: EventTypeBatchAdd, Entries: []SyncEntry{{Key: "张三", Tags: []string{"age:18", "class:1"}}, {Key: "李四"}}}
+	var decoded CacheSyncEvent
+	if err := decoded.Decode([]byte(tagged.Encode())); err != nil {
+		t.Fatal(err)
+	}
+	if tags := decoded.Entries[0].Tags; len(tags) != 2 || tags[1] != "class:1" || decoded.Entries[1].Tags != nil {
+		t.Errorf("entries = %+v", decoded.Entries)
+	}
+
+	// 解压后超过上限的批量事件丢弃
+	bomb := &CacheSyncEvent{EventType: EventTypeBatchAdd, Compress: true, Entries: []SyncEntry{
+		{Key: "张三", Val: make([]byte, MaxBatchBytes/2)}, {Key: "李四", Val: make([]byte, MaxBatchBytes/2)},
+	}}
+	buf := bomb.Encode()
+	if len(buf) > MaxBatchBytes/100 {
+		t.Fatalf("compressed size = %d", len(buf))
+	}
+	if err := decoded.Decode([]byte(buf)); !errors.Is(err, ErrInvalidBatch) {
+		t.Errorf("err = %v, want ErrInvalidBatch", err)
+	}
+
+	// 单条事件仍采用JSON编码
+	var single CacheSyncEvent
+	if err := single.Decode([]byte((&CacheSyncEvent{EventType: EventTypeDelete, Key: "张三"}).Encode())); err != nil || single.Key != "张三" {
+		t.Errorf("single = %+v, %v", single, err)
+	}
+}
@@ -10,6 +10,22 @@ type EventType int
 const (
 	EventTypeAdd EventType = iota + 1
 	EventTypeDelete
+	// EventTypeFlush 清空本地缓存中以Key开头的数据，Key为空时清空接收方前缀下的全部数据
+	// 订阅断开重连或检测到事件序号缺失时由同步器生成，避免继续使用已错过更新的数据
+	EventTypeFlush
+	// EventTypeBatchAdd 批量写入，数据位于Entries，采用二进制编码
+	EventTypeBatchAdd
+	// EventTypeBatchDelete 批量删除，key位于Entries，采用二进制编码
+	EventTypeBatchDelete
+	// EventTypeInvalidate 失效通知，仅携带Key及可选的Version，接收方删除本地数据，下次读取时从下层缓存加载
+	// Version大于零时接收方保留版本号不小于Version的本地数据
+	EventTypeInvalidate
+	// EventTypeBatchInvalidate 批量失效通知，key及版本号位于Entries，采用二进制编码
+	EventTypeBatchInvalidate
+	// EventTypeInvalidateTag 删除带有Tag标签的本地数据，Key为发送方适配器的key前缀，接收方仅处理前缀相同的事件
+	EventTypeInvalidateTag
+	// EventTypeGeneration 缓存代数变更，Key为代数在Redis中的key，Version为新的代数
+	EventTypeGeneration
 )
 
 // CacheSyncEvent 数据同步事件
@@ -19,10 +35,43 @@ type CacheSyncEvent struct {
 	Key       string        `json:"key"`
 	Val       []byte        `json:"val"`
 	TTL       time.Duration `json:"ttl"`
+	// 数据版本号，仅对象实现Versioned接口时有效
+	Version int64 `json:"version,omitempty"`
+	// 发送端递增的事件序号，接收端据此检测丢失的事件
+	Seq uint64 `json:"seq,omitempty"`
+	// 数据的标签，对象实现Tagged接口时有效
+	Tags []string `json:"tags,omitempty"`
+	// 按标签失效事件中的标签
+	Tag string `json:"tag,omitempty"`
+	// 批量事件中的数据
+	Entries []SyncEntry `json:"entries,omitempty"`
+	// 批量事件编码时是否压缩，不参与编码
+	Compress bool `json:"-"`
 }
 
-// Encode 对象序列化
+// SyncEntry 批量同步事件中的单条数据，批量删除事件仅使用Key，批量失效事件仅使用Key及Version
+type SyncEntry struct {
+	Key     string
+	Val     []byte
+	TTL     time.Duration
+	Version int64
+	Tags    []string
+}
+
+// Batch 是否为批量事件
+func (e *CacheSyncEvent) Batch() bool {
+	return e.EventType == EventTypeBatchAdd || e.EventType == EventTypeBatchDelete || e.EventType == EventTypeBatchInvalidate
+}
+
+// Encode 对象序列化，批量事件采用二进制编码，其余事件采用JSON编码
 func (e *CacheSyncEvent) Encode() string {
+	if e.Batch() {
+		buf, err := encodeBatch(e)
+		if err != nil {
+			return ""
+		}
+		return string(buf)
+	}
 	buf, err := json.Marshal(e)
 	if err != nil {
 		return ""
@@ -30,7 +79,10 @@ func (e *CacheSyncEvent) Encode() string {
 	return string(buf)
 }
 
-// Decode 对象反序列化
+// Decode 对象反序列化，按首字节识别二进制编码的批量事件
 func (e *CacheSyncEvent) Decode(buf []byte) error {
+	if len(buf) > 0 && buf[0] == batchMagic {
+		return decodeBatch(buf, e)
+	}
 	return json.Unmarshal(buf, e)
 }
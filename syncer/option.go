@@ -0,0 +1,115 @@
+package syncer
+
+import "time"
+
+// RedisSyncerOption Redis数据同步器配置
+type RedisSyncerOption struct {
+	// 订阅断开后重新订阅的最小及最大退避时间，按指数退避
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// 订阅连接空闲超过该时间时发送PING探测，探测无响应视为断开
+	HealthCheckInterval time.Duration
+	// 同一发送端的并发广播可能乱序到达，缺失的事件超过该时间仍未到达时才视为丢失，零值表示发现缺失立即视为丢失
+	ReorderWindow time.Duration
+}
+
+// RedisSyncerOptionFunc 配置函数
+type RedisSyncerOptionFunc func(opts *RedisSyncerOption)
+
+// DefaultRedisSyncerOption 默认配置
+func DefaultRedisSyncerOption() RedisSyncerOption {
+	return RedisSyncerOption{
+		MinBackoff:          100 * time.Millisecond,
+		MaxBackoff:          5 * time.Second,
+		HealthCheckInterval: 3 * time.Second,
+		ReorderWindow:       time.Second,
+	}
+}
+
+// WithBackoff 设置重新订阅的最小及最大退避时间
+func WithBackoff(min time.Duration, max time.Duration) RedisSyncerOptionFunc {
+	return func(opts *RedisSyncerOption) {
+		opts.MinBackoff = min
+		opts.MaxBackoff = max
+	}
+}
+
+// WithHealthCheckInterval 设置订阅连接的探测间隔
+func WithHealthCheckInterval(interval time.Duration) RedisSyncerOptionFunc {
+	return func(opts *RedisSyncerOption) {
+		opts.HealthCheckInterval = interval
+	}
+}
+
+// WithReorderWindow 设置乱序事件的等待时间
+func WithReorderWindow(window time.Duration) RedisSyncerOptionFunc {
+	return func(opts *RedisSyncerOption) {
+		opts.ReorderWindow = window
+	}
+}
+
+// RedisStreamSyncerOption 基于Redis Streams的数据同步器配置
+type RedisStreamSyncerOption struct {
+	// Stream保留的事件数量，按近似值裁剪，实例断开时间内的事件超过该数量时重连后清空本地缓存
+	MaxLen int64
+	// 单次XREAD阻塞等待时间，同时决定Close等待消费协程退出的最长时间
+	Block time.Duration
+	// 单次XREAD读取的最大事件数
+	Count int64
+	// 读取失败后重试的最小及最大退避时间，按指数退避
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// 缺失的事件超过该时间仍未到达时才视为丢失，零值表示发现缺失立即视为丢失
+	ReorderWindow time.Duration
+}
+
+// RedisStreamSyncerOptionFunc 配置函数
+type RedisStreamSyncerOptionFunc func(opts *RedisStreamSyncerOption)
+
+// DefaultRedisStreamSyncerOption 默认配置
+func DefaultRedisStreamSyncerOption() RedisStreamSyncerOption {
+	return RedisStreamSyncerOption{
+		MaxLen:        10000,
+		Block:         time.Second,
+		Count:         100,
+		MinBackoff:    100 * time.Millisecond,
+		MaxBackoff:    5 * time.Second,
+		ReorderWindow: time.Second,
+	}
+}
+
+// WithStreamMaxLen 设置Stream保留的事件数量
+func WithStreamMaxLen(maxLen int64) RedisStreamSyncerOptionFunc {
+	return func(opts *RedisStreamSyncerOption) {
+		opts.MaxLen = maxLen
+	}
+}
+
+// WithStreamBlock 设置单次XREAD阻塞等待时间
+func WithStreamBlock(block time.Duration) RedisStreamSyncerOptionFunc {
+	return func(opts *RedisStreamSyncerOption) {
+		opts.Block = block
+	}
+}
+
+// WithStreamCount 设置单次XREAD读取的最大事件数
+func WithStreamCount(count int64) RedisStreamSyncerOptionFunc {
+	return func(opts *RedisStreamSyncerOption) {
+		opts.Count = count
+	}
+}
+
+// WithStreamBackoff 设置读取失败后重试的最小及最大退避时间
+func WithStreamBackoff(min time.Duration, max time.Duration) RedisStreamSyncerOptionFunc {
+	return func(opts *RedisStreamSyncerOption) {
+		opts.MinBackoff = min
+		opts.MaxBackoff = max
+	}
+}
+
+// WithStreamReorderWindow 设置乱序事件的等待时间
+func WithStreamReorderWindow(window time.Duration) RedisStreamSyncerOptionFunc {
+	return func(opts *RedisStreamSyncerOption) {
+		opts.ReorderWindow = window
+	}
+}
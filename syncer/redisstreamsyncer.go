@@ -0,0 +1,228 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/utils"
+)
+
+// 类型检测
+var _ Syncer = (*RedisStreamSyncer)(nil)
+
+// streamEventField 事件在Stream消息中的字段名
+const streamEventField = "event"
+
+// RedisStreamSyncer 基于Redis Streams的数据同步器
+// 广播时XADD并按MaxLen近似裁剪，订阅方从订阅时Stream的最后一条事件开始XREAD，断开重连后从最后读取的位置继续读取，补齐断开期间的事件
+// 断开期间的事件已被裁剪或事件序号缺失时向处理函数投递EventTypeFlush事件
+type RedisStreamSyncer struct {
+	clientId string
+	stream   string
+	opts     RedisStreamSyncerOption
+	// Client对象
+	innerClient redis.UniversalClient
+	// 已广播的事件序号
+	seq uint64
+	// 订阅消费协程
+	subs subscriptions
+}
+
+// NewRedisStreamSyncer 基于Redis Streams的数据同步器
+func NewRedisStreamSyncer(iclient redis.UniversalClient, stream string, fns ...RedisStreamSyncerOptionFunc) *RedisStreamSyncer {
+	opts := DefaultRedisStreamSyncerOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	// XREAD的BLOCK为0表示永久阻塞，无法响应关闭
+	opts.Block = utils.IfExpr(opts.Block > 0, opts.Block, DefaultRedisStreamSyncerOption().Block)
+	return &RedisStreamSyncer{
+		innerClient: iclient,
+		stream:      stream,
+		opts:        opts,
+		clientId:    utils.UUID(),
+	}
+}
+
+// ClientID 获取端ID
+func (r *RedisStreamSyncer) ClientID() string {
+	return r.clientId
+}
+
+// Emit 广播数据，事件附带递增序号
+func (r *RedisStreamSyncer) Emit(ctx context.Context, e *CacheSyncEvent) error {
+	if r.innerClient == nil {
+		return ErrNilClient
+	}
+	if r.subs.isClosed() {
+		return ErrSyncerClosed
+	}
+	e.ClientID = r.clientId
+	e.Seq = atomic.AddUint64(&r.seq, 1)
+	return r.innerClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: r.stream,
+		MaxLen: r.opts.MaxLen,
+		Approx: true,
+		Values: []string{streamEventField, e.Encode()},
+	}).Err()
+}
+
+// Subscribe 订阅数据，从Stream当前的最后一条事件之后开始读取，ctx取消或同步器关闭后退出消费协程
+// 读取Stream的最后一条事件失败时返回错误
+func (r *RedisStreamSyncer) Subscribe(ctx context.Context, fn EventHandler) error {
+	if r.innerClient == nil {
+		return ErrNilClient
+	}
+	lastId, err := r.lastId(ctx)
+	if err != nil {
+		return err
+	}
+	return r.subs.start(ctx, func(ctx context.Context) {
+		r.receive(ctx, fn, lastId)
+	})
+}
+
+// Close 停止全部订阅并等待消费协程退出，最长等待Block
+func (r *RedisStreamSyncer) Close(ctx context.Context) error {
+	return r.subs.close(ctx)
+}
+
+// receive 从lastId之后循环读取事件，读取失败后按指数退避重试，直至ctx取消
+func (r *RedisStreamSyncer) receive(ctx context.Context, fn EventHandler, lastId string) {
+	d := newDispatcher(r.stream, r.clientId, r.opts.ReorderWindow, fn)
+	// 读取是否曾失败，恢复后需检查断开期间的事件是否已被裁剪
+	lost := false
+	attempt := 0
+	for {
+		err := r.checkTrimmed(ctx, d, lastId, &lost)
+		if err == nil {
+			var streams []redis.XStream
+			streams, err = r.innerClient.XRead(ctx, &redis.XReadArgs{
+				Streams: []string{r.stream, lastId},
+				Count:   r.opts.Count,
+				Block:   r.opts.Block,
+			}).Result()
+			if errors.Is(err, redis.Nil) {
+				err = nil
+			}
+			for _, stream := range streams {
+				for _, msg := range stream.Messages {
+					lastId = msg.ID
+					payload, _ := msg.Values[streamEventField].(string)
+					d.dispatch(payload)
+				}
+			}
+			d.checkGaps(time.Now())
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			attempt = 0
+			continue
+		}
+		lost = true
+		delay := backoff(r.opts.MinBackoff, r.opts.MaxBackoff, attempt)
+		attempt++
+		logger.Error(err.Error(), "channel", r.stream, "retry", attempt, "backoff", delay, "event", adaptor.LogEventReconnect)
+		if !sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+// checkTrimmed 读取失败后恢复时检查lastId之后的事件是否已被裁剪，已被裁剪时投递EventTypeFlush事件
+func (r *RedisStreamSyncer) checkTrimmed(ctx context.Context, d *dispatcher, lastId string, lost *bool) error {
+	if !*lost {
+		return nil
+	}
+	msgs, err := r.innerClient.XRangeN(ctx, r.stream, "-", "+", 1).Result()
+	if err != nil {
+		return err
+	}
+	*lost = false
+	var first string
+	if len(msgs) > 0 {
+		first = msgs[0].ID
+	}
+	// lastId仍在Stream中时无需查询
+	var maxDeleted string
+	if first == "" || compareStreamId(first, lastId) > 0 {
+		maxDeleted = r.maxDeletedId(ctx)
+	}
+	if streamTrimmed(lastId, first, maxDeleted) {
+		logger.Warn("sync events trimmed", "channel", r.stream, "last", lastId, "first", first, "event", adaptor.LogEventSyncGap)
+		d.reset()
+		d.flush("")
+	}
+	return nil
+}
+
+// maxDeletedId 通过XINFO STREAM查询已删除(含裁剪)事件的最大ID，Redis 7以下版本或查询失败时返回空字符串
+func (r *RedisStreamSyncer) maxDeletedId(ctx context.Context) string {
+	reply, err := r.innerClient.Do(ctx, "XINFO", "STREAM", r.stream).Result()
+	if err != nil {
+		return ""
+	}
+	fields, _ := reply.([]interface{})
+	for i := 0; i+1 < len(fields); i += 2 {
+		if name, _ := fields[i].(string); name == "max-deleted-entry-id" {
+			id, _ := fields[i+1].(string)
+			return id
+		}
+	}
+	return ""
+}
+
+// streamTrimmed 判断lastId之后的事件是否已被裁剪
+// first为Stream中最早的事件ID，Stream为空时为空字符串；maxDeleted为已删除事件的最大ID，未知时为空字符串
+// lastId本身被裁剪而其后的事件仍保留时不视为裁剪；maxDeleted未知时无法区分，按已裁剪处理
+func streamTrimmed(lastId string, first string, maxDeleted string) bool {
+	if first != "" && compareStreamId(first, lastId) <= 0 {
+		return false
+	}
+	if maxDeleted != "" {
+		return compareStreamId(maxDeleted, lastId) > 0
+	}
+	return first != "" || lastId != "0-0"
+}
+
+// lastId Stream中最后一条事件的ID，Stream不存在时返回0-0
+func (r *RedisStreamSyncer) lastId(ctx context.Context) (string, error) {
+	msgs, err := r.innerClient.XRevRangeN(ctx, r.stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "0-0", nil
+	}
+	return msgs[0].ID, nil
+}
+
+// compareStreamId 比较两个Stream ID(毫秒时间戳-序号)
+func compareStreamId(a string, b string) int {
+	aMs, aSeq := parseStreamId(a)
+	bMs, bSeq := parseStreamId(b)
+	switch {
+	case aMs != bMs:
+		return utils.IfExpr(aMs < bMs, -1, 1)
+	case aSeq != bSeq:
+		return utils.IfExpr(aSeq < bSeq, -1, 1)
+	}
+	return 0
+}
+
+// parseStreamId 解析Stream ID
+func parseStreamId(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	msVal, _ := strconv.ParseUint(ms, 10, 64)
+	seqVal, _ := strconv.ParseUint(seq, 10, 64)
+	return msVal, seqVal
+}
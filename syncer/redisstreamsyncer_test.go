@@ -0,0 +1,187 @@
+package syncer
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedisStreamSyncer(t *testing.T) {
+
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	// 订阅方经代理连接Redis，模拟网络分区
+	proxy := newPartitionProxy(t, server.Addr())
+	proxyClient := redis.NewClient(&redis.Options{Addr: proxy.addr(), MaxRetries: -1})
+	defer proxyClient.Close()
+
+	s1 := NewRedisStreamSyncer(proxyClient, "stream_test", WithStreamBlock(50*time.Millisecond), WithStreamBackoff(20*time.Millisecond, 50*time.Millisecond))
+	s2 := NewRedisStreamSyncer(redisClient, "stream_test", WithStreamMaxLen(4))
+	defer s1.Close(context.Background())
+
+	// 订阅前的事件不会被读取
+	s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "赵六"})
+	received := make(chan *CacheSyncEvent, 10)
+	if err := s1.Subscribe(context.TODO(), func(e *CacheSyncEvent) {
+		received <- e
+	}); err != nil {
+		t.Fatal(err)
+	}
+	expect := func(eventType EventType, key string) {
+		t.Helper()
+		select {
+		case e := <-received:
+			if e.EventType != eventType || e.Key != key {
+				t.Errorf("event = %s, want type %d key %s", e.Encode(), eventType, key)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event type %d key %s not received", eventType, key)
+		}
+	}
+
+	// 忽略自身广播的事件
+	s1.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "自身"})
+	s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "张三"})
+	expect(EventTypeDelete, "张三")
+
+	// 分区期间的事件在恢复后补齐
+	proxy.partition()
+	s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "李四"})
+	time.Sleep(200 * time.Millisecond)
+	proxy.heal()
+	expect(EventTypeDelete, "李四")
+
+	// 分区期间的事件超过Stream保留数量时先投递清空事件
+	proxy.partition()
+	for _, key := range []string{"王五", "孙七", "周八", "吴九", "郑十"} {
+		s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: key})
+	}
+	time.Sleep(200 * time.Millisecond)
+	proxy.heal()
+	expect(EventTypeFlush, "")
+	for _, key := range []string{"孙七", "周八", "吴九", "郑十"} {
+		expect(EventTypeDelete, key)
+	}
+
+	// 关闭后不可再订阅
+	s1.Close(context.Background())
+	if err := s1.Subscribe(context.TODO(), func(e *CacheSyncEvent) {}); err != ErrSyncerClosed {
+		t.Errorf("err = %v, want ErrSyncerClosed", err)
+	}
+}
+
+func TestStreamTrimmed(t *testing.T) {
+
+	cases := []struct {
+		lastId, first, maxDeleted string
+		want                      bool
+	}{
+		// lastId仍在Stream中
+		{"5-0", "3-0", "", false},
+		{"5-0", "5-0", "", false},
+		// 仅lastId及之前的事件被裁剪
+		{"5-0", "6-0", "5-0", false},
+		{"5-0", "", "5-0", false},
+		// lastId之后的事件被裁剪
+		{"5-0", "8-0", "7-0", true},
+		{"5-0", "", "7-0", true},
+		// 不支持max-deleted-entry-id时按已裁剪处理
+		{"5-0", "6-0", "", true},
+		{"5-0", "", "", true},
+		// 订阅时Stream为空
+		{"0-0", "", "", false},
+		{"0-0", "", "0-0", false},
+		{"0-0", "1-0", "0-0", false},
+	}
+	for _, c := range cases {
+		if got := streamTrimmed(c.lastId, c.first, c.maxDeleted); got != c.want {
+			t.Errorf("streamTrimmed(%q, %q, %q) = %v, want %v", c.lastId, c.first, c.maxDeleted, got, c.want)
+		}
+	}
+}
+
+// partitionProxy 可模拟网络分区的TCP代理
+type partitionProxy struct {
+	ln     net.Listener
+	target string
+	mu     sync.Mutex
+	down   bool
+	conns  []net.Conn
+}
+
+// newPartitionProxy 创建转发至target的TCP代理
+func newPartitionProxy(t *testing.T, target string) *partitionProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &partitionProxy{ln: ln, target: target}
+	t.Cleanup(func() {
+		ln.Close()
+		p.partition()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			p.serve(conn)
+		}
+	}()
+	return p
+}
+
+// addr 代理地址
+func (p *partitionProxy) addr() string {
+	return p.ln.Addr().String()
+}
+
+// serve 分区期间直接关闭连接，否则双向转发
+func (p *partitionProxy) serve(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		conn.Close()
+		return
+	}
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	p.conns = append(p.conns, conn, upstream)
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		conn.Close()
+	}()
+}
+
+// partition 断开全部连接并拒绝新连接
+func (p *partitionProxy) partition() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = true
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+// heal 恢复连接
+func (p *partitionProxy) heal() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.down = false
+}
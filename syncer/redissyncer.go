@@ -2,10 +2,13 @@ package syncer
 
 import (
 	"context"
-	"fmt"
-	"runtime"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/rumis/multicache/adaptor"
 	"github.com/rumis/multicache/logger"
 	"github.com/rumis/multicache/utils"
 )
@@ -13,19 +16,33 @@ import (
 // 类型检测
 var _ Syncer = (*RedisSyncer)(nil)
 
+// errHealthCheck 订阅连接探测无响应
+var errHealthCheck = errors.New("pubsub health check timeout")
+
 // RedisSyncer 基于Redis发布/订阅模式的数据同步器
+// 订阅断开后按指数退避重新订阅，重新订阅成功及事件序号缺失超过ReorderWindow时向处理函数投递EventTypeFlush事件
 type RedisSyncer struct {
 	clientId string
 	channel  string
+	opts     RedisSyncerOption
 	// Client对象
-	innerClient *redis.Client
+	innerClient redis.UniversalClient
+	// 已广播的事件序号
+	seq uint64
+	// 订阅消费协程
+	subs subscriptions
 }
 
 // NewRedisSyncer 基于Redis发布/订阅模式的数据同步器
-func NewRedisSyncer(iclient *redis.Client, channel string) *RedisSyncer {
+func NewRedisSyncer(iclient redis.UniversalClient, channel string, fns ...RedisSyncerOptionFunc) *RedisSyncer {
+	opts := DefaultRedisSyncerOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
 	return &RedisSyncer{
 		innerClient: iclient,
 		channel:     channel,
+		opts:        opts,
 		clientId:    utils.UUID(),
 	}
 }
@@ -35,12 +52,16 @@ func (r *RedisSyncer) ClientID() string {
 	return r.clientId
 }
 
-// Emit 广播数据
+// Emit 广播数据，事件附带递增序号，广播失败的序号同样被消耗，接收端据此感知事件丢失
 func (r *RedisSyncer) Emit(ctx context.Context, e *CacheSyncEvent) error {
 	if r.innerClient == nil {
 		return ErrNilClient
 	}
+	if r.subs.isClosed() {
+		return ErrSyncerClosed
+	}
 	e.ClientID = r.clientId
+	e.Seq = atomic.AddUint64(&r.seq, 1)
 	err := r.innerClient.Publish(ctx, r.channel, e.Encode()).Err()
 	if err != nil {
 		return err
@@ -48,33 +69,92 @@ func (r *RedisSyncer) Emit(ctx context.Context, e *CacheSyncEvent) error {
 	return nil
 }
 
-// Read 读取数据
+// Subscribe 订阅数据，ctx取消或同步器关闭后退订并退出消费协程
 func (r *RedisSyncer) Subscribe(ctx context.Context, fn EventHandler) error {
 	if r.innerClient == nil {
 		return ErrNilClient
 	}
-	ch := r.innerClient.Subscribe(ctx, r.channel).Channel()
+	return r.subs.start(ctx, func(ctx context.Context) {
+		r.receive(ctx, fn)
+	})
+}
+
+// Close 退订全部订阅并等待消费协程退出
+func (r *RedisSyncer) Close(ctx context.Context) error {
+	return r.subs.close(ctx)
+}
+
+// receive 订阅并消费消息，订阅断开后按指数退避重新订阅，直至ctx取消
+func (r *RedisSyncer) receive(ctx context.Context, fn EventHandler) {
+	d := newDispatcher(r.channel, r.clientId, r.opts.ReorderWindow, fn)
+	// 订阅是否曾断开，重新订阅成功后需清空本地缓存
+	lost := false
+	attempt := 0
+	for {
+		err := r.consume(ctx, d, func() {
+			attempt = 0
+			if lost {
+				lost = false
+				// 断开期间的事件已丢失，序号重新计算
+				d.reset()
+				d.flush("")
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		lost = true
+		delay := backoff(r.opts.MinBackoff, r.opts.MaxBackoff, attempt)
+		attempt++
+		logger.Error(err.Error(), "channel", r.channel, "retry", attempt, "backoff", delay, "event", adaptor.LogEventReconnect)
+		if !sleep(ctx, delay) {
+			return
+		}
+	}
+}
+
+// consume 建立订阅并消费消息直至连接断开或ctx取消，订阅确认后调用subscribed
+func (r *RedisSyncer) consume(ctx context.Context, d *dispatcher, subscribed func()) error {
+	pubsub := r.innerClient.Subscribe(ctx, r.channel)
+	// ctx取消时关闭订阅连接，中断阻塞的读取
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				buf := make([]byte, 1<<16)
-				runtime.Stack(buf, false)
-				logger.Error(fmt.Sprint(err), "channel", r.channel, "stack", string(buf))
-				r.Subscribe(ctx, fn)
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		pubsub.Close()
+	}()
+
+	pinging := false
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, r.opts.HealthCheckInterval)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) || !netErr.Timeout() {
+				return err
 			}
-		}()
-		for {
-			m := <-ch
-			msg := &CacheSyncEvent{}
-			err := msg.Decode([]byte(m.Payload))
-			if err != nil {
-				logger.Error(fmt.Sprint(err), "channel", r.channel)
+			// 空闲期间检查乱序等待超时的缺失事件
+			d.checkGaps(time.Now())
+			// 空闲超时发送PING探测，上一次探测仍无响应视为断开
+			if pinging {
+				return errHealthCheck
 			}
-			if msg.ClientID == r.clientId {
-				continue
+			if err := pubsub.Ping(ctx); err != nil {
+				return err
 			}
-			fn(msg)
+			pinging = true
+			continue
 		}
-	}()
-	return nil
+		pinging = false
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			if m.Kind == "subscribe" {
+				subscribed()
+			}
+		case *redis.Message:
+			d.dispatch(m.Payload)
+		}
+	}
 }
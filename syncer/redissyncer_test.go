@@ -6,9 +6,45 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/rumis/multicache/tests"
 )
 
+func TestRedisSyncerCluster(t *testing.T) {
+
+	redisClient := tests.NewRedisClusterClient()
+
+	s1 := NewRedisSyncer(redisClient, "channel_cluster_test")
+	s2 := NewRedisSyncer(redisClient, "channel_cluster_test")
+
+	received := make(chan *CacheSyncEvent, 1)
+	err := s1.Subscribe(context.TODO(), func(e *CacheSyncEvent) {
+		received <- e
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+
+	err = s2.Emit(context.TODO(), &CacheSyncEvent{
+		EventType: EventTypeDelete,
+		Key:       "张三",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-received:
+		if e.Key != "张三" || e.EventType != EventTypeDelete {
+			t.Error("unexpected event", e.Encode())
+		}
+	case <-time.After(time.Second):
+		t.Error("event not received")
+	}
+}
+
 func TestRedisSyncer(t *testing.T) {
 
 	redisClient := tests.NewRedisClient()
@@ -41,3 +77,95 @@ func TestRedisSyncer(t *testing.T) {
 	time.Sleep(time.Millisecond * 1000)
 
 }
+
+func TestRedisSyncerReconnect(t *testing.T) {
+
+	server := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+
+	s1 := NewRedisSyncer(redisClient, "channel_reconnect_test", WithBackoff(10*time.Millisecond, 50*time.Millisecond), WithHealthCheckInterval(100*time.Millisecond),
+		WithReorderWindow(50*time.Millisecond))
+	s2 := NewRedisSyncer(redisClient, "channel_reconnect_test")
+	defer s1.Close(context.Background())
+
+	received := make(chan *CacheSyncEvent, 10)
+	s1.Subscribe(context.TODO(), func(e *CacheSyncEvent) {
+		received <- e
+	})
+	expect := func(eventType EventType, key string) {
+		t.Helper()
+		select {
+		case e := <-received:
+			if e.EventType != eventType || e.Key != key {
+				t.Errorf("event = %s, want type %d key %s", e.Encode(), eventType, key)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("event type %d key %s not received", eventType, key)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "张三"})
+	expect(EventTypeDelete, "张三")
+
+	// 事件序号缺失超过乱序等待时间后投递清空事件
+	s2.seq += 3
+	s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "李四"})
+	expect(EventTypeDelete, "李四")
+	expect(EventTypeFlush, "")
+
+	// 断开后重新订阅，重新订阅成功后投递清空事件
+	server.Close()
+	time.Sleep(200 * time.Millisecond)
+	if err := server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventTypeFlush, "")
+	s2.Emit(context.TODO(), &CacheSyncEvent{EventType: EventTypeDelete, Key: "王五"})
+	expect(EventTypeDelete, "王五")
+}
+
+func TestDispatcherReorder(t *testing.T) {
+
+	var flushes int
+	var keys []string
+	d := newDispatcher("dispatcher_test", "self", 50*time.Millisecond, func(e *CacheSyncEvent) {
+		if e.EventType == EventTypeFlush {
+			flushes++
+			return
+		}
+		keys = append(keys, e.Key)
+	})
+	emit := func(seq uint64) {
+		e := &CacheSyncEvent{ClientID: "peer", EventType: EventTypeDelete, Key: strconv.FormatUint(seq, 10), Seq: seq}
+		d.dispatch(string(e.Encode()))
+	}
+
+	// 乱序到达的事件在等待时间内补齐，不清空缓存且序号不回退
+	for _, seq := range []uint64{1, 3, 2, 5, 4, 6} {
+		emit(seq)
+	}
+	if flushes != 0 || len(keys) != 6 || d.seqs["peer"].max != 6 {
+		t.Errorf("flushes = %d, keys = %v, max = %d", flushes, keys, d.seqs["peer"].max)
+	}
+
+	// 缺失的事件超过等待时间仍未到达时清空缓存，仅清空一次
+	emit(8)
+	time.Sleep(60 * time.Millisecond)
+	d.checkGaps(time.Now())
+	d.checkGaps(time.Now())
+	if flushes != 1 {
+		t.Errorf("flushes = %d, want 1", flushes)
+	}
+	emit(7)
+	if flushes != 1 || d.seqs["peer"].max != 8 {
+		t.Errorf("late event should not flush again, flushes = %d", flushes)
+	}
+
+	// 空闲超过senderIdleTTL的发送端记录被删除
+	d.checkGaps(time.Now().Add(senderIdleTTL + time.Second))
+	if len(d.seqs) != 0 || flushes != 1 {
+		t.Errorf("seqs = %d, flushes = %d, idle sender should be pruned", len(d.seqs), flushes)
+	}
+}
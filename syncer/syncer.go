@@ -3,11 +3,23 @@ package syncer
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/utils"
 )
 
 // ErrNilClient Redis客户端空
 var ErrNilClient = errors.New("nil redis client")
 
+// ErrSyncerClosed 同步器已关闭
+var ErrSyncerClosed = errors.New("syncer closed")
+
 // EventHandler 本地缓存数据同步事件处理函数
 type EventHandler func(e *CacheSyncEvent)
 
@@ -17,6 +29,232 @@ type Syncer interface {
 	ClientID() string
 	// Emit 广播消息
 	Emit(ctx context.Context, e *CacheSyncEvent) error
-	// Subscribe 订阅消息
+	// Subscribe 订阅消息，ctx取消后退订
 	Subscribe(ctx context.Context, fn EventHandler) error
+	// Close 退订全部订阅并等待消费协程退出，关闭后不可再广播及订阅
+	Close(ctx context.Context) error
+}
+
+// subscriptions 订阅消费协程管理
+type subscriptions struct {
+	mu     sync.Mutex
+	closed bool
+	// 订阅的取消函数
+	cancels map[uint64]context.CancelFunc
+	nextId  uint64
+	wg      sync.WaitGroup
+}
+
+// start 启动订阅消费协程，ctx取消或关闭后run需退出
+func (s *subscriptions) start(ctx context.Context, run func(ctx context.Context)) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSyncerClosed
+	}
+	if s.cancels == nil {
+		s.cancels = make(map[uint64]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	id := s.nextId
+	s.nextId++
+	s.cancels[id] = cancel
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			cancel()
+			s.mu.Lock()
+			delete(s.cancels, id)
+			s.mu.Unlock()
+		}()
+		run(ctx)
+	}()
+	return nil
+}
+
+// close 取消全部订阅并等待消费协程退出
+func (s *subscriptions) close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isClosed 是否已关闭
+func (s *subscriptions) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// maxReorderGap 单次序号跳跃超过该值时视为事件丢失，立即投递EventTypeFlush事件，不再等待乱序到达
+const maxReorderGap = 1024
+
+// senderIdleTTL 发送端超过该时长未发送事件时删除其序号记录，实例重启后ClientID变化，避免记录无限增长
+// 删除后该发送端的下一条事件视为首条事件，空闲期间丢失的事件无法再通过序号发现
+const senderIdleTTL = 5 * time.Minute
+
+// dispatcher 解码并分发事件，按发送端记录事件序号
+// 同一发送端的并发广播可能乱序到达，缺失的序号在reorderWindow内到达视为乱序，超过reorderWindow仍缺失时投递EventTypeFlush事件
+type dispatcher struct {
+	channel  string
+	clientId string
+	fn       EventHandler
+	// 乱序事件的等待时间
+	reorderWindow time.Duration
+	// 各发送端的事件序号
+	seqs map[string]*seqState
+}
+
+// seqState 单个发送端的事件序号
+type seqState struct {
+	// 已收到的最大序号，仅增不减
+	max uint64
+	// 尚未到达的序号及发现缺失的时间
+	missing map[uint64]time.Time
+	// 最近一次收到事件的时间
+	lastSeen time.Time
+}
+
+// newDispatcher 创建事件分发器，忽略clientId自身发出的事件
+func newDispatcher(channel string, clientId string, reorderWindow time.Duration, fn EventHandler) *dispatcher {
+	return &dispatcher{
+		channel:       channel,
+		clientId:      clientId,
+		fn:            fn,
+		reorderWindow: reorderWindow,
+		seqs:          make(map[string]*seqState),
+	}
+}
+
+// dispatch 解码并分发事件
+func (d *dispatcher) dispatch(payload string) {
+	e := &CacheSyncEvent{}
+	err := e.Decode([]byte(payload))
+	if err != nil {
+		logger.Error(err.Error(), "channel", d.channel, "event", adaptor.LogEventSync)
+		return
+	}
+	if e.ClientID == d.clientId {
+		return
+	}
+	now := time.Now()
+	if e.Seq > 0 {
+		d.track(e.ClientID, e.Seq, now)
+	}
+	d.handle(e)
+	d.checkGaps(now)
+}
+
+// track 记录发送端的事件序号，迟到的序号从缺失集合中删除，序号跳跃时记录缺失的序号
+func (d *dispatcher) track(clientId string, seq uint64, now time.Time) {
+	st, ok := d.seqs[clientId]
+	if !ok {
+		d.seqs[clientId] = &seqState{max: seq, missing: make(map[uint64]time.Time), lastSeen: now}
+		return
+	}
+	st.lastSeen = now
+	if seq <= st.max {
+		delete(st.missing, seq)
+		return
+	}
+	gap := seq - st.max - 1
+	st.max = seq
+	if gap == 0 {
+		return
+	}
+	if d.reorderWindow <= 0 || gap > maxReorderGap {
+		logger.Warn("sync event lost", "channel", d.channel, "client", clientId, "missed", gap, "event", adaptor.LogEventSyncGap)
+		st.missing = make(map[uint64]time.Time)
+		d.flush(clientId)
+		return
+	}
+	for s := seq - gap; s < seq; s++ {
+		st.missing[s] = now
+	}
+}
+
+// checkGaps 缺失超过reorderWindow的序号视为事件丢失，投递EventTypeFlush事件并清空该发送端的缺失集合
+// 同时删除空闲超过senderIdleTTL的发送端记录
+func (d *dispatcher) checkGaps(now time.Time) {
+	idle := utils.IfExpr(senderIdleTTL > 3*d.reorderWindow, senderIdleTTL, 3*d.reorderWindow)
+	for clientId, st := range d.seqs {
+		if now.Sub(st.lastSeen) > idle {
+			delete(d.seqs, clientId)
+			continue
+		}
+		for _, at := range st.missing {
+			if now.Sub(at) < d.reorderWindow {
+				continue
+			}
+			logger.Warn("sync event lost", "channel", d.channel, "client", clientId, "missed", len(st.missing), "event", adaptor.LogEventSyncGap)
+			st.missing = make(map[uint64]time.Time)
+			d.flush(clientId)
+			break
+		}
+	}
+}
+
+// flush 投递EventTypeFlush事件
+func (d *dispatcher) flush(clientId string) {
+	d.handle(&CacheSyncEvent{ClientID: clientId, EventType: EventTypeFlush})
+}
+
+// reset 清空已记录的事件序号
+func (d *dispatcher) reset() {
+	d.seqs = make(map[string]*seqState)
+}
+
+// handle 调用事件处理函数，处理函数panic时记录日志并继续消费
+func (d *dispatcher) handle(e *CacheSyncEvent) {
+	defer func() {
+		if err := recover(); err != nil {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, false)
+			logger.Error(fmt.Sprint(err), "channel", d.channel, "event", adaptor.LogEventSync, "stack", string(buf[:n]))
+		}
+	}()
+	d.fn(e)
+}
+
+// backoff 第attempt次重试前的指数退避时间，在[1/2, 1]区间内随机
+func backoff(min time.Duration, max time.Duration, attempt int) time.Duration {
+	delay := min
+	for i := 0; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	delay = utils.IfExpr(delay > max, max, delay)
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// sleep 等待d，ctx取消时返回false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
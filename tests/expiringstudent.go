@@ -0,0 +1,19 @@
+package tests
+
+import (
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+var _ adaptor.Metadata = (*ExpiringStudent)(nil)
+var _ adaptor.Expiring = (*ExpiringStudent)(nil)
+
+// ExpiringStudent 自定义过期时间的测试用对象示例，以年龄(秒)作为过期时间
+type ExpiringStudent struct {
+	Student
+}
+
+func (s *ExpiringStudent) TTL() time.Duration {
+	return time.Duration(s.Age) * time.Second
+}
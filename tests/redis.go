@@ -16,3 +16,15 @@ func NewRedisClient() *redis.Client {
 		Addr: s.Addr(),
 	})
 }
+
+// NewRedisClusterClient 创建一个新的Redis集群客户端
+// miniredis模拟单节点集群，全部槽位均位于同一节点
+func NewRedisClusterClient() *redis.ClusterClient {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{s.Addr()},
+	})
+}
@@ -7,12 +7,15 @@ import (
 )
 
 var _ adaptor.Metadata = (*Student)(nil)
+var _ adaptor.Placeholder = (*Student)(nil)
 
 // Student 测试用对象示例
 type Student struct {
 	Name string `json:"name"`
 	Age  int    `json:"age"`
 	Time int64  `json:"time"`
+	// Nil 标识该对象为零值占位对象
+	Nil bool `json:"nil,omitempty"`
 }
 
 func (s *Student) Key() string {
@@ -30,5 +33,10 @@ func (s *Student) Decode(buf []byte) error {
 }
 
 func (s *Student) Zero() bool {
-	return s.Name == ""
+	return s.Name == "" || s.Nil
+}
+
+func (s *Student) Placeholder(key string) {
+	s.Name = key
+	s.Nil = true
 }
@@ -0,0 +1,19 @@
+package tests
+
+import (
+	"strconv"
+
+	"github.com/rumis/multicache/adaptor"
+)
+
+var _ adaptor.Metadata = (*TaggedStudent)(nil)
+var _ adaptor.Tagged = (*TaggedStudent)(nil)
+
+// TaggedStudent 带标签的测试用对象示例，以年龄作为标签
+type TaggedStudent struct {
+	Student
+}
+
+func (s *TaggedStudent) Tags() []string {
+	return []string{"age:" + strconv.Itoa(s.Age)}
+}
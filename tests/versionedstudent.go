@@ -0,0 +1,15 @@
+package tests
+
+import "github.com/rumis/multicache/adaptor"
+
+var _ adaptor.Metadata = (*VersionedStudent)(nil)
+var _ adaptor.Versioned = (*VersionedStudent)(nil)
+
+// VersionedStudent 带版本号的测试用对象示例，以Time作为版本号
+type VersionedStudent struct {
+	Student
+}
+
+func (s *VersionedStudent) Version() int64 {
+	return s.Time
+}
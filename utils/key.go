@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidKey 字符串无法解析为key类型
+var ErrInvalidKey = errors.New("invalid key")
+
+// ParseKey 将字符串形式的key(Metadata.Key的返回值)还原为K类型
+// K为字符串类型时直接转换，其他类型按fmt.Sscan解析
+func ParseKey[K comparable](s string) (K, error) {
+	var key K
+	if k, ok := any(s).(K); ok {
+		return k, nil
+	}
+	_, err := fmt.Sscan(s, &key)
+	if err != nil {
+		return key, fmt.Errorf("%w %q: %v", ErrInvalidKey, s, err)
+	}
+	return key, nil
+}
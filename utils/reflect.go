@@ -0,0 +1,18 @@
+package utils
+
+import "reflect"
+
+// NewOf 创建一个与v同类型的新对象
+// v为指针类型时创建其指向类型的新实例
+func NewOf[T any](v T) T {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		return reflect.New(t.Elem()).Interface().(T)
+	}
+	return reflect.Zero(t).Interface().(T)
+}
+
+// Assign 将src指向的对象浅拷贝至dst指向的对象，dst与src需为同类型的非空指针
+func Assign[T any](dst T, src T) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src).Elem())
+}
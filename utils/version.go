@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// versionPrefix 带版本号数据的前缀，完整格式为"ver:"+20位十进制版本号+":"+数据
+const versionPrefix = "ver:"
+
+// versionHeaderLen 版本号头部长度
+const versionHeaderLen = len(versionPrefix) + 20 + 1
+
+// FormatVersion 版本号格式化为定长十进制字符串，使字符串比较与数值比较结果一致，负数按零处理
+func FormatVersion(version int64) string {
+	return fmt.Sprintf("%020d", IfExpr(version < 0, 0, version))
+}
+
+// EncodeVersion 在数据前附加版本号头部
+func EncodeVersion(version int64, buf []byte) []byte {
+	out := make([]byte, 0, versionHeaderLen+len(buf))
+	out = append(out, versionPrefix...)
+	out = append(out, FormatVersion(version)...)
+	out = append(out, ':')
+	return append(out, buf...)
+}
+
+// DecodeVersion 解析数据的版本号头部，返回版本号及原始数据
+// 数据不含版本号头部时ok为false，原样返回数据
+func DecodeVersion(buf []byte) (version int64, payload []byte, ok bool) {
+	if len(buf) < versionHeaderLen || !bytes.HasPrefix(buf, []byte(versionPrefix)) || buf[versionHeaderLen-1] != ':' {
+		return 0, buf, false
+	}
+	version, err := strconv.ParseInt(String(buf[len(versionPrefix):versionHeaderLen-1]), 10, 64)
+	if err != nil {
+		return 0, buf, false
+	}
+	return version, buf[versionHeaderLen:], true
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	buf := EncodeVersion(1700000000000000000, []byte(`{"name":"张三"}`))
+	version, payload, ok := DecodeVersion(buf)
+	if !ok || version != 1700000000000000000 || !bytes.Equal(payload, []byte(`{"name":"张三"}`)) {
+		t.Errorf("DecodeVersion() = %d, %s, %v", version, payload, ok)
+	}
+
+	// 定长格式下字符串比较与数值比较一致
+	if FormatVersion(9) >= FormatVersion(10) {
+		t.Error("FormatVersion should keep numeric order")
+	}
+
+	// 不含版本号头部的数据原样返回
+	_, payload, ok = DecodeVersion([]byte(`{"name":"张三"}`))
+	if ok || string(payload) != `{"name":"张三"}` {
+		t.Error("DecodeVersion should return data without header as is")
+	}
+}
@@ -0,0 +1,335 @@
+package multicache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/metrics"
+	"github.com/rumis/multicache/utils"
+)
+
+// KeySource 预热key来源，通过add分批提交需要预热的key，add返回错误时应停止枚举并返回该错误
+type KeySource[K comparable] func(ctx context.Context, add func(keys ...K) error) error
+
+// KeysOf 以key列表作为预热key来源
+func KeysOf[K comparable](keys ...K) KeySource[K] {
+	return func(ctx context.Context, add func(keys ...K) error) error {
+		return add(keys...)
+	}
+}
+
+// KeysFromChan 以通道作为预热key来源，通道关闭后结束
+func KeysFromChan[K comparable](ch <-chan K) KeySource[K] {
+	return func(ctx context.Context, add func(keys ...K) error) error {
+		for {
+			select {
+			case key, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if err := add(key); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// KeysFromPager 以分页函数作为预热key来源，fn返回当前页的key及下一页游标，游标为0表示结束
+func KeysFromPager[K comparable](fn func(ctx context.Context, cursor uint64) ([]K, uint64, error)) KeySource[K] {
+	return func(ctx context.Context, add func(keys ...K) error) error {
+		var cursor uint64
+		for {
+			keys, next, err := fn(ctx, cursor)
+			if err != nil {
+				return err
+			}
+			if err := add(keys...); err != nil {
+				return err
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+}
+
+// WarmupProgress 预热进度
+type WarmupProgress struct {
+	// 已处理的key数量
+	Total int64
+	// 加载成功的key数量
+	Loaded int64
+	// 各级适配器中均不存在或为零值的key数量
+	Missing int64
+	// 加载失败的key数量
+	Failed int64
+	// 已耗时
+	Elapsed time.Duration
+}
+
+// Warmup 预热缓存，从source枚举key并通过适配器链路并发加载，加载到的数据由各适配器回写上层缓存
+// 单个key加载失败不会中断预热，通过OnError回调及进度中的Failed统计；枚举失败或ctx取消时返回错误
+func (c *Cache[K, V]) Warmup(ctx context.Context, source KeySource[K], fns ...WarmupOptionFunc) (WarmupProgress, error) {
+	opts := DefaultWarmupOption()
+	for _, fn := range fns {
+		fn(&opts)
+	}
+	adaptors := warmupAdaptors(c.adaptors, opts.CacheOnly)
+	return runWarmup(ctx, c.name, source, opts, func(ctx context.Context, keys adaptor.Keys[K]) (int64, int64, map[K]error) {
+		var loaded, missing int64
+		failed := make(map[K]error)
+		for _, key := range keys {
+			ok, err := c.warmKey(ctx, adaptors, key)
+			switch {
+			case ok:
+				loaded++
+			case err != nil:
+				failed[key] = err
+			default:
+				missing++
+			}
+		}
+		return loaded, missing, failed
+	})
+}
+
+// warmKey 沿适配器链路加载单个key，软过期数据同样视为加载成功且不触发后台刷新
+func (c *Cache[K, V]) warmKey(ctx context.Context, adaptors []adaptor.Adaptor[K, V], key K) (bool, error) {
+	ctx = context.WithValue(ctx, metrics.MetricsTraceKey, utils.UUID())
+	ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
+	c.metric.Start(ctx, c.name)
+	defer c.metric.Summary(ctx)
+
+	var zero V
+	value := utils.NewOf(zero)
+	var lastErr error
+	for _, adap := range adaptors {
+		ok, err := adap.Get(ctx, key, value)
+		if errors.Is(err, adaptor.ErrStale) {
+			err = nil
+		}
+		if err != nil {
+			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", key, "event", adaptor.LogEventWarmup)
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			lastErr = err
+		}
+		if ok {
+			return !value.Zero(), nil
+		}
+	}
+	return false, lastErr
+}
+
+// Warmup 预热缓存，从source枚举key并按批通过适配器链路并发加载，加载到的数据由各适配器回写上层缓存
+// 单批加载失败不会中断预热，通过OnError回调及进度中的Failed统计；枚举失败或ctx取消时返回错误
+func (c *MultiCache[K, V]) Warmup(ctx context.Context, source KeySource[K], fn adaptor.NewValueFunc[V], fns ...WarmupOptionFunc) (WarmupProgress, error) {
+	opts := DefaultWarmupOption()
+	for _, optFn := range fns {
+		optFn(&opts)
+	}
+	adaptors := warmupAdaptors(c.adaptors, opts.CacheOnly)
+	return runWarmup(ctx, c.name, source, opts, func(ctx context.Context, keys adaptor.Keys[K]) (int64, int64, map[K]error) {
+		vals, err := c.warmKeys(ctx, adaptors, keys, fn)
+		var loaded, missing int64
+		failed := make(map[K]error)
+		for _, key := range keys {
+			val, ok := vals[key]
+			switch {
+			case ok && !val.Zero():
+				loaded++
+			case !ok && err != nil:
+				failed[key] = err
+			default:
+				missing++
+			}
+		}
+		return loaded, missing, failed
+	})
+}
+
+// warmKeys 沿适配器链路加载一批key，返回加载到的数据及最后一个错误
+func (c *MultiCache[K, V]) warmKeys(ctx context.Context, adaptors []adaptor.MultiAdaptor[K, V], keys adaptor.Keys[K], fn adaptor.NewValueFunc[V]) (adaptor.Values[K, V], error) {
+	ctx = context.WithValue(ctx, metrics.MetricsTraceKey, utils.UUID())
+	ctx = context.WithValue(ctx, metrics.MetricsClient, c.metric)
+	c.metric.Start(ctx, c.name)
+	defer c.metric.Summary(ctx)
+
+	vals := make(adaptor.Values[K, V], len(keys))
+	var lastErr error
+	tmpKeys := keys
+	for _, adap := range adaptors {
+		_, err := adap.Get(ctx, tmpKeys, vals, fn)
+		if err != nil {
+			logger.Error(err.Error(), "solution", c.name, "adaptor", adap.Name(), "key", tmpKeys, "event", adaptor.LogEventWarmup)
+			if ctx.Err() != nil {
+				return vals, ctx.Err()
+			}
+			lastErr = err
+		}
+		if len(vals) == len(keys) {
+			return vals, nil
+		}
+		missKeys := make(adaptor.Keys[K], 0, len(keys)-len(vals))
+		for _, key := range keys {
+			if _, ok := vals[key]; !ok {
+				missKeys = append(missKeys, key)
+			}
+		}
+		tmpKeys = missKeys
+	}
+	return vals, lastErr
+}
+
+// warmupAdaptors 预热使用的适配器，仅从缓存预热时截止至第一个数据源适配器
+func warmupAdaptors[A any](adaptors []A, cacheOnly bool) []A {
+	if !cacheOnly {
+		return adaptors
+	}
+	for i, adap := range adaptors {
+		if _, ok := any(adap).(adaptor.Source); ok {
+			return adaptors[:i]
+		}
+	}
+	return adaptors
+}
+
+// runWarmup 枚举key并按批分发至多个协程加载，load返回一批key中加载成功、不存在的数量及失败的key
+func runWarmup[K comparable](ctx context.Context, name string, source KeySource[K], opts WarmupOption, load func(ctx context.Context, keys adaptor.Keys[K]) (int64, int64, map[K]error)) (WarmupProgress, error) {
+	concurrency := utils.IfExpr(opts.Concurrency > 0, opts.Concurrency, 1)
+	batchSize := utils.IfExpr(opts.BatchSize > 0, opts.BatchSize, 1)
+	limiter := newRateLimiter(opts.Rate)
+	startTime := time.Now()
+
+	var total, loaded, missing, failed int64
+	progress := func() WarmupProgress {
+		return WarmupProgress{
+			Total:   atomic.LoadInt64(&total),
+			Loaded:  atomic.LoadInt64(&loaded),
+			Missing: atomic.LoadInt64(&missing),
+			Failed:  atomic.LoadInt64(&failed),
+			Elapsed: time.Since(startTime),
+		}
+	}
+
+	batches := make(chan adaptor.Keys[K], concurrency)
+	var callbackMu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for keys := range batches {
+				l, m, f := load(ctx, keys)
+				atomic.AddInt64(&loaded, l)
+				atomic.AddInt64(&missing, m)
+				atomic.AddInt64(&failed, int64(len(f)))
+				atomic.AddInt64(&total, int64(len(keys)))
+
+				callbackMu.Lock()
+				if opts.OnError != nil {
+					for key, err := range f {
+						opts.OnError(fmt.Sprint(key), err)
+					}
+				}
+				if opts.OnProgress != nil {
+					opts.OnProgress(progress())
+				}
+				callbackMu.Unlock()
+			}
+		}()
+	}
+
+	// 按批分发，限速时等待至可分发的时间
+	batch := make(adaptor.Keys[K], 0, batchSize)
+	dispatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := limiter.wait(ctx, len(batch)); err != nil {
+			return err
+		}
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		batch = make(adaptor.Keys[K], 0, batchSize)
+		return nil
+	}
+	err := source(ctx, func(keys ...K) error {
+		for _, key := range keys {
+			batch = append(batch, key)
+			if len(batch) >= batchSize {
+				if err := dispatch(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = dispatch()
+	}
+	close(batches)
+	wg.Wait()
+
+	if err != nil {
+		logger.Error(err.Error(), "solution", name, "event", adaptor.LogEventWarmup)
+	}
+	return progress(), err
+}
+
+// rateLimiter 按固定间隔发放配额的限速器
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter 创建每秒发放rate个配额的限速器，rate不大于零时不限速
+func newRateLimiter(rate int) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Second / time.Duration(rate)}
+}
+
+// wait 预留n个配额，等待至配额可用
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l.interval <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(time.Duration(n) * l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,307 @@
+package multicache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rumis/multicache/adaptor"
+	"github.com/rumis/multicache/datasource"
+	"github.com/rumis/multicache/logger"
+	"github.com/rumis/multicache/utils"
+)
+
+// ErrWriteQueueFull 异步写回队列已满
+var ErrWriteQueueFull = errors.New("write-behind queue is full")
+
+// writeOp 异步写回队列中的单个操作
+type writeOp[K comparable, V adaptor.Metadata] struct {
+	del bool
+	key K
+	val V
+}
+
+// writer 数据源写入器，负责各写策略下数据源一侧的写入
+type writer[K comparable, V adaptor.Metadata] struct {
+	name     string
+	policy   WritePolicy
+	writeFn  datasource.DataSourceWriteFunc[V]
+	deleteFn datasource.DataSourceDeleteFunc[K]
+	opts     WriteBehindOption
+	onError  WriteErrorFunc[K, V]
+
+	// 待写回的操作，同一key仅保留最后一次操作
+	mu      sync.Mutex
+	pending map[string]writeOp[K, V]
+	// 保证同一时刻仅有一个刷新过程，从而保证同一key的写入顺序
+	flushMu sync.Mutex
+	notify  chan struct{}
+	// 停止后台刷新协程
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// 后台刷新使用的上下文，关闭超时时取消，中断进行中的重试
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newWriter 创建数据源写入器，异步写回模式下启动后台刷新协程
+func newWriter[K comparable, V adaptor.Metadata](name string, opts CacheOption[K, V]) *writer[K, V] {
+	// 开启写策略时必须提供数据源写入函数，否则写入被静默丢弃，未提供时回退为仅写缓存
+	if opts.WritePolicy != WritePolicyNone && opts.WriteFunc == nil {
+		logger.Error(fmt.Sprintf("%s requires a non-nil write function, fall back to %s", opts.WritePolicy, WritePolicyNone), "solution", name, "event", adaptor.LogEventInit)
+		opts.WritePolicy = WritePolicyNone
+	}
+	// 非法的异步写回配置使用默认值
+	defaultOpts := DefaultCacheOption[K, V]().WriteBehind
+	opts.WriteBehind.BatchSize = utils.IfExpr(opts.WriteBehind.BatchSize > 0, opts.WriteBehind.BatchSize, defaultOpts.BatchSize)
+	opts.WriteBehind.FlushInterval = utils.IfExpr(opts.WriteBehind.FlushInterval > 0, opts.WriteBehind.FlushInterval, defaultOpts.FlushInterval)
+	opts.WriteBehind.QueueSize = utils.IfExpr(opts.WriteBehind.QueueSize > 0, opts.WriteBehind.QueueSize, defaultOpts.QueueSize)
+	opts.WriteBehind.WriteTimeout = utils.IfExpr(opts.WriteBehind.WriteTimeout > 0, opts.WriteBehind.WriteTimeout, defaultOpts.WriteTimeout)
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &writer[K, V]{
+		name:     name,
+		policy:   opts.WritePolicy,
+		writeFn:  opts.WriteFunc,
+		deleteFn: opts.DeleteFunc,
+		opts:     opts.WriteBehind,
+		onError:  opts.OnWriteError,
+		pending:  make(map[string]writeOp[K, V]),
+		notify:   make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	if w.policy == WritePolicyBehind {
+		go w.loop()
+	} else {
+		close(w.done)
+	}
+	return w
+}
+
+// write 同步写入数据源
+func (w *writer[K, V]) write(ctx context.Context, vals adaptor.ValueCol[V]) error {
+	if w.writeFn == nil || len(vals) == 0 {
+		return nil
+	}
+	return w.call(ctx, func(ctx context.Context) error {
+		return w.writeFn(ctx, vals)
+	})
+}
+
+// delete 同步从数据源删除
+func (w *writer[K, V]) delete(ctx context.Context, keys adaptor.Keys[K]) error {
+	if w.deleteFn == nil || len(keys) == 0 {
+		return nil
+	}
+	return w.call(ctx, func(ctx context.Context) error {
+		return w.deleteFn(ctx, keys)
+	})
+}
+
+// enqueueSet 写入操作进入异步写回队列
+func (w *writer[K, V]) enqueueSet(vals adaptor.ValueCol[V]) error {
+	if w.writeFn == nil {
+		return nil
+	}
+	ops := make(map[string]writeOp[K, V], len(vals))
+	for _, val := range vals {
+		ops[val.Key()] = writeOp[K, V]{val: val}
+	}
+	return w.enqueue(ops)
+}
+
+// enqueueDel 删除操作进入异步写回队列
+func (w *writer[K, V]) enqueueDel(keys adaptor.Keys[K]) error {
+	if w.deleteFn == nil {
+		return nil
+	}
+	ops := make(map[string]writeOp[K, V], len(keys))
+	for _, key := range keys {
+		ops[fmt.Sprint(key)] = writeOp[K, V]{del: true, key: key}
+	}
+	return w.enqueue(ops)
+}
+
+// enqueue 合并操作至队列，队列积压达到批量大小时通知后台协程立即刷新
+func (w *writer[K, V]) enqueue(ops map[string]writeOp[K, V]) error {
+	w.mu.Lock()
+	size := len(w.pending)
+	for id := range ops {
+		if _, ok := w.pending[id]; !ok {
+			size++
+		}
+	}
+	if size > w.opts.QueueSize {
+		w.mu.Unlock()
+		return ErrWriteQueueFull
+	}
+	for id, op := range ops {
+		w.pending[id] = op
+	}
+	w.mu.Unlock()
+
+	if size >= w.opts.BatchSize {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// loop 后台定时刷新，停止后退出
+func (w *writer[K, V]) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.notify:
+		case <-w.stop:
+			return
+		}
+		w.flush(w.ctx)
+	}
+}
+
+// close 停止后台刷新协程并刷新队列中剩余的操作，ctx结束时中断进行中的重试，未写入的操作保留在队列中
+func (w *writer[K, V]) close(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+	err := w.sync(ctx)
+	w.cancel()
+	return err
+}
+
+// sync 立即刷新队列并等待刷新完成，ctx结束时中断重试，未写入的操作重新放回队列
+func (w *writer[K, V]) sync(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- w.flush(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush 将队列中的操作分批写入数据源
+// ctx结束导致写入中断时将该批操作放回队列(队列中已有同一key的更新操作时以新操作为准)；重试耗尽后丢弃，记录错误日志并调用OnWriteError
+func (w *writer[K, V]) flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	ops := w.pending
+	w.pending = make(map[string]writeOp[K, V])
+	w.mu.Unlock()
+
+	setIds := make([]string, 0, len(ops))
+	delIds := make([]string, 0)
+	for id, op := range ops {
+		if op.del {
+			delIds = append(delIds, id)
+		} else {
+			setIds = append(setIds, id)
+		}
+	}
+
+	var firstErr error
+	for _, ids := range [][]string{setIds, delIds} {
+		for start := 0; start < len(ids); start += w.opts.BatchSize {
+			batch := ids[start:utils.IfExpr(start+w.opts.BatchSize < len(ids), start+w.opts.BatchSize, len(ids))]
+			err := w.flushBatch(ctx, ops, batch)
+			firstErr = utils.IfExpr(firstErr == nil, err, firstErr)
+		}
+	}
+	return firstErr
+}
+
+// flushBatch 写入一批操作，批内操作均为写入或均为删除
+func (w *writer[K, V]) flushBatch(ctx context.Context, ops map[string]writeOp[K, V], ids []string) error {
+	var vals adaptor.ValueCol[V]
+	var keys adaptor.Keys[K]
+	for _, id := range ids {
+		if op := ops[id]; op.del {
+			keys = append(keys, op.key)
+		} else {
+			vals = append(vals, op.val)
+		}
+	}
+	err := w.retry(ctx, func(ctx context.Context) error {
+		if len(keys) > 0 {
+			return w.deleteFn(ctx, keys)
+		}
+		return w.writeFn(ctx, vals)
+	})
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		// 写入被中断，放回队列等待下次刷新
+		w.mu.Lock()
+		for _, id := range ids {
+			if _, ok := w.pending[id]; !ok {
+				w.pending[id] = ops[id]
+			}
+		}
+		w.mu.Unlock()
+		return err
+	}
+	logger.Error(err.Error(), "solution", w.name, "value", vals, "key", keys, "event", adaptor.LogEventWrite)
+	if w.onError != nil {
+		w.call(ctx, func(context.Context) error {
+			w.onError(vals, keys, err)
+			return nil
+		})
+	}
+	return err
+}
+
+// retry 调用数据源，每次调用的超时时间为WriteTimeout，失败后按重试间隔线性退避重试，ctx结束时立即返回
+func (w *writer[K, V]) retry(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for i := 0; i <= w.opts.MaxRetry; i++ {
+		if i > 0 {
+			timer := time.NewTimer(time.Duration(i) * w.opts.RetryInterval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+		callCtx, cancel := context.WithTimeout(ctx, w.opts.WriteTimeout)
+		err = w.call(callCtx, fn)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// call 调用数据源，捕获数据源函数中的panic
+func (w *writer[K, V]) call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = errors.New(fmt.Sprint(e))
+		}
+	}()
+	return fn(ctx)
+}